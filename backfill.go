@@ -0,0 +1,250 @@
+package krakenapi
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+)
+
+// tradesPageSize is the maximum number of trades Kraken returns per Trades call
+const tradesPageSize = 1000
+
+// backfillRetryDelays are the pauses after Kraken rejected a call for its rate limit
+var backfillRetryDelays = []time.Duration{2 * time.Second, 5 * time.Second, 15 * time.Second, 30 * time.Second, time.Minute}
+
+// TradeBackfill walks every public trade of a pair between two times, oldest first,
+// following the nanosecond since cursor of the Trades endpoint
+type TradeBackfill struct {
+	api     *KrakenAPI
+	ctx     context.Context
+	pair    string
+	from    time.Time
+	to      time.Time
+	cursor  int64
+	lastID  int64
+	buffer  []TradeInfo
+	current TradeInfo
+	last    bool
+	done    bool
+	err     error
+}
+
+// BackfillTrades returns a TradeBackfill over the trades of pair executed after from and
+// up to to. A zero to walks up to the most recent trade.
+// A zero from starts at the first trade.
+func (api *KrakenAPI) BackfillTrades(ctx context.Context, pair string, from time.Time, to time.Time) *TradeBackfill {
+	var cursor int64
+	if !from.IsZero() {
+		cursor = from.UnixNano()
+	}
+	return &TradeBackfill{api: api, ctx: ctx, pair: pair, from: from, to: to, cursor: cursor}
+}
+
+// ResumeTradeBackfill continues a TradeBackfill from the values returned by its Cursor and
+// LastTradeID methods. Trades up to lastID are skipped.
+func (api *KrakenAPI) ResumeTradeBackfill(ctx context.Context, pair string, cursor int64, lastID int64, to time.Time) *TradeBackfill {
+	return &TradeBackfill{api: api, ctx: ctx, pair: pair, from: time.Unix(0, cursor), to: to, cursor: cursor, lastID: lastID}
+}
+
+// Next advances to the next trade, it returns false at the end or on error
+func (b *TradeBackfill) Next() bool {
+	for len(b.buffer) == 0 {
+		if b.done || b.err != nil {
+			return false
+		}
+		if b.last {
+			b.done = true
+			return false
+		}
+		if err := b.load(); err != nil {
+			b.err = err
+			return false
+		}
+	}
+
+	b.current, b.buffer = b.buffer[0], b.buffer[1:]
+	if b.current.TradeID > 0 {
+		b.lastID = b.current.TradeID
+	}
+	return true
+}
+
+// load fetches the trades after the cursor, retrying when rate limited
+func (b *TradeBackfill) load() error {
+	var resp *TradesResponse
+	var err error
+	for attempt := 0; ; attempt++ {
+		resp, err = b.api.TradesContext(b.ctx, b.pair, b.cursor)
+		if !isRateLimitError(err) || attempt >= len(backfillRetryDelays) {
+			break
+		}
+		if err := sleepContext(b.ctx, backfillRetryDelays[attempt]); err != nil {
+			return err
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	for _, trade := range resp.Trades {
+		if !b.to.IsZero() && trade.Timestamp.After(b.to) {
+			b.last = true
+			break
+		}
+		if !trade.Timestamp.After(b.from) || (trade.TradeID > 0 && trade.TradeID <= b.lastID) {
+			continue
+		}
+		b.buffer = append(b.buffer, trade)
+	}
+
+	// A short page means the tape is exhausted up to now
+	if len(resp.Trades) < tradesPageSize || resp.Last <= b.cursor {
+		b.last = true
+	}
+	b.cursor = resp.Last
+	return nil
+}
+
+// Trade returns the current trade
+func (b *TradeBackfill) Trade() TradeInfo {
+	return b.current
+}
+
+// Err returns the error that stopped the backfill
+func (b *TradeBackfill) Err() error {
+	return b.err
+}
+
+// Cursor returns a since cursor before the trades not yet returned, which can be passed to
+// ResumeTradeBackfill together with LastTradeID. Trades buffered but not yet returned are
+// fetched again on resume.
+func (b *TradeBackfill) Cursor() int64 {
+	if len(b.buffer) > 0 {
+		return b.buffer[0].Timestamp.UnixNano() - 1
+	}
+	return b.cursor
+}
+
+// LastTradeID returns the ID of the last returned trade, 0 if Kraken sent none
+func (b *TradeBackfill) LastTradeID() int64 {
+	return b.lastID
+}
+
+// TradeFormat selects the output format of a TradeWriter
+type TradeFormat string
+
+// Supported trade output formats
+const (
+	TradeFormatCSV       TradeFormat = "csv"
+	TradeFormatJSONLines TradeFormat = "jsonl"
+)
+
+// TradeWriter writes public trades to a stream
+type TradeWriter interface {
+	Write(trade TradeInfo) error
+	Flush() error
+}
+
+// NewTradeWriter creates a TradeWriter for the given format
+func NewTradeWriter(w io.Writer, format TradeFormat) (TradeWriter, error) {
+	switch format {
+	case TradeFormatCSV:
+		return &csvTradeWriter{writer: csv.NewWriter(w)}, nil
+	case TradeFormatJSONLines:
+		return &jsonTradeWriter{encoder: json.NewEncoder(w)}, nil
+	}
+	return nil, fmt.Errorf("unsupported trade format %q", format)
+}
+
+// tradeRecord is the serialized form of a public trade
+type tradeRecord struct {
+	Time    string `json:"time"`
+	Price   string `json:"price"`
+	Volume  string `json:"volume"`
+	Side    Side   `json:"side"`
+	Type    string `json:"type"`
+	Misc    string `json:"misc"`
+	TradeID int64  `json:"trade_id,omitempty"`
+}
+
+// newTradeRecord converts trade, keeping the decimal places Kraken sent. An unknown side
+// or order type is left empty.
+func newTradeRecord(trade TradeInfo) tradeRecord {
+	record := tradeRecord{
+		Time:    trade.Timestamp.UTC().Format(time.RFC3339Nano),
+		Price:   trade.Price.StringFixed(trade.Price.scale),
+		Volume:  trade.Volume.StringFixed(trade.Volume.scale),
+		Misc:    trade.Miscellaneous,
+		TradeID: trade.TradeID,
+	}
+	switch {
+	case trade.Buy:
+		record.Side = SideBuy
+	case trade.Sell:
+		record.Side = SideSell
+	}
+	switch {
+	case trade.Market:
+		record.Type = OTMarket
+	case trade.Limit:
+		record.Type = OTLimit
+	}
+	return record
+}
+
+type csvTradeWriter struct {
+	writer *csv.Writer
+	header bool
+}
+
+func (w *csvTradeWriter) Write(trade TradeInfo) error {
+	if !w.header {
+		w.header = true
+		if err := w.writer.Write([]string{"time", "price", "volume", "side", "type", "misc", "trade_id"}); err != nil {
+			return err
+		}
+	}
+
+	record := newTradeRecord(trade)
+	tradeID := ""
+	if record.TradeID > 0 {
+		tradeID = strconv.FormatInt(record.TradeID, 10)
+	}
+	return w.writer.Write([]string{record.Time, record.Price, record.Volume, string(record.Side), record.Type, record.Misc, tradeID})
+}
+
+func (w *csvTradeWriter) Flush() error {
+	w.writer.Flush()
+	return w.writer.Error()
+}
+
+type jsonTradeWriter struct {
+	encoder *json.Encoder
+}
+
+func (w *jsonTradeWriter) Write(trade TradeInfo) error {
+	return w.encoder.Encode(newTradeRecord(trade))
+}
+
+func (w *jsonTradeWriter) Flush() error {
+	return nil
+}
+
+// WriteTrades drains the backfill into w and returns the number of trades written
+func WriteTrades(w TradeWriter, backfill *TradeBackfill) (int, error) {
+	count := 0
+	for backfill.Next() {
+		if err := w.Write(backfill.Trade()); err != nil {
+			return count, err
+		}
+		count++
+	}
+	if err := w.Flush(); err != nil {
+		return count, err
+	}
+	return count, backfill.Err()
+}
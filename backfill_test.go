@@ -0,0 +1,94 @@
+package krakenapi
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testTrades = `{"error":[],"result":{"XXBTZUSD":[
+	["30243.40000","0.34507674",1688669597.8277369,"b","m","",61044952],
+	["30243.30000","0.00100000",1688669597.9,"s","l","",61044953],
+	["30240.00000","0.50000000",1688669700.25,"s","l","",61044954]
+],"last":"1688669700250000000"}}`
+
+func TestTradeBackfill(t *testing.T) {
+	api := newTestAPI(testTrades)
+	backfill := api.BackfillTrades(context.Background(), "XBTUSD", time.Unix(1688669000, 0), time.Unix(1688669600, 0))
+
+	var buf bytes.Buffer
+	w, err := NewTradeWriter(&buf, TradeFormatCSV)
+	if err != nil {
+		t.Fatal(err)
+	}
+	count, err := WriteTrades(w, backfill)
+	if err != nil {
+		t.Fatalf("WriteTrades() should not return an error, got %s", err)
+	}
+	if count != 2 {
+		t.Errorf("TradeBackfill should stop at the end time, got %d trades", count)
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 3 || lines[0] != "time,price,volume,side,type,misc,trade_id" {
+		t.Fatalf("CSV output should have a header and one line per trade, got %q", buf.String())
+	}
+	if lines[1] != "2023-07-06T18:53:17.827737Z,30243.40000,0.34507674,buy,market,,61044952" {
+		t.Errorf("CSV output is wrong, got %q", lines[1])
+	}
+}
+
+func TestTradeBackfillJSONLines(t *testing.T) {
+	api := newTestAPI(testTrades)
+	backfill := api.BackfillTrades(context.Background(), XXBTZUSD, time.Unix(1688669597, 850000000), time.Time{})
+
+	var buf bytes.Buffer
+	w, _ := NewTradeWriter(&buf, TradeFormatJSONLines)
+	count, err := WriteTrades(w, backfill)
+	if err != nil || count != 2 {
+		t.Fatalf("WriteTrades() should write the trades after the start time, got %d (%v)", count, err)
+	}
+	if !strings.HasPrefix(buf.String(), `{"time":"2023-07-06T18:53:17.9Z","price":"30243.30000","volume":"0.00100000","side":"sell","type":"limit","misc":"","trade_id":61044953}`) {
+		t.Errorf("JSON Lines output is wrong, got %s", buf.String())
+	}
+	if backfill.Cursor() != 1688669700250000000 {
+		t.Errorf("Cursor() should return the last cursor, got %d", backfill.Cursor())
+	}
+
+	if _, err := NewTradeWriter(&buf, "xml"); err == nil {
+		t.Errorf("NewTradeWriter() should reject unknown formats")
+	}
+
+	buf.Reset()
+	w.Write(TradeInfo{Price: MustDecimal("30240.0"), Volume: MustDecimal("0.5"), Timestamp: time.Unix(1688669700, 0)})
+	if !strings.Contains(buf.String(), `"side":"","type":""`) {
+		t.Errorf("a trade without side and order type should be written without them, got %s", buf.String())
+	}
+}
+
+func TestTradeBackfillResume(t *testing.T) {
+	page := `{"error":[],"result":{"XXBTZUSD":[
+		["30243.40000","0.34507674",1688669597.9,"b","m","",61044952],
+		["30243.30000","0.00100000",1688669597.9,"s","l","",61044953],
+		["30240.00000","0.50000000",1688669597.9,"s","l","",61044954]
+	],"last":"1688669597900000000"}}`
+	api := newTestAPI(page)
+	backfill := api.BackfillTrades(context.Background(), XXBTZUSD, time.Time{}, time.Time{})
+	if backfill.cursor != 0 {
+		t.Errorf("BackfillTrades() should start at cursor 0 without a start time, got %d", backfill.cursor)
+	}
+	if !backfill.Next() || backfill.Trade().TradeID != 61044952 {
+		t.Fatalf("Next() should return the first trade, got %+v (%v)", backfill.Trade(), backfill.Err())
+	}
+
+	resumed := api.ResumeTradeBackfill(context.Background(), XXBTZUSD, backfill.Cursor(), backfill.LastTradeID(), time.Time{})
+	var ids []int64
+	for resumed.Next() {
+		ids = append(ids, resumed.Trade().TradeID)
+	}
+	if resumed.Err() != nil || len(ids) != 2 || ids[0] != 61044953 || ids[1] != 61044954 {
+		t.Errorf("ResumeTradeBackfill() should return the trades sharing the time of the last one, got %v (%v)", ids, resumed.Err())
+	}
+}
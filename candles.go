@@ -0,0 +1,129 @@
+package krakenapi
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// CandleBuilder aggregates public trades into OHLC candles of one interval.
+// Candles start at multiples of the interval since the unix epoch, like Kraken's.
+// Trades must be added oldest first, intervals without trades produce no candle.
+type CandleBuilder struct {
+	interval int64
+	current  *OHLC
+	notional Decimal
+	places   int32
+}
+
+// NewCandleBuilder creates a CandleBuilder for a supported OHLC interval in minutes
+func NewCandleBuilder(interval string) (*CandleBuilder, error) {
+	duration, err := intervalDuration(interval)
+	if err != nil {
+		return nil, err
+	}
+	return &CandleBuilder{interval: int64(duration / time.Second)}, nil
+}
+
+// start returns the start of the candle containing t
+func (b *CandleBuilder) start(t time.Time) time.Time {
+	ts := t.Unix()
+	return time.Unix(ts-((ts%b.interval)+b.interval)%b.interval, 0)
+}
+
+// Add adds a trade and returns the candle it completed, nil while the current candle is open
+func (b *CandleBuilder) Add(trade TradeInfo) (*OHLC, error) {
+	executed := trade.Timestamp
+	if executed.IsZero() {
+		executed = time.Unix(trade.Time, 0)
+	}
+	return b.add(executed, trade.Price, trade.Volume)
+}
+
+// AddPrice adds a price without volume, e.g. a mark price, and returns the candle it
+// completed. Count is the number of prices in the candle.
+func (b *CandleBuilder) AddPrice(t time.Time, price Decimal) (*OHLC, error) {
+	return b.add(t, price, Decimal{})
+}
+
+// add adds a price executed at executed
+func (b *CandleBuilder) add(executed time.Time, price Decimal, volume Decimal) (*OHLC, error) {
+	start := b.start(executed)
+
+	var completed *OHLC
+	if b.current != nil {
+		if start.Before(b.current.Time) {
+			return nil, fmt.Errorf("trade at %s is older than the candle at %s", executed.UTC(), b.current.Time.UTC())
+		}
+		if start.After(b.current.Time) {
+			completed = b.Flush()
+		}
+	}
+
+	if b.current == nil {
+		b.current = &OHLC{Time: start, Open: price, High: price, Low: price}
+	}
+	candle := b.current
+	if price.GreaterThan(candle.High) {
+		candle.High = price
+	}
+	if price.LessThan(candle.Low) {
+		candle.Low = price
+	}
+	candle.Close = price
+	candle.Volume = candle.Volume.Add(volume)
+	candle.Count++
+	b.notional = b.notional.Add(price.Mul(volume))
+	if places := price.Places(); places > b.places {
+		b.places = places
+	}
+	return completed, nil
+}
+
+// Flush returns the current candle, nil if no trade was added since the last one completed
+func (b *CandleBuilder) Flush() *OHLC {
+	candle := b.current
+	if candle == nil {
+		return nil
+	}
+	// The vwap is given with the precision of the prices, as by Kraken
+	if candle.Volume.Sign() > 0 {
+		candle.Vwap = b.notional.Div(candle.Volume, b.places)
+	}
+
+	b.current = nil
+	b.notional = Decimal{}
+	b.places = 0
+	return candle
+}
+
+// BuildOHLC reconstructs the candles of pair from the public Trades tape, starting with
+// the candle containing from and ending with the one containing to, which only holds
+// the trades up to to. Unlike OHLC it is not limited to the most recent 720 candles,
+// but it fetches every trade of the range.
+func (api *KrakenAPI) BuildOHLC(ctx context.Context, pair string, interval string, from time.Time, to time.Time) ([]*OHLC, error) {
+	builder, err := NewCandleBuilder(interval)
+	if err != nil {
+		return nil, err
+	}
+
+	// The backfill skips trades at its start, begin just before the first candle
+	backfill := api.BackfillTrades(ctx, pair, builder.start(from).Add(-time.Nanosecond), to)
+	var candles []*OHLC
+	for backfill.Next() {
+		candle, err := builder.Add(backfill.Trade())
+		if err != nil {
+			return nil, err
+		}
+		if candle != nil {
+			candles = append(candles, candle)
+		}
+	}
+	if err := backfill.Err(); err != nil {
+		return nil, err
+	}
+	if candle := builder.Flush(); candle != nil {
+		candles = append(candles, candle)
+	}
+	return candles, nil
+}
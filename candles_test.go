@@ -0,0 +1,90 @@
+package krakenapi
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestCandleBuilder(t *testing.T) {
+	if _, err := NewCandleBuilder("2"); err == nil {
+		t.Error("NewCandleBuilder() should reject unsupported intervals")
+	}
+
+	builder, err := NewCandleBuilder("5")
+	if err != nil {
+		t.Fatal(err)
+	}
+	trades := []TradeInfo{
+		{Price: MustDecimal("100.0"), Volume: MustDecimal("1"), Timestamp: time.Unix(1688669700, 0)},
+		{Price: MustDecimal("102.5"), Volume: MustDecimal("1"), Timestamp: time.Unix(1688669800, 0)},
+		{Price: MustDecimal("99.0"), Volume: MustDecimal("2"), Timestamp: time.Unix(1688669999, 500)},
+		{Price: MustDecimal("101.0"), Volume: MustDecimal("1"), Timestamp: time.Unix(1688670300, 0)},
+	}
+	var candles []*OHLC
+	for _, trade := range trades {
+		candle, err := builder.Add(trade)
+		if err != nil {
+			t.Fatalf("Add() should not return an error, got %s", err)
+		}
+		if candle != nil {
+			candles = append(candles, candle)
+		}
+	}
+	if len(candles) != 1 {
+		t.Fatalf("Add() should complete one candle, got %d", len(candles))
+	}
+
+	candle := candles[0]
+	if !candle.Time.Equal(time.Unix(1688669700, 0)) || candle.Count != 3 {
+		t.Errorf("candle has wrong time or count, got %+v", candle)
+	}
+	if candle.Open.String() != "100" || candle.High.String() != "102.5" || candle.Low.String() != "99" || candle.Close.String() != "99" {
+		t.Errorf("candle has wrong prices, got %+v", candle)
+	}
+	if candle.Volume.String() != "4" || candle.Vwap.String() != "100.1" {
+		t.Errorf("candle has wrong volume or vwap, got %s %s", candle.Volume, candle.Vwap)
+	}
+
+	if _, err := builder.Add(trades[0]); err == nil {
+		t.Error("Add() should reject trades older than the current candle")
+	}
+	last := builder.Flush()
+	if last == nil || !last.Time.Equal(time.Unix(1688670300, 0)) || last.Count != 1 || last.Vwap.String() != "101" {
+		t.Errorf("Flush() should return the open candle, got %+v", last)
+	}
+	if builder.Flush() != nil {
+		t.Error("Flush() should return nil without new trades")
+	}
+}
+
+func TestBuildOHLC(t *testing.T) {
+	api := newTestAPI(testTrades)
+	candles, err := api.BuildOHLC(context.Background(), "XBTUSD", "1", time.Unix(1688669590, 0), time.Time{})
+	if err != nil {
+		t.Fatalf("BuildOHLC() should not return an error, got %s", err)
+	}
+	if len(candles) != 2 {
+		t.Fatalf("BuildOHLC() should return 2 candles, got %d", len(candles))
+	}
+	if !candles[0].Time.Equal(time.Unix(1688669580, 0)) || candles[0].Count != 2 || candles[0].Close.String() != "30243.3" {
+		t.Errorf("BuildOHLC() first candle is wrong, got %+v", candles[0])
+	}
+	if !candles[1].Time.Equal(time.Unix(1688669700, 0)) || candles[1].Volume.String() != "0.5" {
+		t.Errorf("BuildOHLC() second candle is wrong, got %+v", candles[1])
+	}
+}
+
+func TestOHLCSince(t *testing.T) {
+	api := newTestAPI(`{"error":[],"result":{"XXBTZUSD":[[1688671200,"30306.1","30306.2","30305.7","30305.7","30306.1","3.39243896",23]],"last":1688671200}}`)
+	resp, err := api.OHLCSince("XBTUSD", "60", 1688667600)
+	if err != nil {
+		t.Fatalf("OHLCSince() should not return an error, got %s", err)
+	}
+	if len(resp.OHLC) != 1 || resp.Last != 1688671200 {
+		t.Errorf("OHLCSince() decoded wrong values, got %+v", resp)
+	}
+	if _, err := api.OHLCSince("XBTUSD", "3", 0); err == nil {
+		t.Error("OHLCSince() should reject unsupported intervals")
+	}
+}
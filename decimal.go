@@ -0,0 +1,322 @@
+package krakenapi
+
+import (
+	"bytes"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// Decimal is an exact base 10 number used for Kraken's prices, volumes and balances.
+// The zero value is 0.
+type Decimal struct {
+	// unscaled value, nil means zero
+	value *big.Int
+	// number of digits after the decimal point
+	scale int32
+}
+
+var bigTen = big.NewInt(10)
+
+// maxDecimalExponent bounds the exponent NewDecimalFromString accepts, larger ones
+// would make the scaling allocate huge numbers
+const maxDecimalExponent = 1000
+
+// NewDecimal returns the Decimal value * 10^-scale
+func NewDecimal(value int64, scale int32) Decimal {
+	d := Decimal{value: big.NewInt(value), scale: scale}
+	if scale < 0 {
+		return d.rescale(0)
+	}
+	return d
+}
+
+// NewDecimalFromInt returns the Decimal representation of an integer
+func NewDecimalFromInt(value int64) Decimal {
+	return NewDecimal(value, 0)
+}
+
+// NewDecimalFromFloat returns the shortest Decimal that converts back to the given float
+func NewDecimalFromFloat(value float64) Decimal {
+	d, err := NewDecimalFromString(strconv.FormatFloat(value, 'f', -1, 64))
+	if err != nil {
+		// NaN and infinities have no decimal representation
+		return Decimal{}
+	}
+	return d
+}
+
+// NewDecimalFromString parses a decimal number like "-12.3400" or "1e-8"
+func NewDecimalFromString(input string) (Decimal, error) {
+	mantissa, exp := input, 0
+	if i := strings.IndexAny(input, "eE"); i >= 0 {
+		e, err := strconv.Atoi(input[i+1:])
+		if err != nil {
+			return Decimal{}, fmt.Errorf("invalid decimal %q", input)
+		}
+		if e > maxDecimalExponent || e < -maxDecimalExponent {
+			return Decimal{}, fmt.Errorf("exponent of decimal %q out of range", input)
+		}
+		mantissa, exp = input[:i], e
+	}
+
+	intPart, fracPart := mantissa, ""
+	if i := strings.IndexByte(mantissa, '.'); i >= 0 {
+		intPart, fracPart = mantissa[:i], mantissa[i+1:]
+	}
+	if strings.ContainsAny(fracPart, "+-") {
+		return Decimal{}, fmt.Errorf("invalid decimal %q", input)
+	}
+
+	value, ok := new(big.Int).SetString(intPart+fracPart, 10)
+	if !ok {
+		return Decimal{}, fmt.Errorf("invalid decimal %q", input)
+	}
+
+	d := Decimal{value: value, scale: int32(len(fracPart) - exp)}
+	if d.scale < 0 {
+		return d.rescale(0), nil
+	}
+	return d, nil
+}
+
+// MustDecimal is like NewDecimalFromString but panics on invalid input.
+// It is meant for constants in code and tests.
+func MustDecimal(input string) Decimal {
+	d, err := NewDecimalFromString(input)
+	if err != nil {
+		panic(err)
+	}
+	return d
+}
+
+// int returns the unscaled value, never nil
+func (d Decimal) int() *big.Int {
+	if d.value == nil {
+		return new(big.Int)
+	}
+	return d.value
+}
+
+// rescale returns d with the given scale, which must not lose digits
+func (d Decimal) rescale(scale int32) Decimal {
+	value := new(big.Int).Set(d.int())
+	if scale > d.scale {
+		value.Mul(value, pow10(scale-d.scale))
+	} else if scale < d.scale {
+		value.Quo(value, pow10(d.scale-scale))
+	}
+	return Decimal{value: value, scale: scale}
+}
+
+// align returns a and b with a common scale
+func align(a, b Decimal) (Decimal, Decimal) {
+	if a.scale > b.scale {
+		return a, b.rescale(a.scale)
+	}
+	return a.rescale(b.scale), b
+}
+
+func pow10(n int32) *big.Int {
+	return new(big.Int).Exp(bigTen, big.NewInt(int64(n)), nil)
+}
+
+// Add returns d + other
+func (d Decimal) Add(other Decimal) Decimal {
+	a, b := align(d, other)
+	return Decimal{value: new(big.Int).Add(a.int(), b.int()), scale: a.scale}
+}
+
+// Sub returns d - other
+func (d Decimal) Sub(other Decimal) Decimal {
+	a, b := align(d, other)
+	return Decimal{value: new(big.Int).Sub(a.int(), b.int()), scale: a.scale}
+}
+
+// Mul returns d * other
+func (d Decimal) Mul(other Decimal) Decimal {
+	return Decimal{value: new(big.Int).Mul(d.int(), other.int()), scale: d.scale + other.scale}
+}
+
+// Div returns d / other rounded half away from zero to the given decimal places.
+// It panics if other is zero.
+func (d Decimal) Div(other Decimal, places int32) Decimal {
+	if other.IsZero() {
+		panic("krakenapi: division by zero")
+	}
+
+	// Compute with one extra digit and let Round handle the last one
+	num, den := new(big.Int).Set(d.int()), new(big.Int).Set(other.int())
+	if shift := places + 1 + other.scale - d.scale; shift >= 0 {
+		num.Mul(num, pow10(shift))
+	} else {
+		den.Mul(den, pow10(-shift))
+	}
+	return Decimal{value: num.Quo(num, den), scale: places + 1}.Round(places)
+}
+
+// Neg returns -d
+func (d Decimal) Neg() Decimal {
+	return Decimal{value: new(big.Int).Neg(d.int()), scale: d.scale}
+}
+
+// Abs returns |d|
+func (d Decimal) Abs() Decimal {
+	return Decimal{value: new(big.Int).Abs(d.int()), scale: d.scale}
+}
+
+// Round rounds d half away from zero to the given decimal places
+func (d Decimal) Round(places int32) Decimal {
+	if d.scale <= places {
+		return d
+	}
+
+	divisor := pow10(d.scale - places)
+	q, r := new(big.Int).QuoRem(d.int(), divisor, new(big.Int))
+	if r.Abs(r).Lsh(r, 1).Cmp(divisor) >= 0 {
+		q.Add(q, big.NewInt(int64(d.Sign())))
+	}
+	return Decimal{value: q, scale: places}
+}
+
+// Truncate drops all digits after the given decimal places
+func (d Decimal) Truncate(places int32) Decimal {
+	if d.scale <= places {
+		return d
+	}
+	return d.rescale(places)
+}
+
+// Floor rounds d towards negative infinity to the given decimal places
+func (d Decimal) Floor(places int32) Decimal {
+	t := d.Truncate(places)
+	if t.Cmp(d) > 0 {
+		return t.Sub(NewDecimal(1, places))
+	}
+	return t
+}
+
+// Ceil rounds d towards positive infinity to the given decimal places
+func (d Decimal) Ceil(places int32) Decimal {
+	t := d.Truncate(places)
+	if t.Cmp(d) < 0 {
+		return t.Add(NewDecimal(1, places))
+	}
+	return t
+}
+
+// Places returns the number of significant digits after the decimal point
+func (d Decimal) Places() int32 {
+	if d.IsZero() {
+		return 0
+	}
+
+	value, scale := new(big.Int).Set(d.value), d.scale
+	r := new(big.Int)
+	for scale > 0 {
+		q, _ := new(big.Int).QuoRem(value, bigTen, r)
+		if r.Sign() != 0 {
+			break
+		}
+		value, scale = q, scale-1
+	}
+	return scale
+}
+
+// Cmp returns -1, 0 or +1 if d is less than, equal to or greater than other
+func (d Decimal) Cmp(other Decimal) int {
+	a, b := align(d, other)
+	return a.int().Cmp(b.int())
+}
+
+// Equal reports whether d == other
+func (d Decimal) Equal(other Decimal) bool {
+	return d.Cmp(other) == 0
+}
+
+// LessThan reports whether d < other
+func (d Decimal) LessThan(other Decimal) bool {
+	return d.Cmp(other) < 0
+}
+
+// GreaterThan reports whether d > other
+func (d Decimal) GreaterThan(other Decimal) bool {
+	return d.Cmp(other) > 0
+}
+
+// Sign returns -1, 0 or +1 depending on the sign of d
+func (d Decimal) Sign() int {
+	return d.int().Sign()
+}
+
+// IsZero reports whether d == 0
+func (d Decimal) IsZero() bool {
+	return d.Sign() == 0
+}
+
+// Float64 returns the nearest float64 to d
+func (d Decimal) Float64() float64 {
+	f, _ := strconv.ParseFloat(d.String(), 64)
+	return f
+}
+
+// String returns d without trailing zeros, e.g. "-12.34"
+func (d Decimal) String() string {
+	return d.StringFixed(d.Places())
+}
+
+// StringFixed returns d rounded to exactly the given decimal places, e.g. "-12.3400"
+func (d Decimal) StringFixed(places int32) string {
+	if places < 0 {
+		places = 0
+	}
+	r := d.Round(places).rescale(places)
+
+	digits := new(big.Int).Abs(r.int()).String()
+	if len(digits) <= int(places) {
+		digits = strings.Repeat("0", int(places)-len(digits)+1) + digits
+	}
+
+	sign := ""
+	if r.Sign() < 0 {
+		sign = "-"
+	}
+	if places == 0 {
+		return sign + digits
+	}
+	point := len(digits) - int(places)
+	return sign + digits[:point] + "." + digits[point:]
+}
+
+// MarshalJSON encodes d as a JSON string, the way Kraken sends numbers
+func (d Decimal) MarshalJSON() ([]byte, error) {
+	return []byte(strconv.Quote(d.String())), nil
+}
+
+// UnmarshalJSON accepts Kraken's quoted numbers as well as plain JSON numbers
+func (d *Decimal) UnmarshalJSON(data []byte) error {
+	if bytes.Equal(data, []byte("null")) {
+		return nil
+	}
+
+	input := string(data)
+	if len(data) > 1 && data[0] == '"' {
+		unquoted, err := strconv.Unquote(input)
+		if err != nil {
+			return fmt.Errorf("invalid decimal %s", input)
+		}
+		input = unquoted
+	}
+	if input == "" {
+		*d = Decimal{}
+		return nil
+	}
+
+	parsed, err := NewDecimalFromString(input)
+	if err != nil {
+		return err
+	}
+	*d = parsed
+	return nil
+}
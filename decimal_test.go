@@ -0,0 +1,133 @@
+package krakenapi
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestNewDecimalFromString(t *testing.T) {
+	cases := map[string]string{
+		"0":            "0",
+		"1.50000000":   "1.5",
+		"-12.340":      "-12.34",
+		".5":           "0.5",
+		"-0.0001":      "-0.0001",
+		"1e-8":         "0.00000001",
+		"2.5E3":        "2500",
+		"0.1000000000": "0.1",
+	}
+
+	for input, expected := range cases {
+		d, err := NewDecimalFromString(input)
+		if err != nil {
+			t.Errorf("NewDecimalFromString(%q) should not return an error, got %s", input, err)
+			continue
+		}
+		if d.String() != expected {
+			t.Errorf("NewDecimalFromString(%q) should be %s, got %s", input, expected, d)
+		}
+	}
+
+	for _, input := range []string{"", "abc", "1.2.3", "1.-2", "-", "1e", "1e999999999", "1e-999999999", "1e99999999999999999999"} {
+		if _, err := NewDecimalFromString(input); err == nil {
+			t.Errorf("NewDecimalFromString(%q) should return an error", input)
+		}
+	}
+
+	var d Decimal
+	if err := json.Unmarshal([]byte(`"1e999999999"`), &d); err == nil {
+		t.Error("UnmarshalJSON() should reject huge exponents")
+	}
+}
+
+func TestDecimalArithmetic(t *testing.T) {
+	a := MustDecimal("0.1")
+	b := MustDecimal("0.2")
+
+	if sum := a.Add(b); !sum.Equal(MustDecimal("0.3")) {
+		t.Errorf("0.1 + 0.2 should be exactly 0.3, got %s", sum)
+	}
+	if diff := a.Sub(b); diff.String() != "-0.1" {
+		t.Errorf("0.1 - 0.2 should be -0.1, got %s", diff)
+	}
+	if prod := MustDecimal("1.5").Mul(MustDecimal("-2.25")); prod.String() != "-3.375" {
+		t.Errorf("1.5 * -2.25 should be -3.375, got %s", prod)
+	}
+	if quo := NewDecimalFromInt(2).Div(NewDecimalFromInt(3), 4); quo.String() != "0.6667" {
+		t.Errorf("2 / 3 should be 0.6667, got %s", quo)
+	}
+	if quo := MustDecimal("-1").Div(MustDecimal("0.08"), 1); quo.String() != "-12.5" {
+		t.Errorf("-1 / 0.08 should be -12.5, got %s", quo)
+	}
+	if !a.LessThan(b) || !b.GreaterThan(a) || a.Cmp(a) != 0 {
+		t.Errorf("Comparison of %s and %s is wrong", a, b)
+	}
+
+	var zero Decimal
+	if !zero.IsZero() || zero.Add(a).String() != "0.1" {
+		t.Errorf("The zero value should be usable as 0")
+	}
+}
+
+func TestDecimalRounding(t *testing.T) {
+	d := MustDecimal("-2.345")
+
+	if r := d.Round(2); r.String() != "-2.35" {
+		t.Errorf("Round(2) should round half away from zero, got %s", r)
+	}
+	if r := d.Truncate(1); r.String() != "-2.3" {
+		t.Errorf("Truncate(1) should drop digits, got %s", r)
+	}
+	if r := d.Floor(1); r.String() != "-2.4" {
+		t.Errorf("Floor(1) should round down, got %s", r)
+	}
+	if r := d.Ceil(1); r.String() != "-2.3" {
+		t.Errorf("Ceil(1) should round up, got %s", r)
+	}
+	if s := MustDecimal("1.5").StringFixed(4); s != "1.5000" {
+		t.Errorf("StringFixed(4) should pad zeros, got %s", s)
+	}
+	if s := MustDecimal("0.004").StringFixed(2); s != "0.00" {
+		t.Errorf("StringFixed(2) should round, got %s", s)
+	}
+	if p := MustDecimal("12.34000").Places(); p != 2 {
+		t.Errorf("Places() should ignore trailing zeros, got %d", p)
+	}
+}
+
+func TestDecimalJSON(t *testing.T) {
+	var v struct {
+		Quoted Decimal `json:"quoted"`
+		Plain  Decimal `json:"plain"`
+		Empty  Decimal `json:"empty"`
+		Null   Decimal `json:"null"`
+	}
+
+	err := json.Unmarshal([]byte(`{"quoted":"37000.10000","plain":0.25,"empty":"","null":null}`), &v)
+	if err != nil {
+		t.Fatalf("Decimal should unmarshal, got %s", err)
+	}
+	if v.Quoted.String() != "37000.1" || v.Plain.String() != "0.25" || !v.Empty.IsZero() || !v.Null.IsZero() {
+		t.Errorf("Decimal unmarshalled wrong values, got %+v", v)
+	}
+
+	data, err := json.Marshal(v.Quoted)
+	if err != nil || string(data) != `"37000.1"` {
+		t.Errorf("Decimal should marshal to a JSON string, got %s (%v)", data, err)
+	}
+
+	if err := json.Unmarshal([]byte(`{"quoted":"1,5"}`), &v); err == nil {
+		t.Errorf("Decimal should reject invalid numbers")
+	}
+}
+
+func TestAssetPairInfoRounding(t *testing.T) {
+	pair := AssetPairInfo{PairDecimals: 1, LotDecimals: 8}
+
+	if p := pair.RoundPrice(MustDecimal("37000.15")); p.String() != "37000.2" {
+		t.Errorf("RoundPrice() should round to pair decimals, got %s", p)
+	}
+	if v := pair.RoundVolume(MustDecimal("0.123456789")); v.String() != "0.12345679" {
+		t.Errorf("RoundVolume() should round to lot decimals, got %s", v)
+	}
+}
@@ -0,0 +1,161 @@
+package krakenapi
+
+import (
+	"math"
+	"strings"
+	"time"
+)
+
+// OrderStatus is the state of an order
+type OrderStatus string
+
+// Order states
+const (
+	OrderStatusPending  OrderStatus = "pending"
+	OrderStatusOpen     OrderStatus = "open"
+	OrderStatusClosed   OrderStatus = "closed"
+	OrderStatusCanceled OrderStatus = "canceled"
+	OrderStatusExpired  OrderStatus = "expired"
+)
+
+// IsKnown reports whether s is one of the documented order states
+func (s OrderStatus) IsKnown() bool {
+	switch s {
+	case OrderStatusPending, OrderStatusOpen, OrderStatusClosed, OrderStatusCanceled, OrderStatusExpired:
+		return true
+	}
+	return false
+}
+
+// IsFinal reports whether an order in state s can no longer change
+func (s OrderStatus) IsFinal() bool {
+	return s == OrderStatusClosed || s == OrderStatusCanceled || s == OrderStatusExpired
+}
+
+// Side is the direction of an order or trade
+type Side string
+
+// Order and trade directions
+const (
+	SideBuy  Side = "buy"
+	SideSell Side = "sell"
+)
+
+// IsKnown reports whether s is buy or sell
+func (s Side) IsKnown() bool {
+	return s == SideBuy || s == SideSell
+}
+
+// OrderType is the type of an order, see the OT constants for known values
+type OrderType string
+
+// IsKnown reports whether t is one of the OT constants
+func (t OrderType) IsKnown() bool {
+	switch t {
+	case OTMarket, OTLimit, OTStopLoss, OTTakeProfi, OTStopLossProfit, OTStopLossProfitLimit,
+		OTStopLossLimit, OTTakeProfitLimit, OTTrailingStop, OTTrailingStopLimit,
+		OTStopLossAndLimit, OTSettlePosition:
+		return true
+	}
+	return false
+}
+
+// LedgerType is the type of a ledger entry
+type LedgerType string
+
+// Ledger entry types
+const (
+	LedgerTypeTrade      LedgerType = "trade"
+	LedgerTypeDeposit    LedgerType = "deposit"
+	LedgerTypeWithdrawal LedgerType = "withdrawal"
+	LedgerTypeTransfer   LedgerType = "transfer"
+	LedgerTypeMargin     LedgerType = "margin"
+	LedgerTypeRollover   LedgerType = "rollover"
+	LedgerTypeSpend      LedgerType = "spend"
+	LedgerTypeReceive    LedgerType = "receive"
+	LedgerTypeSettled    LedgerType = "settled"
+	LedgerTypeAdjustment LedgerType = "adjustment"
+	LedgerTypeStaking    LedgerType = "staking"
+	LedgerTypeSale       LedgerType = "sale"
+	LedgerTypeCredit     LedgerType = "credit"
+)
+
+// IsKnown reports whether t is one of the documented ledger types
+func (t LedgerType) IsKnown() bool {
+	switch t {
+	case LedgerTypeTrade, LedgerTypeDeposit, LedgerTypeWithdrawal, LedgerTypeTransfer,
+		LedgerTypeMargin, LedgerTypeRollover, LedgerTypeSpend, LedgerTypeReceive,
+		LedgerTypeSettled, LedgerTypeAdjustment, LedgerTypeStaking, LedgerTypeSale, LedgerTypeCredit:
+		return true
+	}
+	return false
+}
+
+// TransferStatus is the state of a transfer between accounts
+type TransferStatus string
+
+// Transfer states returned by AccountTransfer
+const (
+	TransferStatusPending  TransferStatus = "pending"
+	TransferStatusComplete TransferStatus = "complete"
+)
+
+// IsKnown reports whether s is one of the documented transfer states
+func (s TransferStatus) IsKnown() bool {
+	return s == TransferStatusPending || s == TransferStatusComplete
+}
+
+// MiscFlag is one entry of the comma delimited misc field of orders and trades
+type MiscFlag string
+
+// Misc flags of trades
+const (
+	// Trade closes all or part of a position
+	MiscClosing MiscFlag = "closing"
+	// Trade was initiated by Kraken, e.g. a liquidation
+	MiscInitiated MiscFlag = "initiated"
+)
+
+// Misc flags of orders
+const (
+	// Triggered by stop price
+	MiscStopped MiscFlag = "stopped"
+	// Triggered by touch price
+	MiscTouched MiscFlag = "touched"
+	// Liquidation
+	MiscLiquidated MiscFlag = "liquidated"
+	// Partial fill
+	MiscPartial MiscFlag = "partial"
+)
+
+// ParseMiscFlags splits a comma delimited misc field. Unknown flags are kept as they are.
+func ParseMiscFlags(misc string) []MiscFlag {
+	var flags []MiscFlag
+	for _, flag := range strings.Split(misc, ",") {
+		if flag = strings.TrimSpace(flag); flag != "" {
+			flags = append(flags, MiscFlag(flag))
+		}
+	}
+	return flags
+}
+
+// hasMiscFlag reports whether the comma delimited misc field contains flag
+func hasMiscFlag(misc string, flag MiscFlag) bool {
+	for _, f := range ParseMiscFlags(misc) {
+		if f == flag {
+			return true
+		}
+	}
+	return false
+}
+
+// floatTime converts Kraken's fractional unix timestamps to time.Time.
+// Kraken sends at most microsecond precision, anything below is float noise.
+// A zero timestamp returns the zero time.
+func floatTime(ts float64) time.Time {
+	if ts == 0 {
+		return time.Time{}
+	}
+	sec, frac := math.Modf(ts)
+	return time.Unix(int64(sec), int64(math.Round(frac*1e6))*1e3)
+}
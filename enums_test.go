@@ -0,0 +1,51 @@
+package krakenapi
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestOrderDecoding(t *testing.T) {
+	var order Order
+	err := json.Unmarshal([]byte(`{"status":"canceled","opentm":1688665496.7808,"closetm":1688665499.1922,"expiretm":0,"misc":"stopped,partial","descr":{"type":"sell","ordertype":"limit"}}`), &order)
+	if err != nil {
+		t.Fatalf("Order should unmarshal, got %s", err)
+	}
+
+	if order.Status != OrderStatusCanceled || !order.Status.IsFinal() {
+		t.Errorf("Order status should be canceled, got %s", order.Status)
+	}
+	if order.Description.Type != SideSell || order.Description.OrderType != OTLimit {
+		t.Errorf("Order description was decoded wrong, got %+v", order.Description)
+	}
+	if opened := order.OpenedAt(); !opened.Equal(time.Unix(1688665496, 780800000)) {
+		t.Errorf("OpenedAt() should keep sub-second precision, got %s", opened.Format(time.RFC3339Nano))
+	}
+	if !order.ExpiresAt().IsZero() {
+		t.Errorf("ExpiresAt() should be zero without expiration, got %s", order.ExpiresAt())
+	}
+	if flags := order.MiscFlags(); len(flags) != 2 || flags[0] != MiscStopped || flags[1] != MiscPartial {
+		t.Errorf("MiscFlags() should split the misc field, got %v", flags)
+	}
+}
+
+func TestEnumsTolerateUnknownValues(t *testing.T) {
+	var entry LedgerInfo
+	if err := json.Unmarshal([]byte(`{"type":"nftairdrop"}`), &entry); err != nil {
+		t.Fatalf("LedgerInfo should unmarshal unknown types, got %s", err)
+	}
+	if entry.Type.IsKnown() || entry.Type != "nftairdrop" {
+		t.Errorf("Unknown ledger types should be kept, got %s", entry.Type)
+	}
+
+	if OrderStatus("paused").IsKnown() || !OrderStatusOpen.IsKnown() {
+		t.Errorf("OrderStatus.IsKnown() is wrong")
+	}
+	if !OrderType(OTStopLossLimit).IsKnown() || OrderType("iceberg").IsKnown() {
+		t.Errorf("OrderType.IsKnown() is wrong")
+	}
+	if TransferStatus("failed").IsKnown() || !TransferStatusPending.IsKnown() {
+		t.Errorf("TransferStatus is wrong")
+	}
+}
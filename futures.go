@@ -0,0 +1,315 @@
+package krakenapi
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	// FuturesAPIURL is the official Kraken Futures API Endpoint
+	FuturesAPIURL = "https://futures.kraken.com"
+	// FuturesDemoAPIURL is the Kraken Futures demo environment
+	FuturesDemoAPIURL = "https://demo-futures.kraken.com"
+	// futuresAPIPath is the path of the v3 API, the part after /derivatives is signed
+	futuresAPIPath = "/api/v3/"
+)
+
+// FuturesAPI represents a Kraken Futures API Client connection.
+// Futures use their own API keys, spot keys are not accepted.
+type FuturesAPI struct {
+	key     string
+	secret  string
+	baseURL string
+	client  *http.Client
+	limiter RateLimiter
+}
+
+// NewFutures creates a new Kraken Futures API client
+func NewFutures(key, secret string) *FuturesAPI {
+	return &FuturesAPI{
+		key:     key,
+		secret:  secret,
+		baseURL: FuturesAPIURL,
+		client:  http.DefaultClient,
+	}
+}
+
+// WithClient adds an HTTP client into the FuturesAPI
+func (api *FuturesAPI) WithClient(httpClient *http.Client) *FuturesAPI {
+	api.client = httpClient
+	return api
+}
+
+// WithBaseURL changes the API endpoint, e.g. to FuturesDemoAPIURL
+func (api *FuturesAPI) WithBaseURL(baseURL string) *FuturesAPI {
+	api.baseURL = strings.TrimSuffix(baseURL, "/")
+	return api
+}
+
+// WithRateLimiter makes the FuturesAPI wait for the RateLimiter before every request,
+// the method passed to it is the endpoint, e.g. "sendorder"
+func (api *FuturesAPI) WithRateLimiter(limiter RateLimiter) *FuturesAPI {
+	api.limiter = limiter
+	return api
+}
+
+// Instruments returns all tradable contracts and indices
+func (api *FuturesAPI) Instruments(ctx context.Context) ([]FuturesInstrument, error) {
+	resp := &struct {
+		Instruments []FuturesInstrument `json:"instruments"`
+	}{}
+	if err := api.queryPublic(ctx, "instruments", nil, resp); err != nil {
+		return nil, err
+	}
+	return resp.Instruments, nil
+}
+
+// Tickers returns the tickers of all contracts and indices
+func (api *FuturesAPI) Tickers(ctx context.Context) ([]FuturesTicker, error) {
+	resp := &struct {
+		Tickers []FuturesTicker `json:"tickers"`
+	}{}
+	if err := api.queryPublic(ctx, "tickers", nil, resp); err != nil {
+		return nil, err
+	}
+	return resp.Tickers, nil
+}
+
+// Ticker returns the ticker of one contract or index, e.g. "PF_XBTUSD"
+func (api *FuturesAPI) Ticker(ctx context.Context, symbol string) (*FuturesTicker, error) {
+	resp := &struct {
+		Ticker FuturesTicker `json:"ticker"`
+	}{}
+	if err := api.queryPublic(ctx, "tickers/"+url.PathEscape(symbol), nil, resp); err != nil {
+		return nil, err
+	}
+	return &resp.Ticker, nil
+}
+
+// OrderBook returns the full order book of a contract, bids are sorted from the best price
+func (api *FuturesAPI) OrderBook(ctx context.Context, symbol string) (*OrderBook, error) {
+	resp := &struct {
+		OrderBook OrderBook `json:"orderBook"`
+	}{}
+	if err := api.queryPublic(ctx, "orderbook", url.Values{"symbol": {symbol}}, resp); err != nil {
+		return nil, err
+	}
+	return &resp.OrderBook, nil
+}
+
+// Accounts returns the cash, margin and multi-collateral accounts by name, e.g. "flex"
+func (api *FuturesAPI) Accounts(ctx context.Context) (map[string]FuturesAccount, error) {
+	resp := &struct {
+		Accounts map[string]FuturesAccount `json:"accounts"`
+	}{}
+	if err := api.queryPrivate(ctx, http.MethodGet, "accounts", nil, resp); err != nil {
+		return nil, err
+	}
+	return resp.Accounts, nil
+}
+
+// OpenPositions returns all open positions
+func (api *FuturesAPI) OpenPositions(ctx context.Context) ([]FuturesPosition, error) {
+	resp := &struct {
+		OpenPositions []FuturesPosition `json:"openPositions"`
+	}{}
+	if err := api.queryPrivate(ctx, http.MethodGet, "openpositions", nil, resp); err != nil {
+		return nil, err
+	}
+	return resp.OpenPositions, nil
+}
+
+// OpenOrders returns all open orders
+func (api *FuturesAPI) OpenOrders(ctx context.Context) ([]FuturesOrder, error) {
+	resp := &struct {
+		OpenOrders []FuturesOrder `json:"openOrders"`
+	}{}
+	if err := api.queryPrivate(ctx, http.MethodGet, "openorders", nil, resp); err != nil {
+		return nil, err
+	}
+	return resp.OpenOrders, nil
+}
+
+// SendOrder validates and places an order. Kraken answers orders it refuses,
+// e.g. for insufficient funds, with a status instead of an error.
+func (api *FuturesAPI) SendOrder(ctx context.Context, req *FuturesOrderRequest) (*FuturesOrderStatus, error) {
+	values, err := req.Values()
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &struct {
+		SendStatus FuturesOrderStatus `json:"sendStatus"`
+	}{}
+	if err := api.queryPrivate(ctx, http.MethodPost, "sendorder", values, resp); err != nil {
+		return nil, err
+	}
+	return &resp.SendStatus, nil
+}
+
+// EditOrder changes the size and prices of an open order
+func (api *FuturesAPI) EditOrder(ctx context.Context, req *FuturesEditRequest) (*FuturesOrderStatus, error) {
+	values, err := req.Values()
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &struct {
+		EditStatus FuturesOrderStatus `json:"editStatus"`
+	}{}
+	if err := api.queryPrivate(ctx, http.MethodPost, "editorder", values, resp); err != nil {
+		return nil, err
+	}
+	return &resp.EditStatus, nil
+}
+
+// CancelOrder cancels an open order by its ID
+func (api *FuturesAPI) CancelOrder(ctx context.Context, orderID string) (*FuturesOrderStatus, error) {
+	return api.cancelOrder(ctx, url.Values{"order_id": {orderID}})
+}
+
+// CancelOrderByClientID cancels an open order by the client order ID it was placed with
+func (api *FuturesAPI) CancelOrderByClientID(ctx context.Context, clientOrderID string) (*FuturesOrderStatus, error) {
+	return api.cancelOrder(ctx, url.Values{"cliOrdId": {clientOrderID}})
+}
+
+func (api *FuturesAPI) cancelOrder(ctx context.Context, values url.Values) (*FuturesOrderStatus, error) {
+	resp := &struct {
+		CancelStatus FuturesOrderStatus `json:"cancelStatus"`
+	}{}
+	if err := api.queryPrivate(ctx, http.MethodPost, "cancelorder", values, resp); err != nil {
+		return nil, err
+	}
+	return &resp.CancelStatus, nil
+}
+
+// BatchOrder sends, edits and cancels several orders in one request.
+// The statuses are matched to the instructions by OrderTag for sent orders and OrderID otherwise.
+func (api *FuturesAPI) BatchOrder(ctx context.Context, instructions ...FuturesBatchInstruction) ([]FuturesOrderStatus, error) {
+	batch := make([]map[string]interface{}, len(instructions))
+	for i, instruction := range instructions {
+		var err error
+		if batch[i], err = instruction.params(); err != nil {
+			return nil, fmt.Errorf("invalid batch instruction %d: %s", i, err)
+		}
+	}
+	data, err := json.Marshal(map[string]interface{}{"batchOrder": batch})
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &struct {
+		BatchStatus []FuturesOrderStatus `json:"batchStatus"`
+	}{}
+	if err := api.queryPrivate(ctx, http.MethodPost, "batchorder", url.Values{"json": {string(data)}}, resp); err != nil {
+		return nil, err
+	}
+	return resp.BatchStatus, nil
+}
+
+// Fills returns the last 100 fills, or the 100 fills before lastFillTime if it is not zero
+func (api *FuturesAPI) Fills(ctx context.Context, lastFillTime time.Time) ([]FuturesFill, error) {
+	values := url.Values{}
+	if !lastFillTime.IsZero() {
+		values.Set("lastFillTime", lastFillTime.UTC().Format("2006-01-02T15:04:05.000Z"))
+	}
+
+	resp := &struct {
+		Fills []FuturesFill `json:"fills"`
+	}{}
+	if err := api.queryPrivate(ctx, http.MethodGet, "fills", values, resp); err != nil {
+		return nil, err
+	}
+	return resp.Fills, nil
+}
+
+// queryPublic executes a public GET request of endpoint
+func (api *FuturesAPI) queryPublic(ctx context.Context, endpoint string, values url.Values, typ interface{}) error {
+	return api.query(ctx, http.MethodGet, endpoint, values, nil, typ)
+}
+
+// queryPrivate executes a signed request of endpoint
+func (api *FuturesAPI) queryPrivate(ctx context.Context, method string, endpoint string, values url.Values, typ interface{}) error {
+	secret, _ := base64.StdEncoding.DecodeString(api.secret)
+	nonce := fmt.Sprintf("%d", time.Now().UnixNano())
+
+	headers := map[string]string{
+		"APIKey":  api.key,
+		"Nonce":   nonce,
+		"Authent": createFuturesSignature(futuresAPIPath+endpoint, values.Encode(), nonce, secret),
+	}
+	return api.query(ctx, method, endpoint, values, headers, typ)
+}
+
+// query executes a request of endpoint and decodes the response into typ
+func (api *FuturesAPI) query(ctx context.Context, method string, endpoint string, values url.Values, headers map[string]string, typ interface{}) error {
+	body, err := api.send(ctx, method, "/derivatives"+futuresAPIPath+endpoint, endpoint, values, headers)
+	if err != nil {
+		return err
+	}
+	return decodeFuturesResponse(body, typ)
+}
+
+// send executes a request of path, waiting for the RateLimiter with endpoint, and returns the body
+func (api *FuturesAPI) send(ctx context.Context, method string, path string, endpoint string, values url.Values, headers map[string]string) ([]byte, error) {
+	if api.limiter != nil {
+		if err := api.limiter.Wait(ctx, endpoint); err != nil {
+			return nil, err
+		}
+	}
+
+	reqURL := api.baseURL + path
+	var req *http.Request
+	var err error
+	if method == http.MethodGet {
+		if encoded := values.Encode(); encoded != "" {
+			reqURL += "?" + encoded
+		}
+		req, err = http.NewRequestWithContext(ctx, method, reqURL, nil)
+	} else {
+		req, err = http.NewRequestWithContext(ctx, method, reqURL, strings.NewReader(values.Encode()))
+		if err == nil {
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Could not execute request! #1 (%s)", err.Error())
+	}
+
+	return doHTTPRequest(api.client, req, headers)
+}
+
+// decodeFuturesResponse checks the status of a response and decodes it into typ
+func decodeFuturesResponse(body []byte, typ interface{}) error {
+	// Kraken Futures puts the result next to the status instead of wrapping it
+	var status futuresResponse
+	if err := json.Unmarshal(body, &status); err != nil {
+		return fmt.Errorf("Could not execute request! #6 (%s)", err.Error())
+	}
+	if status.Result != "success" {
+		message := status.Error
+		if message == "" && len(status.Errors) > 0 {
+			message = string(status.Errors)
+		}
+		return fmt.Errorf("Could not execute request! #7 (%s)", message)
+	}
+
+	if err := json.Unmarshal(body, typ); err != nil {
+		return fmt.Errorf("Could not execute request! #6 (%s)", err.Error())
+	}
+	return nil
+}
+
+// createFuturesSignature signs a Kraken Futures request.
+// See https://docs.futures.kraken.com/#http-api-http-api-introduction-authentication
+func createFuturesSignature(endpointPath string, postData string, nonce string, secret []byte) string {
+	shaSum := getSha256([]byte(postData + nonce + endpointPath))
+	macSum := getHMacSha512(shaSum, secret)
+	return base64.StdEncoding.EncodeToString(macSum)
+}
@@ -0,0 +1,302 @@
+package krakenapi
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// FuturesTickType selects the prices of futures candles
+type FuturesTickType string
+
+const (
+	// FuturesTickTrade are the prices of executed trades
+	FuturesTickTrade FuturesTickType = "trade"
+	// FuturesTickMark are the mark prices used for margin and PnL
+	FuturesTickMark FuturesTickType = "mark"
+	// FuturesTickSpot are the prices of the underlying index
+	FuturesTickSpot FuturesTickType = "spot"
+)
+
+// futuresResolutions maps the OHLC intervals in minutes to the resolutions of the charts API
+var futuresResolutions = map[string]string{
+	"1":     "1m",
+	"5":     "5m",
+	"15":    "15m",
+	"30":    "30m",
+	"60":    "1h",
+	"240":   "4h",
+	"720":   "12h",
+	"1440":  "1d",
+	"10080": "1w",
+}
+
+// FuturesFundingRate is the funding rate of a perpetual contract for one funding period
+type FuturesFundingRate struct {
+	Time time.Time `json:"timestamp"`
+	// Funding per contract in the currency of the contract
+	FundingRate Decimal `json:"fundingRate"`
+	// Funding relative to the position value, e.g. 0.0001 for 0.01%
+	RelativeFundingRate Decimal `json:"relativeFundingRate"`
+}
+
+// FuturesPrice is a mark price of a contract
+type FuturesPrice struct {
+	Time  time.Time
+	Price Decimal
+}
+
+// FundingRates returns the funding rates of a perpetual contract, e.g. "PF_XBTUSD", after
+// from and up to to, oldest first. A zero from or to does not bound the range.
+// Kraken returns the whole history in one response.
+func (api *FuturesAPI) FundingRates(ctx context.Context, symbol string, from time.Time, to time.Time) ([]FuturesFundingRate, error) {
+	body, err := api.send(ctx, http.MethodGet, "/derivatives/api/v4/historicalfundingrates", "historicalfundingrates", url.Values{"symbol": {symbol}}, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp := &struct {
+		Rates []FuturesFundingRate `json:"rates"`
+	}{}
+	if err := decodeFuturesResponse(body, resp); err != nil {
+		return nil, err
+	}
+
+	var rates []FuturesFundingRate
+	for _, rate := range resp.Rates {
+		if (!from.IsZero() && !rate.Time.After(from)) || (!to.IsZero() && rate.Time.After(to)) {
+			continue
+		}
+		rates = append(rates, rate)
+	}
+	return rates, nil
+}
+
+// FuturesCandlesIterator walks the candles of a contract or index between two times, oldest first
+type FuturesCandlesIterator struct {
+	api      *FuturesAPI
+	ctx      context.Context
+	path     string
+	interval time.Duration
+	from     time.Time
+	to       time.Time
+	buffer   []*OHLC
+	current  *OHLC
+	last     bool
+	err      error
+}
+
+// IterateCandles returns an iterator over the candles of symbol starting from from up to to,
+// tickType selects trade, mark or index prices. interval is given in minutes as for OHLC,
+// supported are 1, 5, 15, 30, 60, 240, 720, 1440 and 10080. from is required, a zero to
+// walks up to the open candle. Only trade candles have a volume, none has a vwap or count.
+func (api *FuturesAPI) IterateCandles(ctx context.Context, tickType FuturesTickType, symbol string, interval string, from time.Time, to time.Time) *FuturesCandlesIterator {
+	it := &FuturesCandlesIterator{api: api, ctx: ctx, from: from, to: to}
+	if from.IsZero() {
+		it.err = fmt.Errorf("IterateCandles needs a start time")
+		return it
+	}
+	resolution, ok := futuresResolutions[interval]
+	if !ok {
+		it.err = fmt.Errorf("Unsupported value for Interval: %s", interval)
+		return it
+	}
+	minutes, _ := strconv.Atoi(interval)
+	it.interval = time.Duration(minutes) * time.Minute
+	it.path = "/api/charts/v1/" + string(tickType) + "/" + url.PathEscape(symbol) + "/" + resolution
+	return it
+}
+
+// Next advances to the next candle, it returns false at the end or on error
+func (it *FuturesCandlesIterator) Next() bool {
+	for len(it.buffer) == 0 {
+		if it.last || it.err != nil {
+			return false
+		}
+		if err := it.load(); err != nil {
+			it.err = err
+			return false
+		}
+	}
+
+	it.current, it.buffer = it.buffer[0], it.buffer[1:]
+	return true
+}
+
+// load fetches the candles from the cursor on
+func (it *FuturesCandlesIterator) load() error {
+	values := url.Values{"from": {strconv.FormatInt(it.from.Unix(), 10)}}
+	if !it.to.IsZero() {
+		values.Set("to", strconv.FormatInt(it.to.Unix(), 10))
+	}
+	body, err := it.api.send(it.ctx, http.MethodGet, it.path, "charts", values, nil)
+	if err != nil {
+		return err
+	}
+	resp := &struct {
+		Candles []struct {
+			Time   int64   `json:"time"`
+			Open   Decimal `json:"open"`
+			High   Decimal `json:"high"`
+			Low    Decimal `json:"low"`
+			Close  Decimal `json:"close"`
+			Volume Decimal `json:"volume"`
+		} `json:"candles"`
+		MoreCandles bool `json:"more_candles"`
+	}{}
+	if err := decodeJSON(body, resp); err != nil {
+		return err
+	}
+
+	var newest time.Time
+	for _, candle := range resp.Candles {
+		start := futuresTime(candle.Time)
+		if !it.to.IsZero() && start.After(it.to) {
+			it.last = true
+			break
+		}
+		if start.After(newest) {
+			newest = start
+		}
+		// The page boundary may repeat the last candle
+		if start.Before(it.from) {
+			continue
+		}
+		it.buffer = append(it.buffer, &OHLC{
+			Time:   start,
+			Open:   candle.Open,
+			High:   candle.High,
+			Low:    candle.Low,
+			Close:  candle.Close,
+			Volume: candle.Volume,
+		})
+	}
+
+	// Continue after the newest candle of the page, even if all of its candles were
+	// filtered out. A page which does not advance the cursor ends the iteration.
+	next := newest.Add(it.interval)
+	if !resp.MoreCandles || !next.After(it.from) {
+		it.last = true
+	} else {
+		it.from = next
+	}
+	return nil
+}
+
+// Candle returns the current candle
+func (it *FuturesCandlesIterator) Candle() *OHLC {
+	return it.current
+}
+
+// Err returns the error that stopped the iteration
+func (it *FuturesCandlesIterator) Err() error {
+	return it.err
+}
+
+// FuturesPricesIterator walks the mark prices of a contract between two times, oldest first
+type FuturesPricesIterator struct {
+	api     *FuturesAPI
+	ctx     context.Context
+	path    string
+	from    time.Time
+	to      time.Time
+	token   string
+	buffer  []FuturesPrice
+	current FuturesPrice
+	last    bool
+	err     error
+}
+
+// IteratePrices returns an iterator over every mark price change of symbol after from and
+// before to from the market history. A zero to walks up to now. CandleBuilder.AddPrice
+// aggregates the prices into candles.
+func (api *FuturesAPI) IteratePrices(ctx context.Context, symbol string, from time.Time, to time.Time) *FuturesPricesIterator {
+	return &FuturesPricesIterator{
+		api:  api,
+		ctx:  ctx,
+		path: "/api/history/v3/market/" + url.PathEscape(symbol) + "/price",
+		from: from,
+		to:   to,
+	}
+}
+
+// Next advances to the next price, it returns false at the end or on error
+func (it *FuturesPricesIterator) Next() bool {
+	for len(it.buffer) == 0 {
+		if it.last || it.err != nil {
+			return false
+		}
+		if err := it.load(); err != nil {
+			it.err = err
+			return false
+		}
+	}
+
+	it.current, it.buffer = it.buffer[0], it.buffer[1:]
+	return true
+}
+
+// load fetches the page at the continuation token
+func (it *FuturesPricesIterator) load() error {
+	values := url.Values{"sort": {"asc"}}
+	if !it.from.IsZero() {
+		values.Set("since", strconv.FormatInt(it.from.UnixNano()/int64(time.Millisecond), 10))
+	}
+	if !it.to.IsZero() {
+		values.Set("before", strconv.FormatInt(it.to.UnixNano()/int64(time.Millisecond), 10))
+	}
+	if it.token != "" {
+		values.Set("continuation_token", it.token)
+	}
+	body, err := it.api.send(it.ctx, http.MethodGet, it.path, "history", values, nil)
+	if err != nil {
+		return err
+	}
+	resp := &struct {
+		Elements []struct {
+			Timestamp int64 `json:"timestamp"`
+			Event     struct {
+				Price Decimal `json:"price"`
+			} `json:"event"`
+		} `json:"elements"`
+		ContinuationToken string `json:"continuationToken"`
+	}{}
+	if err := decodeJSON(body, resp); err != nil {
+		return err
+	}
+
+	for _, element := range resp.Elements {
+		price := FuturesPrice{Time: futuresTime(element.Timestamp), Price: element.Event.Price}
+		if !price.Time.After(it.from) || (!it.to.IsZero() && !price.Time.Before(it.to)) {
+			continue
+		}
+		it.buffer = append(it.buffer, price)
+	}
+
+	if resp.ContinuationToken == "" || len(resp.Elements) == 0 {
+		it.last = true
+	}
+	it.token = resp.ContinuationToken
+	return nil
+}
+
+// Price returns the current price
+func (it *FuturesPricesIterator) Price() FuturesPrice {
+	return it.current
+}
+
+// Err returns the error that stopped the iteration
+func (it *FuturesPricesIterator) Err() error {
+	return it.err
+}
+
+// decodeJSON decodes a response without status into typ
+func decodeJSON(body []byte, typ interface{}) error {
+	if err := json.Unmarshal(body, typ); err != nil {
+		return fmt.Errorf("Could not execute request! #6 (%s)", err.Error())
+	}
+	return nil
+}
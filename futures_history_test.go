@@ -0,0 +1,123 @@
+package krakenapi
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestFuturesFundingRates(t *testing.T) {
+	requests := make(chan testRequest, 1)
+	api := NewFutures("key", "").WithClient(scriptedClient(t, map[string][]string{"": {`{"rates":[{"timestamp":"2023-10-01T00:00:00.000Z","fundingRate":-8.15861558e-10,"relativeFundingRate":-0.000016898883333333},{"timestamp":"2023-10-01T01:00:00.000Z","fundingRate":1.2e-9,"relativeFundingRate":0.000025},{"timestamp":"2023-10-01T02:00:00.000Z","fundingRate":1e-9,"relativeFundingRate":0.00002}],"result":"success","serverTime":"2023-10-04T16:25:00.000Z"}`}}, requests))
+
+	rates, err := api.FundingRates(context.Background(), "PF_XBTUSD", time.Date(2023, 10, 1, 0, 0, 0, 0, time.UTC), time.Date(2023, 10, 1, 1, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := <-requests
+	if req.URL.Path != "/derivatives/api/v4/historicalfundingrates" || req.URL.Query().Get("symbol") != "PF_XBTUSD" {
+		t.Errorf("FundingRates() requested %s", req.URL)
+	}
+	if len(rates) != 1 || rates[0].RelativeFundingRate.String() != "0.000025" || rates[0].FundingRate.String() != "0.0000000012" {
+		t.Errorf("FundingRates() should only return the rates of the range, got %+v", rates)
+	}
+}
+
+func TestFuturesIterateCandles(t *testing.T) {
+	if it := NewFutures("", "").IterateCandles(context.Background(), FuturesTickMark, "PF_XBTUSD", "2", time.Time{}, time.Time{}); it.Next() || it.Err() == nil {
+		t.Error("IterateCandles() should reject unsupported intervals")
+	}
+
+	requests := make(chan testRequest, 2)
+	api := NewFutures("key", "").WithClient(scriptedClient(t, map[string][]string{"": {
+		`{"candles":[{"time":1696118400000,"open":"27000.5","high":"27100","low":"26900","close":"27050","volume":0},{"time":1696122000000,"open":"27050","high":"27200","low":"27000","close":"27150","volume":0}],"more_candles":true}`,
+		`{"candles":[{"time":1696122000000,"open":"27050","high":"27200","low":"27000","close":"27150","volume":0},{"time":1696125600000,"open":"27150","high":"27150","low":"27100","close":"27120","volume":"0"}],"more_candles":false}`,
+	}}, requests))
+
+	it := api.IterateCandles(context.Background(), FuturesTickMark, "PF_XBTUSD", "60", time.Unix(1696118400, 0), time.Time{})
+	var candles []*OHLC
+	for it.Next() {
+		candles = append(candles, it.Candle())
+	}
+	if err := it.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	req := <-requests
+	if req.URL.Path != "/api/charts/v1/mark/PF_XBTUSD/1h" || req.URL.Query().Get("from") != "1696118400" {
+		t.Errorf("IterateCandles() requested %s", req.URL)
+	}
+	if req := <-requests; req.URL.Query().Get("from") != "1696125600" {
+		t.Errorf("IterateCandles() should page after the last candle, requested %s", req.URL)
+	}
+	if len(candles) != 3 || candles[2].Time.Unix() != 1696125600 || candles[0].Open.String() != "27000.5" || candles[2].Close.String() != "27120" {
+		t.Errorf("IterateCandles() returned wrong candles, got %+v", candles)
+	}
+}
+
+func TestFuturesIterateCandlesFilteredPage(t *testing.T) {
+	if it := NewFutures("", "").IterateCandles(context.Background(), FuturesTickMark, "PF_XBTUSD", "60", time.Time{}, time.Time{}); it.Next() || it.Err() == nil {
+		t.Error("IterateCandles() should reject a zero start time")
+	}
+
+	requests := make(chan testRequest, 2)
+	api := NewFutures("key", "").WithClient(scriptedClient(t, map[string][]string{"": {
+		`{"candles":[{"time":1696118400000,"open":"27000.5","high":"27100","low":"26900","close":"27050","volume":0}],"more_candles":true}`,
+		`{"candles":[{"time":1696122000000,"open":"27050","high":"27200","low":"27000","close":"27150","volume":0}],"more_candles":false}`,
+	}}, requests))
+
+	// The first page only holds the candle before the start time
+	it := api.IterateCandles(context.Background(), FuturesTickMark, "PF_XBTUSD", "60", time.Unix(1696120200, 0), time.Time{})
+	var candles []*OHLC
+	for it.Next() {
+		candles = append(candles, it.Candle())
+	}
+	if err := it.Err(); err != nil {
+		t.Fatal(err)
+	}
+	<-requests
+	if req := <-requests; req.URL.Query().Get("from") != "1696122000" {
+		t.Errorf("IterateCandles() should page after the last candle received, requested %s", req.URL)
+	}
+	if len(candles) != 1 || candles[0].Time.Unix() != 1696122000 {
+		t.Errorf("IterateCandles() returned wrong candles, got %+v", candles)
+	}
+}
+
+func TestFuturesIteratePrices(t *testing.T) {
+	requests := make(chan testRequest, 2)
+	api := NewFutures("key", "").WithClient(scriptedClient(t, map[string][]string{"": {
+		`{"elements":[{"uid":"a","timestamp":1696118400000,"event":{"price":"27000.5"}},{"uid":"b","timestamp":1696118430000,"event":{"price":"27010"}}],"len":2,"continuationToken":"c2"}`,
+		`{"elements":[{"uid":"c","timestamp":1696118700000,"event":{"price":"26990"}},{"uid":"d","timestamp":1696119000000,"event":{"price":"27005"}}],"len":2}`,
+	}}, requests))
+
+	it := api.IteratePrices(context.Background(), "PF_XBTUSD", time.Unix(1696118399, 0), time.Unix(1696119000, 0))
+	builder, _ := NewCandleBuilder("5")
+	var candles []*OHLC
+	for it.Next() {
+		candle, err := builder.AddPrice(it.Price().Time, it.Price().Price)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if candle != nil {
+			candles = append(candles, candle)
+		}
+	}
+	if err := it.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if candle := builder.Flush(); candle != nil {
+		candles = append(candles, candle)
+	}
+
+	req := <-requests
+	if req.URL.Path != "/api/history/v3/market/PF_XBTUSD/price" || req.URL.Query().Get("since") != "1696118399000" || req.URL.Query().Get("sort") != "asc" {
+		t.Errorf("IteratePrices() requested %s", req.URL)
+	}
+	if req := <-requests; req.URL.Query().Get("continuation_token") != "c2" {
+		t.Errorf("IteratePrices() should follow the continuation token, requested %s", req.URL)
+	}
+	if len(candles) != 2 || candles[0].Count != 2 || candles[0].High.String() != "27010" || candles[1].Close.String() != "26990" || candles[1].Volume.Sign() != 0 {
+		t.Errorf("AddPrice() built wrong candles, got %+v", candles)
+	}
+}
@@ -0,0 +1,319 @@
+package krakenapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+)
+
+// FuturesOrderType is the type of a Kraken Futures order
+type FuturesOrderType string
+
+// Kraken Futures order types
+const (
+	FuturesOrderLimit        FuturesOrderType = "lmt"
+	FuturesOrderPostOnly     FuturesOrderType = "post"
+	FuturesOrderIOC          FuturesOrderType = "ioc"
+	FuturesOrderMarket       FuturesOrderType = "mkt"
+	FuturesOrderStop         FuturesOrderType = "stp"
+	FuturesOrderTakeProfit   FuturesOrderType = "take_profit"
+	FuturesOrderTrailingStop FuturesOrderType = "trailing_stop"
+)
+
+// FuturesTrigger is the price signal used to trigger stop, take profit and trailing stop orders
+type FuturesTrigger string
+
+// Kraken Futures trigger signals
+const (
+	FuturesTriggerMark  FuturesTrigger = "mark"
+	FuturesTriggerIndex FuturesTrigger = "index"
+	FuturesTriggerLast  FuturesTrigger = "last"
+)
+
+// Units of FuturesOrderRequest.TrailingStopDeviationUnit
+const (
+	DeviationPercent       = "PERCENT"
+	DeviationQuoteCurrency = "QUOTE_CURRENCY"
+)
+
+// FuturesOrderRequest holds every parameter of a SendOrder call.
+// Zero values mean the parameter is not sent.
+type FuturesOrderRequest struct {
+	Symbol    string
+	Side      Side
+	OrderType FuturesOrderType
+	Size      Decimal
+	// Required for limit, post only and IOC orders, optional for stop and take profit orders
+	LimitPrice Decimal
+	// Trigger price of stop and take profit orders
+	StopPrice     Decimal
+	TriggerSignal FuturesTrigger
+	ReduceOnly    bool
+	ClientOrderID string
+	// Distance of a trailing stop from the best price
+	TrailingStopMaxDeviation  Decimal
+	TrailingStopDeviationUnit string
+}
+
+// NewFuturesMarketOrder creates a market FuturesOrderRequest
+func NewFuturesMarketOrder(symbol string, side Side, size Decimal) *FuturesOrderRequest {
+	return &FuturesOrderRequest{Symbol: symbol, Side: side, OrderType: FuturesOrderMarket, Size: size}
+}
+
+// NewFuturesLimitOrder creates a limit FuturesOrderRequest
+func NewFuturesLimitOrder(symbol string, side Side, size Decimal, price Decimal) *FuturesOrderRequest {
+	return &FuturesOrderRequest{Symbol: symbol, Side: side, OrderType: FuturesOrderLimit, Size: size, LimitPrice: price}
+}
+
+// Check validates the combination of parameters without contacting Kraken
+func (r *FuturesOrderRequest) Check() error {
+	if err := r.check(); err != nil {
+		return fmt.Errorf("invalid futures order request: %s", err)
+	}
+	return nil
+}
+
+func (r *FuturesOrderRequest) check() error {
+	if r.Symbol == "" {
+		return fmt.Errorf("symbol is required")
+	}
+	if !r.Side.IsKnown() {
+		return fmt.Errorf("unknown side %q", r.Side)
+	}
+	if r.Size.Sign() <= 0 {
+		return fmt.Errorf("size must be positive")
+	}
+	if r.LimitPrice.Sign() < 0 || r.StopPrice.Sign() < 0 {
+		return fmt.Errorf("prices must not be negative")
+	}
+
+	triggered := false
+	switch r.OrderType {
+	case FuturesOrderLimit, FuturesOrderPostOnly, FuturesOrderIOC:
+		if r.LimitPrice.IsZero() {
+			return fmt.Errorf("%s orders require a limit price", r.OrderType)
+		}
+		if !r.StopPrice.IsZero() {
+			return fmt.Errorf("stop price is not allowed for %s orders", r.OrderType)
+		}
+	case FuturesOrderMarket:
+		if !r.LimitPrice.IsZero() || !r.StopPrice.IsZero() {
+			return fmt.Errorf("prices are not allowed for market orders")
+		}
+	case FuturesOrderStop, FuturesOrderTakeProfit:
+		if r.StopPrice.IsZero() {
+			return fmt.Errorf("%s orders require a stop price", r.OrderType)
+		}
+		triggered = true
+	case FuturesOrderTrailingStop:
+		if r.TrailingStopMaxDeviation.Sign() <= 0 {
+			return fmt.Errorf("trailing stop orders require a positive max deviation")
+		}
+		if r.TrailingStopDeviationUnit != DeviationPercent && r.TrailingStopDeviationUnit != DeviationQuoteCurrency {
+			return fmt.Errorf("unknown deviation unit %q", r.TrailingStopDeviationUnit)
+		}
+		triggered = true
+	default:
+		return fmt.Errorf("unknown order type %q", r.OrderType)
+	}
+
+	if r.TriggerSignal != "" {
+		if r.TriggerSignal != FuturesTriggerMark && r.TriggerSignal != FuturesTriggerIndex && r.TriggerSignal != FuturesTriggerLast {
+			return fmt.Errorf("unknown trigger signal %q", r.TriggerSignal)
+		}
+		if !triggered {
+			return fmt.Errorf("trigger signal is not allowed for %s orders", r.OrderType)
+		}
+	}
+	if r.OrderType != FuturesOrderTrailingStop && (!r.TrailingStopMaxDeviation.IsZero() || r.TrailingStopDeviationUnit != "") {
+		return fmt.Errorf("trailing stop parameters are only allowed for trailing stop orders")
+	}
+	if len(r.ClientOrderID) > 100 {
+		return fmt.Errorf("client order ID must not be longer than 100 characters")
+	}
+	return nil
+}
+
+// params validates the request and returns its parameters
+func (r *FuturesOrderRequest) params() (map[string]string, error) {
+	if err := r.Check(); err != nil {
+		return nil, err
+	}
+
+	params := map[string]string{
+		"orderType": string(r.OrderType),
+		"symbol":    r.Symbol,
+		"side":      string(r.Side),
+		"size":      r.Size.String(),
+	}
+	if !r.LimitPrice.IsZero() {
+		params["limitPrice"] = r.LimitPrice.String()
+	}
+	if !r.StopPrice.IsZero() {
+		params["stopPrice"] = r.StopPrice.String()
+	}
+	if r.TriggerSignal != "" {
+		params["triggerSignal"] = string(r.TriggerSignal)
+	}
+	if r.ReduceOnly {
+		params["reduceOnly"] = "true"
+	}
+	if r.ClientOrderID != "" {
+		params["cliOrdId"] = r.ClientOrderID
+	}
+	if r.OrderType == FuturesOrderTrailingStop {
+		params["trailingStopMaxDeviation"] = r.TrailingStopMaxDeviation.String()
+		params["trailingStopDeviationUnit"] = r.TrailingStopDeviationUnit
+	}
+	return params, nil
+}
+
+// Values validates the request and returns it encoded as SendOrder parameters
+func (r *FuturesOrderRequest) Values() (url.Values, error) {
+	params, err := r.params()
+	if err != nil {
+		return nil, err
+	}
+	return toValues(params), nil
+}
+
+// FuturesEditRequest changes an open order identified by OrderID or ClientOrderID.
+// Zero values keep the current size and prices.
+type FuturesEditRequest struct {
+	OrderID       string
+	ClientOrderID string
+	Size          Decimal
+	LimitPrice    Decimal
+	StopPrice     Decimal
+}
+
+// params validates the request and returns its parameters
+func (r *FuturesEditRequest) params() (map[string]string, error) {
+	if (r.OrderID == "") == (r.ClientOrderID == "") {
+		return nil, fmt.Errorf("invalid futures edit request: either order ID or client order ID is required")
+	}
+	if r.Size.IsZero() && r.LimitPrice.IsZero() && r.StopPrice.IsZero() {
+		return nil, fmt.Errorf("invalid futures edit request: nothing to change")
+	}
+	if r.Size.Sign() < 0 || r.LimitPrice.Sign() < 0 || r.StopPrice.Sign() < 0 {
+		return nil, fmt.Errorf("invalid futures edit request: values must not be negative")
+	}
+
+	params := map[string]string{}
+	if r.OrderID != "" {
+		params["orderId"] = r.OrderID
+	} else {
+		params["cliOrdId"] = r.ClientOrderID
+	}
+	if !r.Size.IsZero() {
+		params["size"] = r.Size.String()
+	}
+	if !r.LimitPrice.IsZero() {
+		params["limitPrice"] = r.LimitPrice.String()
+	}
+	if !r.StopPrice.IsZero() {
+		params["stopPrice"] = r.StopPrice.String()
+	}
+	return params, nil
+}
+
+// Values validates the request and returns it encoded as EditOrder parameters
+func (r *FuturesEditRequest) Values() (url.Values, error) {
+	params, err := r.params()
+	if err != nil {
+		return nil, err
+	}
+	return toValues(params), nil
+}
+
+// toValues converts request parameters to url.Values
+func toValues(params map[string]string) url.Values {
+	values := url.Values{}
+	for key, value := range params {
+		values.Set(key, value)
+	}
+	return values
+}
+
+// FuturesBatchInstruction is one part of a BatchOrder call, create it with
+// BatchSend, BatchEdit or BatchCancel
+type FuturesBatchInstruction struct {
+	// "send", "edit" or "cancel"
+	Order string
+	// Tag to find the status of a sent order
+	OrderTag string
+	Send     *FuturesOrderRequest
+	Edit     *FuturesEditRequest
+	// Order to cancel
+	OrderID       string
+	ClientOrderID string
+}
+
+// BatchSend returns an instruction to place req, its status carries tag
+func BatchSend(tag string, req *FuturesOrderRequest) FuturesBatchInstruction {
+	return FuturesBatchInstruction{Order: "send", OrderTag: tag, Send: req}
+}
+
+// BatchEdit returns an instruction to edit an open order
+func BatchEdit(req *FuturesEditRequest) FuturesBatchInstruction {
+	return FuturesBatchInstruction{Order: "edit", Edit: req}
+}
+
+// BatchCancel returns an instruction to cancel an open order
+func BatchCancel(orderID string) FuturesBatchInstruction {
+	return FuturesBatchInstruction{Order: "cancel", OrderID: orderID}
+}
+
+// batchNumbers are the batch order fields Kraken expects as JSON numbers
+var batchNumbers = []string{"size", "limitPrice", "stopPrice", "trailingStopMaxDeviation"}
+
+// params returns the instruction as part of the batchOrder JSON
+func (i FuturesBatchInstruction) params() (map[string]interface{}, error) {
+	var params map[string]string
+	var err error
+	switch i.Order {
+	case "send":
+		if i.Send == nil || i.OrderTag == "" {
+			return nil, fmt.Errorf("send requires an order request and a tag")
+		}
+		params, err = i.Send.params()
+	case "edit":
+		if i.Edit == nil {
+			return nil, fmt.Errorf("edit requires an edit request")
+		}
+		params, err = i.Edit.params()
+		if id, ok := params["orderId"]; ok {
+			// The batch endpoint names the order ID like the cancel endpoint
+			delete(params, "orderId")
+			params["order_id"] = id
+		}
+	case "cancel":
+		if (i.OrderID == "") == (i.ClientOrderID == "") {
+			return nil, fmt.Errorf("cancel requires either order ID or client order ID")
+		}
+		params = map[string]string{"order_id": i.OrderID, "cliOrdId": i.ClientOrderID}
+	default:
+		return nil, fmt.Errorf("unknown batch order %q", i.Order)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	batch := map[string]interface{}{"order": i.Order}
+	if i.OrderTag != "" {
+		batch["order_tag"] = i.OrderTag
+	}
+	for key, value := range params {
+		if value == "" {
+			continue
+		}
+		if isStringInSlice(key, batchNumbers) {
+			batch[key] = json.Number(value)
+		} else if key == "reduceOnly" {
+			batch[key] = true
+		} else {
+			batch[key] = value
+		}
+	}
+	return batch, nil
+}
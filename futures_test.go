@@ -0,0 +1,165 @@
+package krakenapi
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestFutures returns a client that answers every request with body and records the requests
+func newTestFutures(body string, requests chan<- testRequest) *FuturesAPI {
+	return NewFutures("key", base64.StdEncoding.EncodeToString([]byte("futures-secret"))).WithClient(testClient(body, requests))
+}
+
+func TestCreateFuturesSignature(t *testing.T) {
+	signature := createFuturesSignature("/api/v3/sendorder", "orderType=lmt&symbol=PF_XBTUSD", "1700000000000", []byte("futures-secret"))
+	if expected := "ei3d4ZDTyQizLMVETuCbJ30w2QW4T16yDqFlbImi1k8Hurc/nuA/KTOC/ubb1cB+qjKAI4o/QE7OPCJOXjTe/A=="; signature != expected {
+		t.Errorf("createFuturesSignature() returned %s, want %s", signature, expected)
+	}
+}
+
+func TestFuturesPublic(t *testing.T) {
+	ctx := context.Background()
+	requests := make(chan testRequest, 1)
+	api := newTestFutures(`{"result":"success","instruments":[{"symbol":"PF_XBTUSD","type":"flexible_futures","underlying":"rr_xbtusd","tickSize":0.5,"contractSize":1,"tradeable":true,"impactMidSize":1,"maxPositionSize":1000000,"openingDate":"2022-01-01T00:00:00.000Z","marginLevels":[{"numNonContractUnits":0,"initialMargin":0.02,"maintenanceMargin":0.01}],"fundingRateCoefficient":8,"maxRelativeFundingRate":0.001,"contractValueTradePrecision":4,"postOnly":false,"tags":[]}],"serverTime":"2023-10-04T16:25:00.000Z"}`, requests)
+	instruments, err := api.Instruments(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := <-requests
+	if req.Method != http.MethodGet || req.URL.String() != "https://futures.kraken.com/derivatives/api/v3/instruments" || req.Header.Get("APIKey") != "" {
+		t.Errorf("Instruments() sent a wrong request, got %s %s", req.Method, req.URL)
+	}
+	if len(instruments) != 1 || instruments[0].TickSize.String() != "0.5" || instruments[0].MarginLevels[0].InitialMargin.String() != "0.02" || instruments[0].ContractValueTradePrecision != 4 {
+		t.Errorf("Instruments() returned wrong instruments, got %+v", instruments)
+	}
+
+	api = newTestFutures(`{"result":"success","orderBook":{"bids":[[40186,5.0183],[40185.5,1e-4]],"asks":[[40209,0.1]]},"serverTime":"2023-10-04T16:25:00.000Z"}`, requests)
+	book, err := api.OrderBook(ctx, "PF_XBTUSD")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if req := <-requests; req.URL.Query().Get("symbol") != "PF_XBTUSD" {
+		t.Errorf("OrderBook() sent a wrong symbol, got %s", req.URL)
+	}
+	if len(book.Bids) != 2 || book.Bids[1].Amount.String() != "0.0001" || book.Asks[0].Price.String() != "40209" {
+		t.Errorf("OrderBook() returned a wrong book, got %+v", book)
+	}
+
+	api = newTestFutures(`{"result":"error","error":"apiLimitExceeded","serverTime":"2023-10-04T16:25:00.000Z"}`, nil)
+	if _, err := api.Tickers(ctx); err == nil || !strings.Contains(err.Error(), "apiLimitExceeded") {
+		t.Errorf("Tickers() should return the Kraken error, got %v", err)
+	}
+}
+
+func TestFuturesPrivate(t *testing.T) {
+	ctx := context.Background()
+	requests := make(chan testRequest, 1)
+	api := newTestFutures(`{"result":"success","accounts":{"flex":{"type":"multiCollateralMarginAccount","currencies":{"XBT":{"quantity":0.1,"value":3000,"collateral":2850,"available":0.1}},"portfolioValue":3000.5,"availableMargin":2500},"fi_xbtusd":{"type":"marginAccount","currency":"xbt","balances":{"fi_xbtusd_180615":0,"xbt":0.5},"auxiliary":{"af":0.4,"pnl":0.01,"pv":0.51},"marginRequirements":{"im":0.1,"mm":0.05,"lt":0.04,"tt":0.03}}},"serverTime":"2023-10-04T16:25:00.000Z"}`, requests)
+	accounts, err := api.Accounts(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := <-requests
+	nonce := req.Header.Get("Nonce")
+	if req.Header.Get("APIKey") != "key" || req.Header.Get("Authent") != createFuturesSignature("/api/v3/accounts", "", nonce, []byte("futures-secret")) {
+		t.Errorf("Accounts() sent wrong authentication, got %v", req.Header)
+	}
+	if accounts["flex"].Currencies["XBT"].Collateral.String() != "2850" || accounts["fi_xbtusd"].Auxiliary.AvailableFunds.String() != "0.4" || accounts["fi_xbtusd"].Balances["xbt"].String() != "0.5" {
+		t.Errorf("Accounts() returned wrong accounts, got %+v", accounts)
+	}
+
+	api = newTestFutures(`{"result":"success","sendStatus":{"order_id":"179f9af8-e45e-469d-b3e9-2fd4675cb7d0","status":"placed","receivedTime":"2019-09-05T16:33:50.734Z","orderEvents":[{"order":{"orderId":"179f9af8-e45e-469d-b3e9-2fd4675cb7d0","cliOrdId":"my-order","type":"lmt","symbol":"PF_XBTUSD","side":"buy","quantity":0.01,"filled":0,"limitPrice":9400,"reduceOnly":false,"timestamp":"2019-09-05T16:33:50.734Z","lastUpdateTimestamp":"2019-09-05T16:33:50.734Z"},"reducedQuantity":null,"type":"PLACE"}]},"serverTime":"2019-09-05T16:33:50.734Z"}`, requests)
+	order := NewFuturesLimitOrder("PF_XBTUSD", SideBuy, MustDecimal("0.01"), MustDecimal("9400"))
+	order.ClientOrderID = "my-order"
+	order.ReduceOnly = true
+	status, err := api.SendOrder(ctx, order)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req = <-requests
+	body, values := req.Data, req.Values
+	if req.Method != http.MethodPost || values.Get("orderType") != "lmt" || values.Get("size") != "0.01" || values.Get("limitPrice") != "9400" || values.Get("reduceOnly") != "true" {
+		t.Errorf("SendOrder() sent a wrong request, got %s", body)
+	}
+	if req.Header.Get("Authent") != createFuturesSignature("/api/v3/sendorder", string(body), req.Header.Get("Nonce"), []byte("futures-secret")) {
+		t.Error("SendOrder() should sign the post data")
+	}
+	if status.Status != "placed" || status.OrderID != "179f9af8-e45e-469d-b3e9-2fd4675cb7d0" || status.OrderEvents[0].Order.ClientOrderID != "my-order" {
+		t.Errorf("SendOrder() returned a wrong status, got %+v", status)
+	}
+
+	api = newTestFutures(`{"result":"success","editStatus":{"status":"edited","orderId":"022774bc-2c4a-4f26-9317-436c8d85746d","receivedTime":"2019-09-05T16:47:47.521Z","orderEvents":[]},"serverTime":"2019-09-05T16:47:47.521Z"}`, nil)
+	edited, err := api.EditOrder(ctx, &FuturesEditRequest{OrderID: "022774bc-2c4a-4f26-9317-436c8d85746d", LimitPrice: MustDecimal("9500")})
+	if err != nil || edited.OrderID != "022774bc-2c4a-4f26-9317-436c8d85746d" || edited.Status != "edited" {
+		t.Errorf("EditOrder() returned a wrong status, got %+v %v", edited, err)
+	}
+	if _, err := api.EditOrder(ctx, &FuturesEditRequest{OrderID: "022774bc"}); err == nil {
+		t.Error("EditOrder() should reject an edit without changes")
+	}
+
+	api = newTestFutures(`{"result":"success","batchStatus":[{"status":"placed","order_tag":"1","order_id":"022774bc-2c4a-4f26-9317-436c8d85746d","dateTimeReceived":"2019-09-05T16:41:35.173Z","orderEvents":[]},{"status":"cancelled","order_id":"9c2cbcc8-14f6-42fe-a020-6e395babafd1","orderEvents":[]}],"serverTime":"2019-09-05T16:41:40.176Z"}`, requests)
+	batch, err := api.BatchOrder(ctx, BatchSend("1", NewFuturesLimitOrder("PF_XBTUSD", SideSell, MustDecimal("1"), MustDecimal("9400"))), BatchCancel("9c2cbcc8-14f6-42fe-a020-6e395babafd1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req = <-requests
+	values = req.Values
+	var sent struct {
+		BatchOrder []map[string]interface{} `json:"batchOrder"`
+	}
+	if err := json.Unmarshal([]byte(values.Get("json")), &sent); err != nil {
+		t.Fatal(err)
+	}
+	if send := sent.BatchOrder[0]; send["order"] != "send" || send["order_tag"] != "1" || send["size"] != 1.0 || send["limitPrice"] != 9400.0 {
+		t.Errorf("BatchOrder() sent a wrong send instruction, got %v", send)
+	}
+	if cancel := sent.BatchOrder[1]; cancel["order"] != "cancel" || cancel["order_id"] != "9c2cbcc8-14f6-42fe-a020-6e395babafd1" || cancel["cliOrdId"] != nil {
+		t.Errorf("BatchOrder() sent a wrong cancel instruction, got %v", cancel)
+	}
+	if len(batch) != 2 || batch[0].OrderTag != "1" || batch[0].ReceivedTime.IsZero() || batch[1].Status != "cancelled" {
+		t.Errorf("BatchOrder() returned wrong statuses, got %+v", batch)
+	}
+
+	api = newTestFutures(`{"result":"success","fills":[{"fill_id":"3d57ed09-fbd6-44f1-8e8b-b10e551c5e73","symbol":"PF_XBTUSD","side":"buy","order_id":"693af756-055e-47ef-99d5-bcf4c456ebc5","size":0.5,"price":9400,"fillTime":"2020-07-22T13:37:27.077Z","fillType":"maker"}],"serverTime":"2020-07-22T13:44:24.311Z"}`, requests)
+	fills, err := api.Fills(ctx, time.Date(2020, 7, 23, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if req := <-requests; req.URL.Query().Get("lastFillTime") != "2020-07-23T00:00:00.000Z" {
+		t.Errorf("Fills() sent a wrong lastFillTime, got %s", req.URL)
+	}
+	if len(fills) != 1 || fills[0].Size.String() != "0.5" || fills[0].Side != SideBuy || fills[0].FillTime.Unix() != 1595425047 {
+		t.Errorf("Fills() returned wrong fills, got %+v", fills)
+	}
+}
+
+func TestFuturesOrderRequestCheck(t *testing.T) {
+	invalid := []*FuturesOrderRequest{
+		{Symbol: "PF_XBTUSD", Side: SideBuy, OrderType: FuturesOrderLimit, Size: MustDecimal("1")},
+		{Symbol: "PF_XBTUSD", Side: SideBuy, OrderType: FuturesOrderMarket, Size: MustDecimal("1"), LimitPrice: MustDecimal("1")},
+		{Symbol: "PF_XBTUSD", Side: SideBuy, OrderType: FuturesOrderStop, Size: MustDecimal("1")},
+		{Symbol: "PF_XBTUSD", Side: SideBuy, OrderType: FuturesOrderLimit, Size: MustDecimal("1"), LimitPrice: MustDecimal("1"), TriggerSignal: FuturesTriggerMark},
+		{Symbol: "PF_XBTUSD", Side: SideBuy, OrderType: FuturesOrderTrailingStop, Size: MustDecimal("1"), TrailingStopMaxDeviation: MustDecimal("1")},
+		{Symbol: "PF_XBTUSD", Side: "long", OrderType: FuturesOrderMarket, Size: MustDecimal("1")},
+		{Symbol: "PF_XBTUSD", Side: SideBuy, OrderType: FuturesOrderMarket},
+	}
+	for i, req := range invalid {
+		if err := req.Check(); err == nil {
+			t.Errorf("request %d should be invalid", i)
+		}
+	}
+
+	stop := &FuturesOrderRequest{Symbol: "PF_XBTUSD", Side: SideSell, OrderType: FuturesOrderStop, Size: MustDecimal("1"), StopPrice: MustDecimal("9000"), LimitPrice: MustDecimal("8990"), TriggerSignal: FuturesTriggerMark}
+	values, err := stop.Values()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if values.Get("stopPrice") != "9000" || values.Get("limitPrice") != "8990" || values.Get("triggerSignal") != "mark" {
+		t.Errorf("Values() returned wrong values, got %v", values)
+	}
+}
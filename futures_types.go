@@ -0,0 +1,255 @@
+package krakenapi
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// futuresResponse is the status part of every Kraken Futures response
+type futuresResponse struct {
+	// "success" or "error"
+	Result string          `json:"result"`
+	Error  string          `json:"error"`
+	Errors json.RawMessage `json:"errors"`
+}
+
+// FuturesInstrument is a contract or index listed on Kraken Futures
+type FuturesInstrument struct {
+	// Symbol like "PF_XBTUSD"
+	Symbol string `json:"symbol"`
+	// Type like "flexible_futures", "futures_inverse" or "spot index"
+	Type         string  `json:"type"`
+	Underlying   string  `json:"underlying"`
+	Tradeable    bool    `json:"tradeable"`
+	TickSize     Decimal `json:"tickSize"`
+	ContractSize Decimal `json:"contractSize"`
+	// Number of decimals allowed in order sizes, negative for multiples of ten
+	ContractValueTradePrecision int                  `json:"contractValueTradePrecision"`
+	ImpactMidSize               Decimal              `json:"impactMidSize"`
+	MaxPositionSize             Decimal              `json:"maxPositionSize"`
+	OpeningDate                 time.Time            `json:"openingDate"`
+	LastTradingTime             time.Time            `json:"lastTradingTime"`
+	MarginLevels                []FuturesMarginLevel `json:"marginLevels"`
+	FundingRateCoefficient      Decimal              `json:"fundingRateCoefficient"`
+	MaxRelativeFundingRate      Decimal              `json:"maxRelativeFundingRate"`
+	PostOnly                    bool                 `json:"postOnly"`
+	Tags                        []string             `json:"tags"`
+}
+
+// FuturesMarginLevel is the margin required from a position size on
+type FuturesMarginLevel struct {
+	// Position size in contracts, set for inverse futures
+	Contracts Decimal `json:"contracts"`
+	// Position size in the base currency, set for flexible futures
+	NumNonContractUnits Decimal `json:"numNonContractUnits"`
+	InitialMargin       Decimal `json:"initialMargin"`
+	MaintenanceMargin   Decimal `json:"maintenanceMargin"`
+}
+
+// FuturesTicker is the market summary of a contract or index, indices only have
+// Symbol, Last and LastTime
+type FuturesTicker struct {
+	Symbol string `json:"symbol"`
+	// Pair like "XBT:USD"
+	Pair                  string    `json:"pair"`
+	Tag                   string    `json:"tag"`
+	Last                  Decimal   `json:"last"`
+	LastTime              time.Time `json:"lastTime"`
+	LastSize              Decimal   `json:"lastSize"`
+	MarkPrice             Decimal   `json:"markPrice"`
+	IndexPrice            Decimal   `json:"indexPrice"`
+	Bid                   Decimal   `json:"bid"`
+	BidSize               Decimal   `json:"bidSize"`
+	Ask                   Decimal   `json:"ask"`
+	AskSize               Decimal   `json:"askSize"`
+	Volume24h             Decimal   `json:"vol24h"`
+	VolumeQuote           Decimal   `json:"volumeQuote"`
+	OpenInterest          Decimal   `json:"openInterest"`
+	Open24h               Decimal   `json:"open24h"`
+	High24h               Decimal   `json:"high24h"`
+	Low24h                Decimal   `json:"low24h"`
+	Change24h             Decimal   `json:"change24h"`
+	FundingRate           Decimal   `json:"fundingRate"`
+	FundingRatePrediction Decimal   `json:"fundingRatePrediction"`
+	Suspended             bool      `json:"suspended"`
+	PostOnly              bool      `json:"postOnly"`
+}
+
+// FuturesAccount is a cash, margin or multi-collateral account.
+// Fields that do not apply to the account type are zero.
+type FuturesAccount struct {
+	// "cashAccount", "marginAccount" or "multiCollateralMarginAccount"
+	Type string `json:"type"`
+	// Currency of a margin account
+	Currency string             `json:"currency"`
+	Balances map[string]Decimal `json:"balances"`
+	// Values of a margin account
+	Auxiliary          FuturesAuxiliary          `json:"auxiliary"`
+	MarginRequirements FuturesMarginRequirements `json:"marginRequirements"`
+	// Collateral of the multi-collateral account
+	Currencies        map[string]FuturesCollateral `json:"currencies"`
+	BalanceValue      Decimal                      `json:"balanceValue"`
+	PortfolioValue    Decimal                      `json:"portfolioValue"`
+	CollateralValue   Decimal                      `json:"collateralValue"`
+	InitialMargin     Decimal                      `json:"initialMargin"`
+	MaintenanceMargin Decimal                      `json:"maintenanceMargin"`
+	AvailableMargin   Decimal                      `json:"availableMargin"`
+	PnL               Decimal                      `json:"pnl"`
+	UnrealizedFunding Decimal                      `json:"unrealizedFunding"`
+	TotalUnrealized   Decimal                      `json:"totalUnrealized"`
+}
+
+// FuturesAuxiliary holds the values of a margin account
+type FuturesAuxiliary struct {
+	AvailableFunds Decimal `json:"af"`
+	PnL            Decimal `json:"pnl"`
+	PortfolioValue Decimal `json:"pv"`
+	Funding        Decimal `json:"funding"`
+	USD            Decimal `json:"usd"`
+}
+
+// FuturesMarginRequirements holds the margin thresholds of a margin account
+type FuturesMarginRequirements struct {
+	Initial     Decimal `json:"im"`
+	Maintenance Decimal `json:"mm"`
+	Liquidation Decimal `json:"lt"`
+	Termination Decimal `json:"tt"`
+}
+
+// FuturesCollateral is a currency held in the multi-collateral account
+type FuturesCollateral struct {
+	Quantity Decimal `json:"quantity"`
+	// Value in USD
+	Value      Decimal `json:"value"`
+	Collateral Decimal `json:"collateral"`
+	Available  Decimal `json:"available"`
+}
+
+// FuturesPosition is an open position
+type FuturesPosition struct {
+	Symbol string `json:"symbol"`
+	// "long" or "short"
+	Side string `json:"side"`
+	// Average entry price
+	Price             Decimal   `json:"price"`
+	Size              Decimal   `json:"size"`
+	FillTime          time.Time `json:"fillTime"`
+	UnrealizedFunding Decimal   `json:"unrealizedFunding"`
+	PnLCurrency       string    `json:"pnlCurrency"`
+	MaxFixedLeverage  Decimal   `json:"maxFixedLeverage"`
+}
+
+// FuturesOrder is an open order
+type FuturesOrder struct {
+	OrderID       string `json:"order_id"`
+	ClientOrderID string `json:"cliOrdId"`
+	Symbol        string `json:"symbol"`
+	Side          Side   `json:"side"`
+	// Order type like "lmt", "stop" or "take_profit"
+	OrderType      string    `json:"orderType"`
+	LimitPrice     Decimal   `json:"limitPrice"`
+	StopPrice      Decimal   `json:"stopPrice"`
+	FilledSize     Decimal   `json:"filledSize"`
+	UnfilledSize   Decimal   `json:"unfilledSize"`
+	ReduceOnly     bool      `json:"reduceOnly"`
+	TriggerSignal  string    `json:"triggerSignal"`
+	ReceivedTime   time.Time `json:"receivedTime"`
+	LastUpdateTime time.Time `json:"lastUpdateTime"`
+	// Status like "untouched" or "partiallyFilled"
+	Status string `json:"status"`
+}
+
+// FuturesOrderStatus is the answer to an order request.
+// Status is e.g. "placed", "edited", "cancelled", "insufficientAvailableFunds" or "notFound".
+type FuturesOrderStatus struct {
+	OrderID       string
+	ClientOrderID string
+	// Tag of the batch instruction which sent the order
+	OrderTag     string
+	Status       string
+	ReceivedTime time.Time
+	OrderEvents  []FuturesOrderEvent
+}
+
+// UnmarshalJSON decodes the slightly different status objects of the order endpoints
+func (s *FuturesOrderStatus) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		OrderID          string              `json:"order_id"`
+		EditedOrderID    string              `json:"orderId"`
+		ClientOrderID    string              `json:"cliOrdId"`
+		OrderTag         string              `json:"order_tag"`
+		Status           string              `json:"status"`
+		ReceivedTime     time.Time           `json:"receivedTime"`
+		DateTimeReceived time.Time           `json:"dateTimeReceived"`
+		OrderEvents      []FuturesOrderEvent `json:"orderEvents"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	*s = FuturesOrderStatus{
+		OrderID:       raw.OrderID,
+		ClientOrderID: raw.ClientOrderID,
+		OrderTag:      raw.OrderTag,
+		Status:        raw.Status,
+		ReceivedTime:  raw.ReceivedTime,
+		OrderEvents:   raw.OrderEvents,
+	}
+	if s.OrderID == "" {
+		s.OrderID = raw.EditedOrderID
+	}
+	if s.ReceivedTime.IsZero() {
+		s.ReceivedTime = raw.DateTimeReceived
+	}
+	return nil
+}
+
+// FuturesOrderEvent is a change an order request caused
+type FuturesOrderEvent struct {
+	// Type like "PLACE", "EXECUTION", "EDIT", "CANCEL" or "REJECT"
+	Type  string             `json:"type"`
+	Order *FuturesEventOrder `json:"order"`
+	// Order before and after an edit
+	Old *FuturesEventOrder `json:"old"`
+	New *FuturesEventOrder `json:"new"`
+	// Reason of a rejection, e.g. "POST_WOULD_EXECUTE"
+	Reason string `json:"reason"`
+	// Execution details
+	ExecutionID string  `json:"executionId"`
+	Price       Decimal `json:"price"`
+	Amount      Decimal `json:"amount"`
+}
+
+// FuturesEventOrder is the state of an order in a FuturesOrderEvent
+type FuturesEventOrder struct {
+	OrderID       string `json:"orderId"`
+	ClientOrderID string `json:"cliOrdId"`
+	// Type like "lmt" or "stp"
+	Type                string    `json:"type"`
+	Symbol              string    `json:"symbol"`
+	Side                Side      `json:"side"`
+	Quantity            Decimal   `json:"quantity"`
+	Filled              Decimal   `json:"filled"`
+	LimitPrice          Decimal   `json:"limitPrice"`
+	StopPrice           Decimal   `json:"stopPrice"`
+	ReduceOnly          bool      `json:"reduceOnly"`
+	Timestamp           time.Time `json:"timestamp"`
+	LastUpdateTimestamp time.Time `json:"lastUpdateTimestamp"`
+}
+
+// FuturesFill is an execution of an own order
+type FuturesFill struct {
+	FillID        string    `json:"fill_id"`
+	OrderID       string    `json:"order_id"`
+	ClientOrderID string    `json:"cliOrdId"`
+	Symbol        string    `json:"symbol"`
+	Side          Side      `json:"side"`
+	Size          Decimal   `json:"size"`
+	Price         Decimal   `json:"price"`
+	FillTime      time.Time `json:"fillTime"`
+	// Fill type like "maker", "taker" or "liquidation"
+	FillType string `json:"fillType"`
+	// Fee, only sent on the WebSocket
+	Fee         Decimal `json:"-"`
+	FeeCurrency string  `json:"-"`
+}
@@ -1,6 +1,7 @@
 package krakenapi
 
 import (
+	"context"
 	"crypto/hmac"
 	"crypto/sha256"
 	"crypto/sha512"
@@ -9,7 +10,6 @@ import (
 	"errors"
 	"fmt"
 	"io/ioutil"
-	"math/big"
 	"mime"
 	"net/http"
 	"net/url"
@@ -42,11 +42,13 @@ var publicMethods = []string{
 
 // List of valid private methods
 var privateMethods = []string{
+	"AccountTransfer",
 	"AddExport",
 	"AddOrder",
 	"Balance",
 	"CancelOrder",
 	"ClosedOrders",
+	"CreateSubaccount",
 	"DepositAddresses",
 	"DepositMethods",
 	"DepositStatus",
@@ -72,6 +74,8 @@ var privateMethods = []string{
 
 // These represent the minimum order sizes for the respective coins
 // Should be monitored through here: https://support.kraken.com/hc/en-us/articles/205893708-What-is-the-minimum-order-size-
+//
+// Deprecated: these values are outdated, use AssetPairInfo.OrderMin or an OrderValidator instead.
 const (
 	MinimumREP  = 0.3
 	MinimumXBT  = 0.002
@@ -97,9 +101,11 @@ type KrakenApi = KrakenAPI
 
 // KrakenAPI represents a Kraken API Client connection
 type KrakenAPI struct {
-	key    string
-	secret string
-	client *http.Client
+	key      string
+	secret   string
+	client   *http.Client
+	registry *Registry
+	limiter  RateLimiter
 }
 
 // New creates a new Kraken API client
@@ -124,6 +130,18 @@ func (api *KrakenAPI) WithClient(httpClient *http.Client) *KrakenAPI {
 	return api
 }
 
+// WithRegistry attaches a Registry to the KrakenAPI, which is used to resolve pair aliases
+func (api *KrakenAPI) WithRegistry(registry *Registry) *KrakenAPI {
+	api.registry = registry
+	return api
+}
+
+// WithRateLimiter makes the KrakenAPI wait for the RateLimiter before every request
+func (api *KrakenAPI) WithRateLimiter(limiter RateLimiter) *KrakenAPI {
+	api.limiter = limiter
+	return api
+}
+
 // Time returns the server's time
 func (api *KrakenAPI) Time() (*TimeResponse, error) {
 	resp, err := api.queryPublicGet("Time", nil, &TimeResponse{})
@@ -135,77 +153,166 @@ func (api *KrakenAPI) Time() (*TimeResponse, error) {
 }
 
 // Assets returns the servers available assets
+//
+// Deprecated: Assets drops every asset without a field in AssetsResponse, use AssetsWithClass instead.
 func (api *KrakenAPI) Assets() (*AssetsResponse, error) {
-	resp, err := api.queryPublicGet("Assets", nil, &AssetsResponse{})
+	assets, err := api.AssetsWithClass("")
 	if err != nil {
 		return nil, err
 	}
 
-	return resp.(*AssetsResponse), nil
+	return assets.Legacy(), nil
 }
 
 // AssetPairs returns the servers available asset pairs
+//
+// Deprecated: AssetPairs drops every pair without a field in AssetPairsResponse, use AssetPairsWithInfo instead.
 func (api *KrakenAPI) AssetPairs() (*AssetPairsResponse, error) {
-	resp, err := api.queryPublicGet("AssetPairs", nil, &AssetPairsResponse{})
+	pairs, err := api.AssetPairsWithInfo("", "")
+	if err != nil {
+		return nil, err
+	}
+
+	return pairs.Legacy(), nil
+}
+
+// AssetsWithClass returns information about the given assets, or all assets if none are given.
+// An empty aclass uses Kraken's default asset class ("currency").
+func (api *KrakenAPI) AssetsWithClass(aclass string, assets ...string) (AssetMap, error) {
+	values := url.Values{}
+	if len(assets) > 0 {
+		values.Set("asset", api.assetList(strings.Join(assets, ",")))
+	}
+	if aclass != "" {
+		values.Set("aclass", aclass)
+	}
+
+	resp, err := api.queryPublicGet("Assets", values, &AssetMap{})
+	if err != nil {
+		return nil, err
+	}
+
+	return *resp.(*AssetMap), nil
+}
+
+// AssetPairsWithInfo returns the given info level for the given pairs, or all pairs if none are given.
+// Only the fields belonging to the requested info level are populated. A non-empty aclassBase
+// filters by the asset class of the base asset, e.g. "tokenized_asset".
+func (api *KrakenAPI) AssetPairsWithInfo(aclassBase, info string, pairs ...string) (AssetPairMap, error) {
+	values := url.Values{}
+	if len(pairs) > 0 {
+		values.Set("pair", api.pairList(strings.Join(pairs, ",")))
+	}
+	if aclassBase != "" {
+		values.Set("aclass_base", aclassBase)
+	}
+
+	switch info {
+	case "":
+	case InfoAll, InfoLeverage, InfoFees, InfoMargin:
+		values.Set("info", info)
+	default:
+		return nil, fmt.Errorf("Unsupported value for info: %s", info)
+	}
+
+	resp, err := api.queryPublicGet("AssetPairs", values, &AssetPairMap{})
 	if err != nil {
 		return nil, err
 	}
 
-	return resp.(*AssetPairsResponse), nil
+	return *resp.(*AssetPairMap), nil
 }
 
 // Ticker returns the ticker for given comma separated pairs
+//
+// Deprecated: Ticker drops every pair without a field in TickerResponse, use Tickers instead.
 func (api *KrakenAPI) Ticker(pairs ...string) (*TickerResponse, error) {
-	resp, err := api.queryPublicGet("Ticker", url.Values{
-		"pair": {strings.Join(pairs, ",")},
-	}, &TickerResponse{})
+	tickers, err := api.Tickers(pairs...)
 	if err != nil {
 		return nil, err
 	}
 
-	return resp.(*TickerResponse), nil
+	return tickers.Legacy(), nil
 }
 
-// OHLCWithInterval returns a OHLCResponse struct based on the given pair
-func (api *KrakenAPI) OHLCWithInterval(pair string, interval string) (*OHLCResponse, error) {
-	urlValue := url.Values{}
-	urlValue.Add("pair", pair)
+// Tickers returns the ticker for given pairs, keyed by the pair names returned by Kraken
+func (api *KrakenAPI) Tickers(pairs ...string) (TickerMap, error) {
+	return api.TickersContext(context.Background(), pairs...)
+}
+
+// TickersContext returns the ticker for given pairs, keyed by the pair names returned by Kraken
+func (api *KrakenAPI) TickersContext(ctx context.Context, pairs ...string) (TickerMap, error) {
+	resp, err := api.queryPublicGetContext(ctx, "Ticker", url.Values{
+		"pair": {api.pairList(strings.Join(pairs, ","))},
+	}, &TickerMap{})
+	if err != nil {
+		return nil, err
+	}
+
+	return *resp.(*TickerMap), nil
+}
+
+// ohlcIntervals are the candle intervals in minutes Kraken supports
+// See https://docs.kraken.com/rest/#tag/Market-Data/operation/getOHLCData
+var ohlcIntervals = []string{"1", "5", "15", "30", "60", "240", "1440", "10080", "21600"}
 
+// intervalDuration returns the length of a supported OHLC interval, "" meaning 1 minute
+func intervalDuration(interval string) (time.Duration, error) {
 	if interval == "" {
-		urlValue.Add("interval", "1")
-	} else {
-		switch interval {
-		// supported values https://www.kraken.com/features/api#get-ohlc-data
-		case "1", "5", "15", "30", "60", "240", "1440", "10080", "21600":
-			urlValue.Add("interval", interval)
-		default:
-			return nil, fmt.Errorf("Unsupported value for Interval: " + interval)
-		}
+		interval = "1"
+	}
+	if !isStringInSlice(interval, ohlcIntervals) {
+		return 0, fmt.Errorf("Unsupported value for Interval: %s", interval)
 	}
+	minutes, _ := strconv.Atoi(interval)
+	return time.Duration(minutes) * time.Minute, nil
+}
+
+// OHLCWithInterval returns a OHLCResponse struct based on the given pair
+func (api *KrakenAPI) OHLCWithInterval(pair string, interval string) (*OHLCResponse, error) {
+	return api.OHLCSinceContext(context.Background(), pair, interval, 0)
+}
+
+// OHLCSince returns the candles of the given pair after the since cursor, pass the Last
+// field of the response to poll for new candles. Kraken only keeps the most recent 720
+// candles of an interval and always returns the still open candle, which is therefore
+// returned again by the next poll.
+func (api *KrakenAPI) OHLCSince(pair string, interval string, since int64) (*OHLCResponse, error) {
+	return api.OHLCSinceContext(context.Background(), pair, interval, since)
+}
 
-	// Returns a map[string]interface{} as an interface{}
-	interfaceResponse, err := api.queryPublicGet("OHLC", urlValue, nil)
+// OHLCSinceContext returns the candles of the given pair after the since cursor
+func (api *KrakenAPI) OHLCSinceContext(ctx context.Context, pair string, interval string, since int64) (*OHLCResponse, error) {
+	duration, err := intervalDuration(interval)
 	if err != nil {
 		return nil, err
 	}
 
-	// Converts the interface into map[string]interface{}
-	mapResponse := interfaceResponse.(map[string]interface{})
-	// Extracts the list of OHLC from the map to build a slice of interfaces
-	OHLCsUnstructured := mapResponse[pair].([]interface{})
+	urlValue := url.Values{}
+	urlValue.Add("pair", api.pairName(pair))
+	urlValue.Add("interval", strconv.Itoa(int(duration/time.Minute)))
+	if since > 0 {
+		urlValue.Add("since", strconv.FormatInt(since, 10))
+	}
+
+	rows := []json.RawMessage{}
+	last, err := api.queryPairResult(ctx, "OHLC", pair, urlValue, &rows)
+	if err != nil {
+		return nil, err
+	}
 
 	ret := new(OHLCResponse)
-	for _, OHLCInterfaceSlice := range OHLCsUnstructured {
-		OHLCObj, OHLCErr := NewOHLC(OHLCInterfaceSlice.([]interface{}))
-		if OHLCErr != nil {
-			return nil, OHLCErr
+	for i, row := range rows {
+		OHLCObj := new(OHLC)
+		if err := json.Unmarshal(row, OHLCObj); err != nil {
+			return nil, fmt.Errorf("Could not decode OHLC entry %d: %s", i, err)
 		}
 
 		ret.OHLC = append(ret.OHLC, OHLCObj)
 	}
 
 	ret.Pair = pair
-	ret.Last = mapResponse["last"].(float64)
+	ret.Last = float64(last)
 
 	return ret, nil
 }
@@ -247,64 +354,60 @@ func (api *KrakenAPI) TradesHistory(start int64, end int64, args map[string]stri
 
 // Trades returns the recent trades for given pair
 func (api *KrakenAPI) Trades(pair string, since int64) (*TradesResponse, error) {
-	values := url.Values{"pair": {pair}}
+	return api.TradesContext(context.Background(), pair, since)
+}
+
+// TradesContext returns up to 1000 trades for given pair after the since cursor
+func (api *KrakenAPI) TradesContext(ctx context.Context, pair string, since int64) (*TradesResponse, error) {
+	values := url.Values{"pair": {api.pairName(pair)}}
 	if since > 0 {
 		values.Set("since", strconv.FormatInt(since, 10))
 	}
-	resp, err := api.queryPublicGet("Trades", values, nil)
-	if err != nil {
-		return nil, err
-	}
-
-	v := resp.(map[string]interface{})
-
-	last, err := strconv.ParseInt(v["last"].(string), 10, 64)
+	rows := []json.RawMessage{}
+	last, err := api.queryPairResult(ctx, "Trades", pair, values, &rows)
 	if err != nil {
 		return nil, err
 	}
 
 	result := &TradesResponse{
 		Last:   last,
-		Trades: make([]TradeInfo, 0),
-	}
-
-	trades := v[pair].([]interface{})
-	for _, v := range trades {
-		trade := v.([]interface{})
-
-		priceString := trade[0].(string)
-		price, _ := strconv.ParseFloat(priceString, 64)
-
-		volumeString := trade[1].(string)
-		volume, _ := strconv.ParseFloat(trade[1].(string), 64)
-
-		tradeInfo := TradeInfo{
-			Price:         priceString,
-			PriceFloat:    price,
-			Volume:        volumeString,
-			VolumeFloat:   volume,
-			Time:          int64(trade[2].(float64)),
-			Buy:           trade[3].(string) == BUY,
-			Sell:          trade[3].(string) == SELL,
-			Market:        trade[4].(string) == MARKET,
-			Limit:         trade[4].(string) == LIMIT,
-			Miscellaneous: trade[5].(string),
-		}
+		Trades: make([]TradeInfo, len(rows)),
+	}
 
-		result.Trades = append(result.Trades, tradeInfo)
+	for i, row := range rows {
+		if err := json.Unmarshal(row, &result.Trades[i]); err != nil {
+			return nil, fmt.Errorf("Could not decode trade %d: %s", i, err)
+		}
 	}
 
 	return result, nil
 }
 
 // Balance returns all account asset balances
+//
+// Deprecated: Balance drops every asset without a field in BalanceResponse, use Balances instead.
 func (api *KrakenAPI) Balance() (*BalanceResponse, error) {
-	resp, err := api.queryPrivate("Balance", url.Values{}, &BalanceResponse{})
+	balances, err := api.Balances()
+	if err != nil {
+		return nil, err
+	}
+
+	return balances.Legacy(), nil
+}
+
+// Balances returns all account asset balances, keyed by the asset names returned by Kraken
+func (api *KrakenAPI) Balances() (BalanceMap, error) {
+	return api.BalancesContext(context.Background())
+}
+
+// BalancesContext returns all account asset balances, keyed by the asset names returned by Kraken
+func (api *KrakenAPI) BalancesContext(ctx context.Context) (BalanceMap, error) {
+	resp, err := api.queryPrivateContext(ctx, "Balance", url.Values{}, &BalanceMap{})
 	if err != nil {
 		return nil, err
 	}
 
-	return resp.(*BalanceResponse), nil
+	return *resp.(*BalanceMap), nil
 }
 
 // TradeBalance returns trade balance info
@@ -314,7 +417,7 @@ func (api *KrakenAPI) TradeBalance(args map[string]string) (*TradeBalanceRespons
 		params.Add("aclass", value)
 	}
 	if value, ok := args["asset"]; ok {
-		params.Add("asset", value)
+		params.Add("asset", api.assetName(value))
 	}
 	resp, err := api.queryPrivate("TradeBalance", params, &TradeBalanceResponse{})
 	if err != nil {
@@ -328,7 +431,7 @@ func (api *KrakenAPI) TradeBalance(args map[string]string) (*TradeBalanceRespons
 func (api *KrakenAPI) TradeVolume(args map[string]string) (*TradeVolumeResponse, error) {
 	params := url.Values{}
 	if value, ok := args["pair"]; ok {
-		params.Add("pair", value)
+		params.Add("pair", api.pairList(value))
 	}
 	if value, ok := args["fee-info"]; ok {
 		params.Add("fee-info", value)
@@ -343,6 +446,11 @@ func (api *KrakenAPI) TradeVolume(args map[string]string) (*TradeVolumeResponse,
 
 // OpenOrders returns all open orders
 func (api *KrakenAPI) OpenOrders(args map[string]string) (*OpenOrdersResponse, error) {
+	return api.OpenOrdersContext(context.Background(), args)
+}
+
+// OpenOrdersContext returns all open orders
+func (api *KrakenAPI) OpenOrdersContext(ctx context.Context, args map[string]string) (*OpenOrdersResponse, error) {
 	params := url.Values{}
 	if value, ok := args["trades"]; ok {
 		params.Add("trades", value)
@@ -351,7 +459,7 @@ func (api *KrakenAPI) OpenOrders(args map[string]string) (*OpenOrdersResponse, e
 		params.Add("userref", value)
 	}
 
-	resp, err := api.queryPrivate("OpenOrders", params, &OpenOrdersResponse{})
+	resp, err := api.queryPrivateContext(ctx, "OpenOrders", params, &OpenOrdersResponse{})
 
 	if err != nil {
 		return nil, err
@@ -394,14 +502,19 @@ func (api *KrakenAPI) ClosedOrders(args map[string]string) (*ClosedOrdersRespons
 func (api *KrakenAPI) Depth(pair string, count int) (*OrderBook, error) {
 	dr := DepthResponse{}
 	_, err := api.queryPublicGet("Depth", url.Values{
-		"pair": {pair}, "count": {strconv.Itoa(count)},
+		"pair": {api.pairName(pair)}, "count": {strconv.Itoa(count)},
 	}, &dr)
 
 	if err != nil {
 		return nil, err
 	}
 
-	if book, found := dr[pair]; found {
+	keys := make([]string, 0, len(dr))
+	for key := range dr {
+		keys = append(keys, key)
+	}
+	if key, found := api.resultKey(pair, keys); found {
+		book := dr[key]
 		return &book, nil
 	}
 
@@ -410,9 +523,14 @@ func (api *KrakenAPI) Depth(pair string, count int) (*OrderBook, error) {
 
 // CancelOrder cancels order
 func (api *KrakenAPI) CancelOrder(txid string) (*CancelOrderResponse, error) {
+	return api.CancelOrderContext(context.Background(), txid)
+}
+
+// CancelOrderContext cancels order, Pending is set when the cancellation is not yet done
+func (api *KrakenAPI) CancelOrderContext(ctx context.Context, txid string) (*CancelOrderResponse, error) {
 	params := url.Values{}
 	params.Add("txid", txid)
-	resp, err := api.queryPrivate("CancelOrder", params, &CancelOrderResponse{})
+	resp, err := api.queryPrivateContext(ctx, "CancelOrder", params, &CancelOrderResponse{})
 
 	if err != nil {
 		return nil, err
@@ -423,6 +541,11 @@ func (api *KrakenAPI) CancelOrder(txid string) (*CancelOrderResponse, error) {
 
 // QueryOrders shows order
 func (api *KrakenAPI) QueryOrders(txids string, args map[string]string) (*QueryOrdersResponse, error) {
+	return api.QueryOrdersContext(context.Background(), txids, args)
+}
+
+// QueryOrdersContext shows the orders with the comma separated txids, at most 50
+func (api *KrakenAPI) QueryOrdersContext(ctx context.Context, txids string, args map[string]string) (*QueryOrdersResponse, error) {
 	params := url.Values{"txid": {txids}}
 	if value, ok := args["trades"]; ok {
 		params.Add("trades", value)
@@ -430,7 +553,7 @@ func (api *KrakenAPI) QueryOrders(txids string, args map[string]string) (*QueryO
 	if value, ok := args["userref"]; ok {
 		params.Add("userref", value)
 	}
-	resp, err := api.queryPrivate("QueryOrders", params, &QueryOrdersResponse{})
+	resp, err := api.queryPrivateContext(ctx, "QueryOrders", params, &QueryOrdersResponse{})
 
 	if err != nil {
 		return nil, err
@@ -440,9 +563,11 @@ func (api *KrakenAPI) QueryOrders(txids string, args map[string]string) (*QueryO
 }
 
 // AddOrder adds new order
+//
+// Deprecated: unknown keys in args are silently dropped, use PlaceOrder with an OrderRequest instead.
 func (api *KrakenAPI) AddOrder(pair string, direction string, orderType string, volume string, args map[string]string) (*AddOrderResponse, error) {
 	params := url.Values{
-		"pair":      {pair},
+		"pair":      {api.pairName(pair)},
 		"type":      {direction},
 		"ordertype": {orderType},
 		"volume":    {volume},
@@ -500,7 +625,7 @@ func (api *KrakenAPI) Ledgers(args map[string]string) (*LedgersResponse, error)
 		params.Add("aclass", value)
 	}
 	if value, ok := args["asset"]; ok {
-		params.Add("asset", value)
+		params.Add("asset", api.assetList(value))
 	}
 	if value, ok := args["type"]; ok {
 		params.Add("type", value)
@@ -525,7 +650,7 @@ func (api *KrakenAPI) Ledgers(args map[string]string) (*LedgersResponse, error)
 // DepositAddresses returns deposit addresses
 func (api *KrakenAPI) DepositAddresses(asset string, method string) (*DepositAddressesResponse, error) {
 	resp, err := api.queryPrivate("DepositAddresses", url.Values{
-		"asset":  {asset},
+		"asset":  {api.assetName(asset)},
 		"method": {method},
 	}, &DepositAddressesResponse{})
 	if err != nil {
@@ -535,9 +660,9 @@ func (api *KrakenAPI) DepositAddresses(asset string, method string) (*DepositAdd
 }
 
 // Withdraw executes a withdrawal, returning a reference ID
-func (api *KrakenAPI) Withdraw(asset string, key string, amount *big.Float) (*WithdrawResponse, error) {
+func (api *KrakenAPI) Withdraw(asset string, key string, amount Decimal) (*WithdrawResponse, error) {
 	resp, err := api.queryPrivate("Withdraw", url.Values{
-		"asset":  {asset},
+		"asset":  {api.assetName(asset)},
 		"key":    {key},
 		"amount": {amount.String()},
 	}, &WithdrawResponse{})
@@ -548,9 +673,9 @@ func (api *KrakenAPI) Withdraw(asset string, key string, amount *big.Float) (*Wi
 }
 
 // WithdrawInfo returns withdrawal information
-func (api *KrakenAPI) WithdrawInfo(asset string, key string, amount *big.Float) (*WithdrawInfoResponse, error) {
+func (api *KrakenAPI) WithdrawInfo(asset string, key string, amount Decimal) (*WithdrawInfoResponse, error) {
 	resp, err := api.queryPrivate("WithdrawInfo", url.Values{
-		"asset":  {asset},
+		"asset":  {api.assetName(asset)},
 		"key":    {key},
 		"amount": {amount.String()},
 	}, &WithdrawInfoResponse{})
@@ -560,6 +685,89 @@ func (api *KrakenAPI) WithdrawInfo(asset string, key string, amount *big.Float)
 	return resp.(*WithdrawInfoResponse), nil
 }
 
+// CreateSubaccount creates a trading subaccount with the given username and email
+func (api *KrakenAPI) CreateSubaccount(username string, email string) (bool, error) {
+	resp, err := api.queryPrivate("CreateSubaccount", url.Values{
+		"username": {username},
+		"email":    {email},
+	}, nil)
+	if err != nil {
+		return false, err
+	}
+
+	created, ok := resp.(bool)
+	if !ok {
+		return false, fmt.Errorf("unexpected CreateSubaccount result: %v", resp)
+	}
+	return created, nil
+}
+
+// GetWebSocketsToken returns a token to authenticate on the private WebSocket feeds
+func (api *KrakenAPI) GetWebSocketsToken() (*WebSocketsTokenResponse, error) {
+	return api.GetWebSocketsTokenContext(context.Background())
+}
+
+// GetWebSocketsTokenContext returns a token to authenticate on the private WebSocket feeds
+func (api *KrakenAPI) GetWebSocketsTokenContext(ctx context.Context) (*WebSocketsTokenResponse, error) {
+	resp, err := api.queryPrivateContext(ctx, "GetWebSocketsToken", url.Values{}, &WebSocketsTokenResponse{})
+	if err != nil {
+		return nil, err
+	}
+
+	return resp.(*WebSocketsTokenResponse), nil
+}
+
+// AccountTransfer moves funds between the master account and its subaccounts
+func (api *KrakenAPI) AccountTransfer(asset string, amount Decimal, from string, to string) (*AccountTransferResponse, error) {
+	resp, err := api.queryPrivate("AccountTransfer", url.Values{
+		"asset":  {api.assetName(asset)},
+		"amount": {amount.String()},
+		"from":   {from},
+		"to":     {to},
+	}, &AccountTransferResponse{})
+	if err != nil {
+		return nil, err
+	}
+	return resp.(*AccountTransferResponse), nil
+}
+
+// queryPairResult executes a public per-pair query like OHLC or Trades. Kraken returns
+// the data under the pair's canonical name, which is decoded into rows, next to a "last" cursor.
+func (api *KrakenAPI) queryPairResult(ctx context.Context, method string, pair string, values url.Values, rows interface{}) (int64, error) {
+	result := map[string]json.RawMessage{}
+	_, err := api.queryPublicGetContext(ctx, method, values, &result)
+	if err != nil {
+		return 0, err
+	}
+
+	keys := make([]string, 0, len(result))
+	for key := range result {
+		keys = append(keys, key)
+	}
+	key, found := api.resultKey(pair, keys)
+	if !found {
+		return 0, fmt.Errorf("Could not decode %s: no data for %s in response", method, pair)
+	}
+	if err := json.Unmarshal(result[key], rows); err != nil {
+		return 0, fmt.Errorf("Could not decode %s: %s", method, err)
+	}
+
+	lastData, found := result["last"]
+	if !found {
+		return 0, fmt.Errorf("Could not decode %s: missing last", method)
+	}
+	var last json.Number
+	if err := json.Unmarshal(lastData, &last); err != nil {
+		return 0, fmt.Errorf("Could not decode %s: invalid last %s", method, lastData)
+	}
+	cursor, err := last.Int64()
+	if err != nil {
+		return 0, fmt.Errorf("Could not decode %s: invalid last %s", method, lastData)
+	}
+
+	return cursor, nil
+}
+
 // Query sends a query to Kraken api for given method and parameters
 func (api *KrakenAPI) Query(method string, data map[string]string) (interface{}, error) {
 	values := url.Values{}
@@ -580,18 +788,38 @@ func (api *KrakenAPI) Query(method string, data map[string]string) (interface{},
 // Execute a public method query
 func (api *KrakenAPI) queryPublicPost(method string, values url.Values, typ interface{}) (interface{}, error) {
 	url := fmt.Sprintf("%s/%s/public/%s", APIURL, APIVersion, method)
-	resp, err := api.doPost(url, values, nil, typ)
+	if err := api.wait(context.Background(), method); err != nil {
+		return nil, err
+	}
+	resp, err := api.doPost(context.Background(), url, values, nil, typ)
 
 	return resp, err
 }
 
 func (api *KrakenAPI) queryPublicGet(reqURL string, values url.Values, typ interface{}) (interface{}, error) {
-	url := fmt.Sprintf("%s/%s/public/%s", APIURL, APIVersion, reqURL)
-	return api.doGet(url, values, nil, typ)
+	return api.queryPublicGetContext(context.Background(), reqURL, values, typ)
+}
+
+// queryPublicGetContext executes a public method query bound to ctx
+func (api *KrakenAPI) queryPublicGetContext(ctx context.Context, method string, values url.Values, typ interface{}) (interface{}, error) {
+	if err := api.wait(ctx, method); err != nil {
+		return nil, err
+	}
+	url := fmt.Sprintf("%s/%s/public/%s", APIURL, APIVersion, method)
+	return api.doGet(ctx, url, values, nil, typ)
 }
 
 // queryPrivate executes a private method query
 func (api *KrakenAPI) queryPrivate(method string, values url.Values, typ interface{}) (interface{}, error) {
+	return api.queryPrivateContext(context.Background(), method, values, typ)
+}
+
+// queryPrivateContext executes a private method query bound to ctx
+func (api *KrakenAPI) queryPrivateContext(ctx context.Context, method string, values url.Values, typ interface{}) (interface{}, error) {
+	if err := api.wait(ctx, method); err != nil {
+		return nil, err
+	}
+
 	urlPath := fmt.Sprintf("/%s/private/%s", APIVersion, method)
 	reqURL := fmt.Sprintf("%s%s", APIURL, urlPath)
 	secret, _ := base64.StdEncoding.DecodeString(api.secret)
@@ -606,16 +834,24 @@ func (api *KrakenAPI) queryPrivate(method string, values url.Values, typ interfa
 		"API-Sign": signature,
 	}
 
-	resp, err := api.doPost(reqURL, values, headers, typ)
+	resp, err := api.doPost(ctx, reqURL, values, headers, typ)
 
 	return resp, err
 }
 
-func (api *KrakenAPI) doGet(reqURL string, values url.Values, headers map[string]string, typ interface{}) (interface{}, error) {
+// wait blocks until the rate limiter allows calling method
+func (api *KrakenAPI) wait(ctx context.Context, method string) error {
+	if api.limiter == nil {
+		return ctx.Err()
+	}
+	return api.limiter.Wait(ctx, method)
+}
+
+func (api *KrakenAPI) doGet(ctx context.Context, reqURL string, values url.Values, headers map[string]string, typ interface{}) (interface{}, error) {
 	encodedValues := values.Encode()
 	fullURL := reqURL + "?" + encodedValues
 
-	req, err := http.NewRequest("GET", fullURL, nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", fullURL, nil)
 	if err != nil {
 		return nil, fmt.Errorf("Could not execute request! #1 (%s)", err.Error())
 	}
@@ -624,10 +860,10 @@ func (api *KrakenAPI) doGet(reqURL string, values url.Values, headers map[string
 }
 
 // doPost executes a HTTP Request to the Kraken API and returns the result
-func (api *KrakenAPI) doPost(reqURL string, values url.Values, headers map[string]string, typ interface{}) (interface{}, error) {
+func (api *KrakenAPI) doPost(ctx context.Context, reqURL string, values url.Values, headers map[string]string, typ interface{}) (interface{}, error) {
 
 	// Create request
-	req, err := http.NewRequest("POST", reqURL, strings.NewReader(values.Encode()))
+	req, err := http.NewRequestWithContext(ctx, "POST", reqURL, strings.NewReader(values.Encode()))
 	if err != nil {
 		return nil, fmt.Errorf("Could not execute request! #1 (%s)", err.Error())
 	}
@@ -636,13 +872,42 @@ func (api *KrakenAPI) doPost(reqURL string, values url.Values, headers map[strin
 }
 
 func (api *KrakenAPI) doAPIRequest(req *http.Request, headers map[string]string, typ interface{}) (interface{}, error) {
+	body, err := doHTTPRequest(api.client, req, headers)
+	if err != nil {
+		return nil, err
+	}
+
+	// Parse request
+	var jsonData KrakenResponse
+
+	// Set the KrakenResponse.Result to typ so `json.Unmarshal` will
+	// unmarshal it into given type, instead of `interface{}`.
+	if typ != nil {
+		jsonData.Result = typ
+	}
+
+	err = json.Unmarshal(body, &jsonData)
+	if err != nil {
+		return nil, fmt.Errorf("Could not execute request! #6 (%s)", err.Error())
+	}
+
+	// Check for Kraken API error
+	if len(jsonData.Error) > 0 {
+		return nil, fmt.Errorf("Could not execute request! #7 (%s)", jsonData.Error)
+	}
+
+	return jsonData.Result, nil
+}
+
+// doHTTPRequest executes req with client and returns the JSON body of the response
+func doHTTPRequest(client *http.Client, req *http.Request, headers map[string]string) ([]byte, error) {
 	req.Header.Add("User-Agent", APIUserAgent)
 	for key, value := range headers {
 		req.Header.Add(key, value)
 	}
 
 	// Execute request
-	resp, err := api.client.Do(req)
+	resp, err := client.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("Could not execute request! #2 (%s)", err.Error())
 	}
@@ -663,26 +928,7 @@ func (api *KrakenAPI) doAPIRequest(req *http.Request, headers map[string]string,
 		return nil, fmt.Errorf("Could not execute request #5! (%s)", fmt.Sprintf("Response Content-Type is '%s', but should be 'application/json'.", mimeType))
 	}
 
-	// Parse request
-	var jsonData KrakenResponse
-
-	// Set the KrakenResponse.Result to typ so `json.Unmarshal` will
-	// unmarshal it into given type, instead of `interface{}`.
-	if typ != nil {
-		jsonData.Result = typ
-	}
-
-	err = json.Unmarshal(body, &jsonData)
-	if err != nil {
-		return nil, fmt.Errorf("Could not execute request! #6 (%s)", err.Error())
-	}
-
-	// Check for Kraken API error
-	if len(jsonData.Error) > 0 {
-		return nil, fmt.Errorf("Could not execute request! #7 (%s)", jsonData.Error)
-	}
-
-	return jsonData.Result, nil
+	return body, nil
 }
 
 // isStringInSlice is a helper function to test if given term is in a list of strings
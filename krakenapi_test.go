@@ -2,13 +2,87 @@ package krakenapi
 
 import (
 	"encoding/base64"
+	"io/ioutil"
+	"net/http"
 	"net/url"
+	"path"
 	"reflect"
+	"strings"
+	"sync"
 	"testing"
 )
 
 var publicAPI = New("", "")
 
+// testRequest is a request received by a test client
+type testRequest struct {
+	*http.Request
+	// Last element of the URL path, e.g. "AddOrder"
+	Endpoint string
+	// Body as sent
+	Data []byte
+	// Query and form parameters
+	Values url.Values
+}
+
+// testTransport answers requests with answer instead of the network and sends them to
+// requests unless it is nil
+type testTransport struct {
+	answer   func(req testRequest) string
+	requests chan<- testRequest
+}
+
+func (tr testTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	recorded := testRequest{Request: req, Endpoint: path.Base(req.URL.Path), Values: req.URL.Query()}
+	if req.Body != nil {
+		recorded.Data, _ = ioutil.ReadAll(req.Body)
+		form, _ := url.ParseQuery(string(recorded.Data))
+		for key, value := range form {
+			recorded.Values[key] = value
+		}
+	}
+	if tr.requests != nil {
+		tr.requests <- recorded
+	}
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": {"application/json"}},
+		Body:       ioutil.NopCloser(strings.NewReader(tr.answer(recorded))),
+	}, nil
+}
+
+// testClient returns an HTTP client answering every request with body
+func testClient(body string, requests chan<- testRequest) *http.Client {
+	return &http.Client{Transport: testTransport{answer: func(testRequest) string { return body }, requests: requests}}
+}
+
+// scriptedClient returns an HTTP client answering each endpoint with the next of its
+// bodies, endpoints without bodies of their own use the bodies of "". Running out of
+// bodies fails the test.
+func scriptedClient(t *testing.T, bodies map[string][]string, requests chan<- testRequest) *http.Client {
+	var mu sync.Mutex
+	return &http.Client{Transport: testTransport{answer: func(req testRequest) string {
+		mu.Lock()
+		defer mu.Unlock()
+		endpoint := req.Endpoint
+		if _, found := bodies[endpoint]; !found {
+			endpoint = ""
+		}
+		if len(bodies[endpoint]) == 0 {
+			t.Errorf("unexpected request %s", req.URL)
+			return `{"error":["EGeneral:Internal error"]}`
+		}
+		body := bodies[endpoint][0]
+		bodies[endpoint] = bodies[endpoint][1:]
+		return body
+	}, requests: requests}}
+}
+
+// newTestAPI returns a client that answers every request with the given JSON body
+func newTestAPI(body string) *KrakenAPI {
+	return NewWithClient("", "", testClient(body, nil))
+}
+
 func TestKrakenApi(t *testing.T) {
 	var kk interface{} = KrakenApi{
 		key:    "key",
@@ -65,13 +139,39 @@ func TestAssetPairs(t *testing.T) {
 	}
 }
 
+func TestAssetsWithClass(t *testing.T) {
+	resp, err := publicAPI.AssetsWithClass("currency", "XBT", "ETH")
+	if err != nil {
+		t.Errorf("AssetsWithClass() should not return an error, got %s", err)
+	}
+
+	if _, found := resp["XXBT"]; !found {
+		t.Errorf("AssetsWithClass() should return XXBT, got %+v", resp)
+	}
+}
+
+func TestAssetPairsWithInfo(t *testing.T) {
+	resp, err := publicAPI.AssetPairsWithInfo("", InfoMargin, XXBTZEUR)
+	if err != nil {
+		t.Errorf("AssetPairsWithInfo() should not return an error, got %s", err)
+	}
+
+	if len(resp) != 1 || resp[XXBTZEUR].MarginCall == 0 {
+		t.Errorf("AssetPairsWithInfo() should return margin info for %s, got %+v", XXBTZEUR, resp)
+	}
+
+	if _, err := publicAPI.AssetPairsWithInfo("", "volume"); err == nil {
+		t.Errorf("AssetPairsWithInfo() should reject unknown info levels")
+	}
+}
+
 func TestTicker(t *testing.T) {
 	resp, err := publicAPI.Ticker(XXBTZEUR, XXRPZEUR)
 	if err != nil {
 		t.Errorf("Ticker() should not return an error, got %s", err)
 	}
 
-	if resp.XXBTZEUR.OpeningPrice == 0 {
+	if resp.XXBTZEUR.OpeningPrice.IsZero() {
 		t.Errorf("Ticker() should return valid OpeningPrice, got %+v", resp.XXBTZEUR.OpeningPrice)
 	}
 }
@@ -170,3 +270,99 @@ func TestQueryDepth(t *testing.T) {
 		t.Errorf("Bids length must be less than count , got %d > %d", len(result.Bids), count)
 	}
 }
+
+func TestOHLCWithIntervalDecoding(t *testing.T) {
+	api := newTestAPI(`{"error":[],"result":{"XXBTZUSD":[[1688671200,"30306.1","30306.2","30305.7","30305.7","30306.1","3.39243896",23]],"last":1688672160}}`)
+	resp, err := api.OHLCWithInterval("XBTUSD", "1")
+	if err != nil {
+		t.Fatalf("OHLCWithInterval() should not return an error, got %s", err)
+	}
+	if len(resp.OHLC) != 1 || resp.OHLC[0].Volume.String() != "3.39243896" || resp.OHLC[0].Count != 23 || resp.Last != 1688672160 {
+		t.Errorf("OHLCWithInterval() decoded wrong values, got %+v", resp.OHLC[0])
+	}
+
+	malformed := []string{
+		`{"error":[],"result":{"XXBTZUSD":[[1688671200,30306.1,"30306.2","30305.7","30305.7","30306.1","3.39243896",23]],"last":1688672160}}`,
+		`{"error":[],"result":{"XXBTZUSD":[[1688671200,"abc","30306.2","30305.7","30305.7","30306.1","3.39243896",23]],"last":1688672160}}`,
+		`{"error":[],"result":{"XXBTZUSD":{"open":"1"},"last":1688672160}}`,
+		`{"error":[],"result":{"XXBTZUSD":[],"XETHZUSD":[],"last":1688672160}}`,
+		`{"error":[],"result":{"XXBTZUSD":[],"since":1688672160}}`,
+	}
+	for _, body := range malformed {
+		if _, err := newTestAPI(body).OHLCWithInterval("XBTUSD", "1"); err == nil {
+			t.Errorf("OHLCWithInterval() should return an error for %s", body)
+		}
+	}
+}
+
+func TestTradesDecoding(t *testing.T) {
+	api := newTestAPI(`{"error":[],"result":{"XXBTZUSD":[["30243.40000","0.34507674",1688669597.8277369,"b","m","",61044952]],"last":"1688671969993150842"}}`)
+	resp, err := api.Trades("XBTUSD", 0)
+	if err != nil {
+		t.Fatalf("Trades() should not return an error, got %s", err)
+	}
+	if resp.Last != 1688671969993150842 || len(resp.Trades) != 1 || !resp.Trades[0].Buy || !resp.Trades[0].Market {
+		t.Errorf("Trades() decoded wrong values, got %+v", resp)
+	}
+	if trade := resp.Trades[0]; trade.Price.String() != "30243.4" || trade.Volume.String() != "0.34507674" {
+		t.Errorf("Trades() should decode exact prices and volumes, got %+v", trade)
+	}
+
+	malformed := []string{
+		`{"error":[],"result":{"XXBTZUSD":[["30243.40000","0.34507674",1688669597.8277369,"b"]],"last":"1688671969993150842"}}`,
+		`{"error":[],"result":{"XXBTZUSD":[["30243.40000","0.34507674","1688669597","b","m",""]],"last":"1688671969993150842"}}`,
+		`{"error":[],"result":{"XXBTZUSD":[],"last":"abc"}}`,
+	}
+	for _, body := range malformed {
+		if _, err := newTestAPI(body).Trades("XBTUSD", 0); err == nil {
+			t.Errorf("Trades() should return an error for %s", body)
+		}
+	}
+}
+
+func TestAssetPairsWithClass(t *testing.T) {
+	sent := make(chan testRequest, 1)
+	api := NewWithClient("", "", testClient(`{"error":[],"result":{}}`, sent))
+	if _, err := api.AssetPairsWithInfo("tokenized_asset", InfoFees, "AAPLxUSD"); err != nil {
+		t.Fatalf("AssetPairsWithInfo() should succeed, got %s", err)
+	}
+	req := <-sent
+	if values := req.Values; req.Endpoint != "AssetPairs" || values.Get("aclass_base") != "tokenized_asset" || values.Get("info") != InfoFees || values.Get("pair") != "AAPLxUSD" {
+		t.Errorf("AssetPairsWithInfo() sent %v", req.Values)
+	}
+
+	if _, err := api.AssetPairsWithInfo("", ""); err != nil {
+		t.Fatalf("AssetPairsWithInfo() should succeed, got %s", err)
+	}
+	if req := <-sent; req.Values["aclass_base"] != nil {
+		t.Errorf("AssetPairsWithInfo() should omit an empty aclass_base, sent %v", req.Values)
+	}
+}
+
+func TestCreateSubaccount(t *testing.T) {
+	sent := make(chan testRequest, 1)
+	created, err := NewWithClient("", "", testClient(`{"error":[],"result":true}`, sent)).CreateSubaccount("trader1", "trader1@example.com")
+	if err != nil || !created {
+		t.Fatalf("CreateSubaccount() should succeed, got %v (%v)", created, err)
+	}
+	req := <-sent
+	if values := req.Values; req.Endpoint != "CreateSubaccount" || values.Get("username") != "trader1" || values.Get("email") != "trader1@example.com" || values.Get("nonce") == "" {
+		t.Errorf("CreateSubaccount() sent %v", req.Values)
+	}
+}
+
+func TestAccountTransfer(t *testing.T) {
+	sent := make(chan testRequest, 1)
+	resp, err := NewWithClient("", "", testClient(`{"error":[],"result":{"transfer_id":"TOH3AS2-LPCWR8-JDQGEU","status":"complete"}}`, sent)).
+		AccountTransfer("XBT", MustDecimal("1.25"), "ABCD 1234 EFGH 5678", "IJKL 0000 MNOP 9999")
+	if err != nil {
+		t.Fatalf("AccountTransfer() should succeed, got %s", err)
+	}
+	req := <-sent
+	if values := req.Values; req.Endpoint != "AccountTransfer" || values.Get("asset") != "XBT" || values.Get("amount") != "1.25" || values.Get("from") != "ABCD 1234 EFGH 5678" || values.Get("to") != "IJKL 0000 MNOP 9999" {
+		t.Errorf("AccountTransfer() sent %v", req.Values)
+	}
+	if resp.TransferID != "TOH3AS2-LPCWR8-JDQGEU" || !resp.IsComplete() || resp.IsPending() {
+		t.Errorf("AccountTransfer() decoded %+v", resp)
+	}
+}
@@ -0,0 +1,542 @@
+package krakenapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultOrderPollInterval is the pause between QueryOrders polls of an OrderManager
+const DefaultOrderPollInterval = 5 * time.Second
+
+// queryOrdersLimit is the maximum number of orders per QueryOrders call
+const queryOrdersLimit = 50
+
+// fillPriceDecimals is the precision of the fill and average prices computed from costs
+const fillPriceDecimals = 16
+
+// OrderState is the state of an order tracked by an OrderManager
+type OrderState string
+
+// Order manager states
+const (
+	OrderStateSubmitted       OrderState = "submitted"
+	OrderStateOpen            OrderState = "open"
+	OrderStatePartiallyFilled OrderState = "partially_filled"
+	OrderStateFilled          OrderState = "filled"
+	OrderStateCanceled        OrderState = "canceled"
+	OrderStateExpired         OrderState = "expired"
+	OrderStateRejected        OrderState = "rejected"
+)
+
+// IsFinal reports whether an order in state s can no longer change
+func (s OrderState) IsFinal() bool {
+	switch s {
+	case OrderStateFilled, OrderStateCanceled, OrderStateExpired, OrderStateRejected:
+		return true
+	}
+	return false
+}
+
+// rank orders the states, an order only moves to a state of a higher rank
+func (s OrderState) rank() int {
+	switch s {
+	case OrderStateSubmitted:
+		return 0
+	case OrderStateOpen:
+		return 1
+	case OrderStatePartiallyFilled:
+		return 2
+	}
+	return 3
+}
+
+// ManagedOrder is the state of an order submitted through an OrderManager
+type ManagedOrder struct {
+	// Empty for rejected orders
+	TransactionID  string
+	UserRef        int32
+	Pair           string
+	Side           Side
+	OrderType      OrderType
+	Volume         Decimal
+	VolumeExecuted Decimal
+	Cost           Decimal
+	Fee            Decimal
+	// Average price of the fills, zero without fills
+	AveragePrice Decimal
+	State        OrderState
+	// Kraken accepted a cancellation which is not done yet
+	CancelPending bool
+	// Reason of a cancellation or rejection
+	Reason    string
+	UpdatedAt time.Time
+}
+
+// OrderFill is an execution of a managed order. Fills are derived from the executed
+// volume Kraken reports, a fill may combine several trades.
+type OrderFill struct {
+	TransactionID string
+	Volume        Decimal
+	// Average price of the fill
+	Price Decimal
+	Cost  Decimal
+	Fee   Decimal
+	Time  time.Time
+}
+
+// OrderEvent reports a change of a managed order
+type OrderEvent struct {
+	Order ManagedOrder
+	// Previous state of the order
+	Previous OrderState
+	// Set when the change is a fill
+	Fill *OrderFill
+	// Set instead of Order when a poll failed, it is retried at the next interval
+	Err error
+}
+
+// OrderManager submits orders and tracks them through their states until they are
+// final. It follows the openOrders channel of a WebSocket when one is given, and
+// polls QueryOrders otherwise. Every order carries the manager's user reference,
+// which Reconcile uses to pick up the orders of an earlier run.
+// Events must be read, the manager waits for the reader.
+type OrderManager struct {
+	api      *KrakenAPI
+	userRef  int32
+	source   WSSource
+	interval time.Duration
+	events   chan OrderEvent
+
+	mu     sync.Mutex
+	orders map[string]*ManagedOrder
+}
+
+// NewOrderManager creates an OrderManager placing orders with userRef through api
+func NewOrderManager(api *KrakenAPI, userRef int32) *OrderManager {
+	return &OrderManager{
+		api:      api,
+		userRef:  userRef,
+		interval: DefaultOrderPollInterval,
+		events:   make(chan OrderEvent, 16),
+		orders:   map[string]*ManagedOrder{},
+	}
+}
+
+// WithWebSocket makes Run follow the openOrders channel of source instead of polling.
+// source must be authenticated, e.g. a WSSupervisor with an API, and Run must be its
+// only reader. Orders are polled once after subscribing, after every reconnect and
+// after every sequence gap.
+func (m *OrderManager) WithWebSocket(source WSSource) *OrderManager {
+	m.source = source
+	return m
+}
+
+// WithPollInterval changes the pause between polls without a WebSocket
+func (m *OrderManager) WithPollInterval(interval time.Duration) *OrderManager {
+	m.interval = interval
+	return m
+}
+
+// Events returns the changes of the managed orders
+func (m *OrderManager) Events() <-chan OrderEvent {
+	return m.events
+}
+
+// Order returns the managed order txid
+func (m *OrderManager) Order(txid string) (ManagedOrder, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	order, ok := m.orders[txid]
+	if !ok {
+		return ManagedOrder{}, false
+	}
+	return *order, true
+}
+
+// Orders returns all managed orders, oldest update first
+func (m *OrderManager) Orders() []ManagedOrder {
+	m.mu.Lock()
+	orders := make([]ManagedOrder, 0, len(m.orders))
+	for _, order := range m.orders {
+		orders = append(orders, *order)
+	}
+	m.mu.Unlock()
+
+	sort.Slice(orders, func(i, j int) bool {
+		return orders[i].UpdatedAt.Before(orders[j].UpdatedAt)
+	})
+	return orders
+}
+
+// Submit places req with the manager's user reference. An order refused by Kraken is
+// returned in OrderStateRejected together with the error. When Kraken's answer is lost,
+// only the error is returned and the order is found by the next Reconcile.
+func (m *OrderManager) Submit(ctx context.Context, req *OrderRequest) (ManagedOrder, error) {
+	if req.Validate {
+		return ManagedOrder{}, errors.New("validate only orders cannot be managed")
+	}
+	if req.ClientOrderID != "" || (req.UserRef != 0 && req.UserRef != m.userRef) {
+		return ManagedOrder{}, fmt.Errorf("managed orders carry the user reference %d", m.userRef)
+	}
+	r := *req
+	r.UserRef = m.userRef
+
+	order := &ManagedOrder{
+		UserRef:   m.userRef,
+		Pair:      r.Pair,
+		Side:      r.Side,
+		OrderType: r.OrderType,
+		Volume:    r.Volume,
+		State:     OrderStateSubmitted,
+		UpdatedAt: time.Now(),
+	}
+	resp, err := m.api.PlaceOrderContext(ctx, &r)
+	if err != nil {
+		if !isOrderRejection(err) {
+			return ManagedOrder{}, err
+		}
+		order.State = OrderStateRejected
+		order.Reason = err.Error()
+		if !m.emit(ctx, OrderEvent{Order: *order, Previous: OrderStateSubmitted}) {
+			return *order, ctx.Err()
+		}
+		return *order, err
+	}
+	if len(resp.TransactionIds) == 0 {
+		return ManagedOrder{}, errors.New("AddOrder returned no transaction ID")
+	}
+	order.TransactionID = resp.TransactionIds[0]
+
+	m.mu.Lock()
+	// A WebSocket update may have been first
+	if tracked, ok := m.orders[order.TransactionID]; ok {
+		order = tracked
+	} else {
+		m.orders[order.TransactionID] = order
+	}
+	submitted := *order
+	m.mu.Unlock()
+
+	if !m.emit(ctx, OrderEvent{Order: submitted, Previous: OrderStateSubmitted}) {
+		return submitted, ctx.Err()
+	}
+	return submitted, nil
+}
+
+// Cancel requests the cancellation of the managed order txid. When Kraken cannot cancel
+// it at once the order is marked CancelPending until an update reports the final state.
+func (m *OrderManager) Cancel(ctx context.Context, txid string) error {
+	if _, ok := m.Order(txid); !ok {
+		return fmt.Errorf("order %s is not managed", txid)
+	}
+	resp, err := m.api.CancelOrderContext(ctx, txid)
+	if err != nil {
+		// The order may have been closed meanwhile, fetch its state
+		if pollErr := m.poll(ctx, []string{txid}); pollErr != nil {
+			return pollErr
+		}
+		return err
+	}
+	if !resp.Pending {
+		return m.poll(ctx, []string{txid})
+	}
+
+	m.mu.Lock()
+	order := m.orders[txid]
+	var events []OrderEvent
+	if !order.State.IsFinal() && !order.CancelPending {
+		order.CancelPending = true
+		order.UpdatedAt = time.Now()
+		events = append(events, OrderEvent{Order: *order, Previous: order.State})
+	}
+	m.mu.Unlock()
+	return m.emitAll(ctx, events)
+}
+
+// Poll queries all managed orders which are not final
+func (m *OrderManager) Poll(ctx context.Context) error {
+	m.mu.Lock()
+	var txids []string
+	for txid, order := range m.orders {
+		if !order.State.IsFinal() {
+			txids = append(txids, txid)
+		}
+	}
+	m.mu.Unlock()
+
+	sort.Strings(txids)
+	return m.poll(ctx, txids)
+}
+
+// poll queries the orders txids and applies their states
+func (m *OrderManager) poll(ctx context.Context, txids []string) error {
+	for start := 0; start < len(txids); start += queryOrdersLimit {
+		end := start + queryOrdersLimit
+		if end > len(txids) {
+			end = len(txids)
+		}
+		resp, err := m.api.QueryOrdersContext(ctx, strings.Join(txids[start:end], ","), nil)
+		if err != nil {
+			return err
+		}
+		orders := make([]Order, 0, len(*resp))
+		for txid, order := range *resp {
+			order.TransactionID = txid
+			orders = append(orders, order)
+		}
+		if err := m.apply(ctx, orders, false); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Reconcile picks up the orders with the manager's user reference, the open ones and
+// those closed after since, e.g. after a restart. Orders already managed are updated.
+func (m *OrderManager) Reconcile(ctx context.Context, since time.Time) error {
+	userRef := strconv.FormatInt(int64(m.userRef), 10)
+	resp, err := m.api.OpenOrdersContext(ctx, map[string]string{"userref": userRef})
+	if err != nil {
+		return err
+	}
+	orders := make([]Order, 0, len(resp.Open))
+	for txid, order := range resp.Open {
+		order.TransactionID = txid
+		orders = append(orders, order)
+	}
+
+	closed := m.api.IterateClosedOrders(ctx, PageOptions{Start: since, Args: map[string]string{"userref": userRef}})
+	for closed.Next() {
+		orders = append(orders, closed.Order())
+	}
+	if err := closed.Err(); err != nil {
+		return err
+	}
+
+	// Apply oldest first so the events follow the order history
+	sort.Slice(orders, func(i, j int) bool {
+		return orders[i].OpenTime < orders[j].OpenTime
+	})
+	return m.apply(ctx, orders, true)
+}
+
+// Apply updates the managed orders from an event of the openOrders channel. Run calls
+// it with a WebSocket, call it when the events are read elsewhere, e.g. from a WSHub.
+// Other events are ignored.
+func (m *OrderManager) Apply(ctx context.Context, event WSEvent) error {
+	e, ok := event.(*OpenOrdersEvent)
+	if !ok {
+		return nil
+	}
+	return m.apply(ctx, e.Orders, true)
+}
+
+// Run keeps the managed orders up to date until ctx is done or the WebSocket is closed
+func (m *OrderManager) Run(ctx context.Context) error {
+	if m.source == nil {
+		return m.runPolling(ctx)
+	}
+
+	if err := m.source.Subscribe(ctx, Subscription{Name: ChannelOpenOrders}); err != nil {
+		return err
+	}
+	// Changes made before the subscription are only seen by polling
+	if err := m.pollOrReport(ctx); err != nil {
+		return err
+	}
+	for {
+		select {
+		case event, ok := <-m.source.Events():
+			if !ok {
+				return ErrWebSocketClosed
+			}
+			var err error
+			switch e := event.(type) {
+			case *OpenOrdersEvent:
+				err = m.Apply(ctx, e)
+			case *ConnectionEvent:
+				if e.Connected {
+					err = m.pollOrReport(ctx)
+				}
+			case *SequenceGapEvent:
+				if e.Name == ChannelOpenOrders {
+					err = m.pollOrReport(ctx)
+				}
+			}
+			if err != nil {
+				return err
+			}
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// runPolling polls the managed orders every interval
+func (m *OrderManager) runPolling(ctx context.Context) error {
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := m.pollOrReport(ctx); err != nil {
+				return err
+			}
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// pollOrReport polls the managed orders and reports a failed poll as event,
+// it only returns an error when ctx is done
+func (m *OrderManager) pollOrReport(ctx context.Context) error {
+	err := m.Poll(ctx)
+	if err == nil || ctx.Err() != nil {
+		return ctx.Err()
+	}
+	if !m.emit(ctx, OrderEvent{Err: err}) {
+		return ctx.Err()
+	}
+	return nil
+}
+
+// apply merges orders reported by Kraken into the managed orders. Unknown orders with
+// the manager's user reference are adopted if adopt is set.
+func (m *OrderManager) apply(ctx context.Context, orders []Order, adopt bool) error {
+	var events []OrderEvent
+	m.mu.Lock()
+	for _, reported := range orders {
+		order, ok := m.orders[reported.TransactionID]
+		if !ok {
+			if !adopt || int32(reported.UserRef) != m.userRef {
+				continue
+			}
+			order = &ManagedOrder{TransactionID: reported.TransactionID, UserRef: m.userRef, State: OrderStateSubmitted}
+			m.orders[reported.TransactionID] = order
+		}
+		events = append(events, order.update(reported)...)
+	}
+	m.mu.Unlock()
+	return m.emitAll(ctx, events)
+}
+
+// update merges an order reported by Kraken, which may only carry the changed fields,
+// and returns the resulting events
+func (o *ManagedOrder) update(reported Order) []OrderEvent {
+	var events []OrderEvent
+	now := time.Now()
+
+	if o.Pair == "" {
+		o.Pair = reported.Description.AssetPair
+		o.Side = reported.Description.Type
+		o.OrderType = reported.Description.OrderType
+	}
+	if !reported.Volume.IsZero() {
+		o.Volume = reported.Volume
+	}
+	if reported.Reason != "" {
+		o.Reason = reported.Reason
+	}
+
+	if reported.VolumeExecuted.GreaterThan(o.VolumeExecuted) {
+		fill := &OrderFill{
+			TransactionID: o.TransactionID,
+			Volume:        reported.VolumeExecuted.Sub(o.VolumeExecuted),
+			Cost:          reported.Cost.Sub(o.Cost),
+			Fee:           reported.Fee.Sub(o.Fee),
+			Time:          now,
+		}
+		fill.Price = fill.Cost.Div(fill.Volume, fillPriceDecimals)
+
+		o.VolumeExecuted = reported.VolumeExecuted
+		o.Cost = reported.Cost
+		o.Fee = reported.Fee
+		o.AveragePrice = reported.Price
+		if o.AveragePrice.IsZero() {
+			o.AveragePrice = o.Cost.Div(o.VolumeExecuted, fillPriceDecimals)
+		}
+		previous := o.State
+		if next := o.nextState(reported.Status); next == OrderStatePartiallyFilled {
+			o.State = next
+		}
+		o.UpdatedAt = now
+		events = append(events, OrderEvent{Order: *o, Previous: previous, Fill: fill})
+	}
+
+	if next := o.nextState(reported.Status); next.rank() > o.State.rank() {
+		previous := o.State
+		o.State = next
+		if next.IsFinal() {
+			o.CancelPending = false
+		}
+		o.UpdatedAt = now
+		events = append(events, OrderEvent{Order: *o, Previous: previous})
+	}
+	return events
+}
+
+// nextState returns the state of the order with the reported status, an empty status
+// keeps the current state
+func (o *ManagedOrder) nextState(status OrderStatus) OrderState {
+	switch status {
+	case OrderStatusPending:
+		return OrderStateSubmitted
+	case OrderStatusClosed:
+		return OrderStateFilled
+	case OrderStatusCanceled:
+		return OrderStateCanceled
+	case OrderStatusExpired:
+		return OrderStateExpired
+	case OrderStatusOpen, "":
+		if o.State.IsFinal() || (status == "" && o.State == OrderStateSubmitted && o.VolumeExecuted.IsZero()) {
+			return o.State
+		}
+		if o.VolumeExecuted.Sign() > 0 {
+			return OrderStatePartiallyFilled
+		}
+		return OrderStateOpen
+	}
+	return o.State
+}
+
+// emit passes event to the reader, it returns false when ctx is done
+func (m *OrderManager) emit(ctx context.Context, event OrderEvent) bool {
+	select {
+	case m.events <- event:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// emitAll passes events to the reader
+func (m *OrderManager) emitAll(ctx context.Context, events []OrderEvent) error {
+	for _, event := range events {
+		if !m.emit(ctx, event) {
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+// rejectionErrors are the Kraken errors which guarantee that an order was not placed
+var rejectionErrors = []string{"EOrder:", "EFunding:", "EGeneral:Invalid arguments", "EAPI:Invalid nonce"}
+
+// isOrderRejection reports whether Kraken refused an order, as opposed to errors like
+// "EGeneral:Internal error" which leave it unknown whether the order was placed
+func isOrderRejection(err error) bool {
+	msg := err.Error()
+	for _, rejection := range rejectionErrors {
+		if strings.Contains(msg, rejection) {
+			return true
+		}
+	}
+	return false
+}
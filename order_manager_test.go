@@ -0,0 +1,194 @@
+package krakenapi
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestOrderAPI returns a client that answers each method with the next of its bodies
+func newTestOrderAPI(t *testing.T, bodies map[string][]string) *KrakenAPI {
+	return NewWithClient("", "", scriptedClient(t, bodies, nil))
+}
+
+// nextOrderEvent returns the next buffered event of m
+func nextOrderEvent(t *testing.T, m *OrderManager) OrderEvent {
+	t.Helper()
+	select {
+	case event := <-m.Events():
+		return event
+	default:
+		t.Fatal("expected an order event")
+	}
+	return OrderEvent{}
+}
+
+func TestOrderManager(t *testing.T) {
+	ctx := context.Background()
+	api := newTestOrderAPI(t, map[string][]string{
+		"AddOrder": {
+			`{"error":[],"result":{"descr":{"order":"buy 0.5 XBTUSD @ limit 27.5"},"txid":["OABC-1"]}}`,
+			`{"error":["EOrder:Insufficient funds"]}`,
+		},
+		"QueryOrders": {
+			`{"error":[],"result":{"OABC-1":{"userref":7,"status":"open","opentm":1700000000,"descr":{"pair":"XBTUSD","type":"buy","ordertype":"limit","price":"27.5"},"vol":"0.5","vol_exec":"0.4","cost":"10.8","fee":"0.02","price":"27.0"}}}`,
+			`{"error":[],"result":{"OABC-1":{"userref":7,"status":"canceled","reason":"User requested","opentm":1700000000,"descr":{"pair":"XBTUSD","type":"buy","ordertype":"limit","price":"27.5"},"vol":"0.5","vol_exec":"0.5","cost":"13.55","fee":"0.025","price":"27.1"}}}`,
+		},
+		"CancelOrder": {`{"error":[],"result":{"count":0,"pending":true}}`},
+	})
+	m := NewOrderManager(api, 7)
+
+	if _, err := m.Submit(ctx, &OrderRequest{Pair: "XBTUSD", Side: SideBuy, OrderType: OTLimit, Volume: MustDecimal("0.5"), Price: MustDecimal("27.5"), UserRef: 8}); err == nil {
+		t.Error("Submit() should reject a foreign user reference")
+	}
+	order, err := m.Submit(ctx, NewLimitOrder("XBTUSD", SideBuy, MustDecimal("0.5"), MustDecimal("27.5")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if order.TransactionID != "OABC-1" || order.State != OrderStateSubmitted || order.UserRef != 7 {
+		t.Errorf("Submit() returned %+v", order)
+	}
+	nextOrderEvent(t, m)
+
+	rejected, err := m.Submit(ctx, NewLimitOrder("XBTUSD", SideBuy, MustDecimal("100"), MustDecimal("27.5")))
+	if err == nil || rejected.State != OrderStateRejected || !strings.Contains(rejected.Reason, "Insufficient funds") {
+		t.Errorf("Submit() should reject the order, got %+v %v", rejected, err)
+	}
+	if event := nextOrderEvent(t, m); event.Order.State != OrderStateRejected {
+		t.Errorf("expected rejection, got %+v", event)
+	}
+
+	if err := m.Poll(ctx); err != nil {
+		t.Fatal(err)
+	}
+	event := nextOrderEvent(t, m)
+	if event.Fill == nil || event.Fill.Volume.String() != "0.4" || event.Fill.Price.String() != "27" || event.Order.State != OrderStatePartiallyFilled || event.Previous != OrderStateSubmitted {
+		t.Errorf("expected partial fill, got %+v %+v", event, event.Fill)
+	}
+
+	if err := m.Cancel(ctx, "OABC-1"); err != nil {
+		t.Fatal(err)
+	}
+	if event := nextOrderEvent(t, m); !event.Order.CancelPending || event.Order.State != OrderStatePartiallyFilled {
+		t.Errorf("expected pending cancellation, got %+v", event)
+	}
+
+	if err := m.Poll(ctx); err != nil {
+		t.Fatal(err)
+	}
+	event = nextOrderEvent(t, m)
+	if event.Fill == nil || event.Fill.Volume.String() != "0.1" || event.Fill.Price.String() != "27.5" || event.Fill.Fee.String() != "0.005" {
+		t.Errorf("expected the last fill, got %+v %+v", event, event.Fill)
+	}
+	event = nextOrderEvent(t, m)
+	if event.Order.State != OrderStateCanceled || event.Order.CancelPending || event.Order.AveragePrice.String() != "27.1" || event.Order.Reason != "User requested" {
+		t.Errorf("expected cancellation, got %+v", event)
+	}
+
+	// Final orders are not polled again
+	if err := m.Poll(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if orders := m.Orders(); len(orders) != 1 || orders[0].VolumeExecuted.String() != "0.5" {
+		t.Errorf("Orders() returned %+v", orders)
+	}
+}
+
+func TestOrderManagerUnknownSubmission(t *testing.T) {
+	api := newTestOrderAPI(t, map[string][]string{
+		"AddOrder": {`{"error":["EGeneral:Internal error"]}`, `{"error":["EGeneral:Invalid arguments:volume"]}`},
+	})
+	m := NewOrderManager(api, 7)
+
+	order, err := m.Submit(context.Background(), NewLimitOrder("XBTUSD", SideBuy, MustDecimal("0.5"), MustDecimal("27.5")))
+	if err == nil || order.State != "" {
+		t.Errorf("Submit() should leave an order with an internal error unknown, got %+v %v", order, err)
+	}
+	select {
+	case event := <-m.Events():
+		t.Errorf("Submit() should not emit an event for an unknown order, got %+v", event)
+	default:
+	}
+
+	order, err = m.Submit(context.Background(), NewLimitOrder("XBTUSD", SideBuy, MustDecimal("0.5"), MustDecimal("27.5")))
+	if err == nil || order.State != OrderStateRejected {
+		t.Errorf("Submit() should reject an order with invalid arguments, got %+v %v", order, err)
+	}
+}
+
+func TestOrderManagerReconcile(t *testing.T) {
+	ctx := context.Background()
+	api := newTestOrderAPI(t, map[string][]string{
+		"OpenOrders":   {`{"error":[],"result":{"open":{"OOPEN-1":{"userref":7,"status":"open","opentm":1700000200,"descr":{"pair":"XBTUSD","type":"sell","ordertype":"limit"},"vol":"1","vol_exec":"0","cost":"0","fee":"0","price":"0"},"OTHER-1":{"userref":9,"status":"open","opentm":1700000100,"descr":{"pair":"XBTUSD","type":"sell","ordertype":"limit"},"vol":"1","vol_exec":"0"}},"count":2}}`},
+		"ClosedOrders": {`{"error":[],"result":{"closed":{"OCLOSED-1":{"userref":7,"status":"closed","opentm":1700000000,"closetm":1700000050,"descr":{"pair":"XBTUSD","type":"buy","ordertype":"market"},"vol":"1","vol_exec":"1","cost":"27","fee":"0.1","price":"27"}},"count":1}}`},
+	})
+	m := NewOrderManager(api, 7)
+	if err := m.Reconcile(ctx, time.Unix(1699990000, 0)); err != nil {
+		t.Fatal(err)
+	}
+
+	if event := nextOrderEvent(t, m); event.Order.TransactionID != "OCLOSED-1" || event.Fill == nil || event.Fill.Volume.String() != "1" {
+		t.Errorf("expected the fill of the closed order, got %+v", event)
+	}
+	if event := nextOrderEvent(t, m); event.Order.State != OrderStateFilled {
+		t.Errorf("expected filled order, got %+v", event)
+	}
+	if event := nextOrderEvent(t, m); event.Order.TransactionID != "OOPEN-1" || event.Order.State != OrderStateOpen || event.Order.Side != SideSell {
+		t.Errorf("expected open order, got %+v", event)
+	}
+	if _, ok := m.Order("OTHER-1"); ok {
+		t.Error("Reconcile() should ignore orders of other user references")
+	}
+
+	// WebSocket changes only carry the changed fields
+	update := &OpenOrdersEvent{WSChannel: WSChannel{Name: ChannelOpenOrders}, Orders: []Order{
+		{TransactionID: "OOPEN-1", VolumeExecuted: MustDecimal("0.25"), Cost: MustDecimal("7"), Fee: MustDecimal("0.01"), Price: MustDecimal("28")},
+		{TransactionID: "OOPEN-1", Status: OrderStatusClosed},
+	}}
+	if err := m.Apply(ctx, update); err != nil {
+		t.Fatal(err)
+	}
+	if event := nextOrderEvent(t, m); event.Fill == nil || event.Fill.Price.String() != "28" || event.Order.State != OrderStatePartiallyFilled || event.Order.Volume.String() != "1" {
+		t.Errorf("expected fill, got %+v", event)
+	}
+	if event := nextOrderEvent(t, m); event.Order.State != OrderStateFilled || event.Previous != OrderStatePartiallyFilled {
+		t.Errorf("expected filled order, got %+v", event)
+	}
+}
+
+func TestOrderManagerRunPollsAfterSubscribing(t *testing.T) {
+	server := newWSTestServer(t)
+	ws, conn := server.connect(t)
+	api := newTestOrderAPI(t, map[string][]string{
+		"GetWebSocketsToken": {`{"error":[],"result":{"token":"1Dwc4lzSwNWOAwkMdqhssNNFhs1ed606d1WcF3XfEMw","expires":900}}`},
+		"AddOrder":           {`{"error":[],"result":{"descr":{"order":"buy 0.5 XBTUSD @ limit 40000.5"},"txid":["OABC-1"]}}`},
+		"QueryOrders":        {`{"error":[],"result":{"OABC-1":{"userref":7,"status":"open","opentm":1700000000,"descr":{"pair":"XBTUSD","type":"buy","ordertype":"limit","price":"40000.5"},"vol":"0.5","vol_exec":"0.3","cost":"12000.13","fee":"0.02","price":"40000.4"}}}`},
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := ws.Authenticate(ctx, api); err != nil {
+		t.Fatal(err)
+	}
+
+	m := NewOrderManager(api, 7).WithWebSocket(ws)
+	if _, err := m.Submit(ctx, NewLimitOrder("XBTUSD", SideBuy, MustDecimal("0.5"), MustDecimal("40000.5"))); err != nil {
+		t.Fatal(err)
+	}
+	nextOrderEvent(t, m)
+
+	// A plain WebSocket emits no ConnectionEvent, the fill is found by the first poll
+	go m.Run(ctx)
+	var req map[string]interface{}
+	if err := conn.ReadJSON(&req); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case event := <-m.Events():
+		if event.Fill == nil || event.Fill.Price.String() != "40000.4333333333333333" || event.Order.AveragePrice.String() != "40000.4" {
+			t.Errorf("expected the fill found by the first poll, got %+v %+v", event, event.Fill)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run() should poll after subscribing")
+	}
+}
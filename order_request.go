@@ -0,0 +1,424 @@
+package krakenapi
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// OrderFlag is an order flag of AddOrder's oflags parameter
+type OrderFlag string
+
+// Order flags
+const (
+	// Post-only order, only available for limit orders
+	FlagPost OrderFlag = "post"
+	// Prefer fee in base currency
+	FlagFeeInBase OrderFlag = "fcib"
+	// Prefer fee in quote currency
+	FlagFeeInQuote OrderFlag = "fciq"
+	// Disable market price protection for market orders
+	FlagNoMarketPriceProtection OrderFlag = "nompp"
+	// Order volume expressed in quote currency, only available for market orders
+	FlagVolumeInQuote OrderFlag = "viqc"
+)
+
+// TimeInForce defines how long an order stays in the book
+type TimeInForce string
+
+// Time in force values
+const (
+	// Good till canceled
+	TimeInForceGTC TimeInForce = "GTC"
+	// Immediate or cancel
+	TimeInForceIOC TimeInForce = "IOC"
+	// Good till date, requires an ExpireTime
+	TimeInForceGTD TimeInForce = "GTD"
+)
+
+// Trigger is the price signal used to trigger stop and take profit orders
+type Trigger string
+
+// Trigger values
+const (
+	TriggerIndex Trigger = "index"
+	TriggerLast  Trigger = "last"
+)
+
+// SelfTradePrevention defines what happens when an order would match an own order
+type SelfTradePrevention string
+
+// Self trade prevention values
+const (
+	STPCancelNewest SelfTradePrevention = "cancel-newest"
+	STPCancelOldest SelfTradePrevention = "cancel-oldest"
+	STPCancelBoth   SelfTradePrevention = "cancel-both"
+)
+
+// CloseOrder is a conditional close order attached to an OrderRequest,
+// which is placed once the order is filled
+type CloseOrder struct {
+	OrderType OrderType
+	Price     Decimal
+	Price2    Decimal
+}
+
+// OrderRequest holds every parameter of an AddOrder call.
+// Zero values mean the parameter is not sent.
+type OrderRequest struct {
+	Pair      string
+	Side      Side
+	OrderType OrderType
+	Volume    Decimal
+	// Visible volume of an iceberg order, only available for limit orders
+	DisplayVolume Decimal
+	// Limit price for limit orders, trigger price for stop and take profit orders,
+	// and the trailing offset for trailing stop orders
+	Price Decimal
+	// Limit price once a stop or take profit order is triggered,
+	// and the limit offset for trailing stop limit orders
+	Price2  Decimal
+	Trigger Trigger
+	// Leverage like "2", empty for spot orders
+	Leverage   string
+	ReduceOnly bool
+	Flags      []OrderFlag
+	// Scheduled start time
+	StartTime   time.Time
+	TimeInForce TimeInForce
+	// Expiration time, requires TimeInForceGTD
+	ExpireTime          time.Time
+	SelfTradePrevention SelfTradePrevention
+	Close               *CloseOrder
+	// Client order ID, cannot be combined with UserRef
+	ClientOrderID string
+	// User reference ID, cannot be combined with ClientOrderID
+	UserRef int32
+	// Reject the order if it cannot be matched before the deadline
+	Deadline time.Time
+	// Only validate the order, do not submit it
+	Validate         bool
+	TradingAgreement bool
+}
+
+// NewMarketOrder creates a market OrderRequest
+func NewMarketOrder(pair string, side Side, volume Decimal) *OrderRequest {
+	return &OrderRequest{Pair: pair, Side: side, OrderType: OTMarket, Volume: volume}
+}
+
+// NewLimitOrder creates a limit OrderRequest
+func NewLimitOrder(pair string, side Side, volume Decimal, price Decimal) *OrderRequest {
+	return &OrderRequest{Pair: pair, Side: side, OrderType: OTLimit, Volume: volume, Price: price}
+}
+
+// HasFlag reports whether the request carries flag
+func (r *OrderRequest) HasFlag(flag OrderFlag) bool {
+	for _, f := range r.Flags {
+		if f == flag {
+			return true
+		}
+	}
+	return false
+}
+
+// priceRule returns whether an order type requires price and price2
+func priceRule(orderType OrderType) (price bool, price2 bool, err error) {
+	switch orderType {
+	case OTMarket, OTSettlePosition:
+		return false, false, nil
+	case OTLimit, OTStopLoss, OTTakeProfi, OTTrailingStop:
+		return true, false, nil
+	case OTStopLossLimit, OTTakeProfitLimit, OTTrailingStopLimit,
+		OTStopLossProfit, OTStopLossProfitLimit, OTStopLossAndLimit:
+		return true, true, nil
+	}
+	return false, false, fmt.Errorf("unknown order type %q", orderType)
+}
+
+// checkPrices validates price and price2 against the order type
+func checkPrices(orderType OrderType, price Decimal, price2 Decimal) error {
+	needsPrice, needsPrice2, err := priceRule(orderType)
+	if err != nil {
+		return err
+	}
+
+	if needsPrice && price.IsZero() {
+		return fmt.Errorf("price is required for %s orders", orderType)
+	}
+	if !needsPrice && !price.IsZero() {
+		return fmt.Errorf("price is not allowed for %s orders", orderType)
+	}
+	if needsPrice2 && price2.IsZero() {
+		return fmt.Errorf("price2 is required for %s orders", orderType)
+	}
+	if !needsPrice2 && !price2.IsZero() {
+		return fmt.Errorf("price2 is not allowed for %s orders", orderType)
+	}
+	return nil
+}
+
+// isTriggered reports whether orders of the type wait for a trigger price
+func isTriggered(orderType OrderType) bool {
+	switch orderType {
+	case OTMarket, OTLimit, OTSettlePosition:
+		return false
+	}
+	return true
+}
+
+// isTrailing reports whether prices of the order type are offsets
+func isTrailing(orderType OrderType) bool {
+	return orderType == OTTrailingStop || orderType == OTTrailingStopLimit
+}
+
+// limitPrice returns the lowest price an order of the type can execute at as a limit
+// order, false for order types without an absolute limit price
+func limitPrice(orderType OrderType, price Decimal, price2 Decimal) (Decimal, bool) {
+	switch orderType {
+	case OTLimit:
+		return price, true
+	case OTStopLossLimit, OTTakeProfitLimit, OTStopLossAndLimit:
+		return price2, true
+	case OTStopLossProfitLimit:
+		if price2.LessThan(price) {
+			return price2, true
+		}
+		return price, true
+	}
+	return Decimal{}, false
+}
+
+// Check validates the request without sending it to Kraken
+func (r *OrderRequest) Check() error {
+	if err := r.check(); err != nil {
+		return fmt.Errorf("invalid order request: %s", err)
+	}
+	return nil
+}
+
+func (r *OrderRequest) check() error {
+	if r.Pair == "" {
+		return fmt.Errorf("pair is required")
+	}
+	if !r.Side.IsKnown() {
+		return fmt.Errorf("unknown side %q", r.Side)
+	}
+	if err := checkPrices(r.OrderType, r.Price, r.Price2); err != nil {
+		return err
+	}
+
+	if r.Volume.Sign() < 0 || (r.Volume.IsZero() && r.OrderType != OTSettlePosition) {
+		return fmt.Errorf("volume must be positive")
+	}
+	if !r.DisplayVolume.IsZero() {
+		if r.OrderType != OTLimit {
+			return fmt.Errorf("display volume is only allowed for limit orders")
+		}
+		if r.DisplayVolume.Sign() < 0 || !r.DisplayVolume.LessThan(r.Volume) {
+			return fmt.Errorf("display volume must be positive and below the volume")
+		}
+	}
+	if r.Trigger != "" {
+		if r.Trigger != TriggerIndex && r.Trigger != TriggerLast {
+			return fmt.Errorf("unknown trigger %q", r.Trigger)
+		}
+		if !isTriggered(r.OrderType) {
+			return fmt.Errorf("trigger is not allowed for %s orders", r.OrderType)
+		}
+	}
+	if r.ReduceOnly && r.Leverage == "" {
+		return fmt.Errorf("reduce only requires leverage")
+	}
+
+	seen := map[OrderFlag]bool{}
+	for _, flag := range r.Flags {
+		if seen[flag] {
+			return fmt.Errorf("duplicate flag %q", flag)
+		}
+		seen[flag] = true
+
+		switch flag {
+		case FlagPost:
+			if r.OrderType != OTLimit {
+				return fmt.Errorf("post is only allowed for limit orders")
+			}
+			if r.TimeInForce == TimeInForceIOC {
+				return fmt.Errorf("post cannot be combined with IOC")
+			}
+		case FlagFeeInBase, FlagFeeInQuote:
+		case FlagNoMarketPriceProtection:
+			if r.OrderType != OTMarket {
+				return fmt.Errorf("nompp is only allowed for market orders")
+			}
+		case FlagVolumeInQuote:
+			if r.OrderType != OTMarket {
+				return fmt.Errorf("viqc is only allowed for market orders")
+			}
+			if r.Leverage != "" {
+				return fmt.Errorf("viqc is not allowed for leveraged orders")
+			}
+		default:
+			return fmt.Errorf("unknown flag %q", flag)
+		}
+	}
+	if seen[FlagFeeInBase] && seen[FlagFeeInQuote] {
+		return fmt.Errorf("fcib and fciq cannot be combined")
+	}
+
+	switch r.TimeInForce {
+	case "", TimeInForceGTC:
+		if !r.ExpireTime.IsZero() {
+			return fmt.Errorf("expire time requires GTD")
+		}
+	case TimeInForceIOC:
+		if !r.ExpireTime.IsZero() {
+			return fmt.Errorf("expire time requires GTD")
+		}
+	case TimeInForceGTD:
+		if r.ExpireTime.IsZero() {
+			return fmt.Errorf("GTD requires an expire time")
+		}
+	default:
+		return fmt.Errorf("unknown time in force %q", r.TimeInForce)
+	}
+	if !r.StartTime.IsZero() && !r.ExpireTime.IsZero() && !r.ExpireTime.After(r.StartTime) {
+		return fmt.Errorf("expire time must be after start time")
+	}
+
+	switch r.SelfTradePrevention {
+	case "", STPCancelNewest, STPCancelOldest, STPCancelBoth:
+	default:
+		return fmt.Errorf("unknown self trade prevention %q", r.SelfTradePrevention)
+	}
+
+	if r.Close != nil {
+		if r.Close.OrderType == OTMarket || r.Close.OrderType == OTSettlePosition {
+			return fmt.Errorf("close order type %s is not allowed", r.Close.OrderType)
+		}
+		if err := checkPrices(r.Close.OrderType, r.Close.Price, r.Close.Price2); err != nil {
+			return fmt.Errorf("close order: %s", err)
+		}
+	}
+
+	if r.ClientOrderID != "" && r.UserRef != 0 {
+		return fmt.Errorf("client order id cannot be combined with userref")
+	}
+	return nil
+}
+
+// formatPrice formats a price, adding the relative "+" prefix Kraken requires for trailing offsets
+func formatPrice(orderType OrderType, price Decimal) string {
+	if isTrailing(orderType) && price.Sign() > 0 {
+		return "+" + price.String()
+	}
+	return price.String()
+}
+
+// params validates the request and returns its parameters as sent to Kraken
+func (r *OrderRequest) params() (map[string]string, error) {
+	if err := r.Check(); err != nil {
+		return nil, err
+	}
+
+	params := map[string]string{
+		"pair":      r.Pair,
+		"type":      string(r.Side),
+		"ordertype": string(r.OrderType),
+		"volume":    r.Volume.String(),
+	}
+	if !r.DisplayVolume.IsZero() {
+		params["displayvol"] = r.DisplayVolume.String()
+	}
+	if !r.Price.IsZero() {
+		params["price"] = formatPrice(r.OrderType, r.Price)
+	}
+	if !r.Price2.IsZero() {
+		params["price2"] = formatPrice(r.OrderType, r.Price2)
+	}
+	if r.Trigger != "" {
+		params["trigger"] = string(r.Trigger)
+	}
+	if r.Leverage != "" {
+		params["leverage"] = r.Leverage
+	}
+	if r.ReduceOnly {
+		params["reduce_only"] = "true"
+	}
+	if len(r.Flags) > 0 {
+		flags := make([]string, len(r.Flags))
+		for i, flag := range r.Flags {
+			flags[i] = string(flag)
+		}
+		params["oflags"] = strings.Join(flags, ",")
+	}
+	if !r.StartTime.IsZero() {
+		params["starttm"] = strconv.FormatInt(r.StartTime.Unix(), 10)
+	}
+	if r.TimeInForce != "" {
+		params["timeinforce"] = string(r.TimeInForce)
+	}
+	if !r.ExpireTime.IsZero() {
+		params["expiretm"] = strconv.FormatInt(r.ExpireTime.Unix(), 10)
+	}
+	if r.SelfTradePrevention != "" {
+		params["stptype"] = string(r.SelfTradePrevention)
+	}
+	if r.Close != nil {
+		params["close[ordertype]"] = string(r.Close.OrderType)
+		if !r.Close.Price.IsZero() {
+			params["close[price]"] = formatPrice(r.Close.OrderType, r.Close.Price)
+		}
+		if !r.Close.Price2.IsZero() {
+			params["close[price2]"] = formatPrice(r.Close.OrderType, r.Close.Price2)
+		}
+	}
+	if r.ClientOrderID != "" {
+		params["cl_ord_id"] = r.ClientOrderID
+	}
+	if r.UserRef != 0 {
+		params["userref"] = strconv.FormatInt(int64(r.UserRef), 10)
+	}
+	if !r.Deadline.IsZero() {
+		params["deadline"] = r.Deadline.UTC().Format(time.RFC3339Nano)
+	}
+	if r.Validate {
+		params["validate"] = "true"
+	}
+	if r.TradingAgreement {
+		params["trading_agreement"] = "agree"
+	}
+	return params, nil
+}
+
+// Values validates the request and returns it encoded as AddOrder parameters
+func (r *OrderRequest) Values() (url.Values, error) {
+	params, err := r.params()
+	if err != nil {
+		return nil, err
+	}
+	return toValues(params), nil
+}
+
+// PlaceOrder validates and submits the order request
+func (api *KrakenAPI) PlaceOrder(req *OrderRequest) (*AddOrderResponse, error) {
+	return api.PlaceOrderContext(context.Background(), req)
+}
+
+// PlaceOrderContext validates and submits the order request
+func (api *KrakenAPI) PlaceOrderContext(ctx context.Context, req *OrderRequest) (*AddOrderResponse, error) {
+	params, err := req.Values()
+	if err != nil {
+		return nil, err
+	}
+	params.Set("pair", api.pairName(req.Pair))
+
+	resp, err := api.queryPrivateContext(ctx, "AddOrder", params, &AddOrderResponse{})
+	if err != nil {
+		return nil, err
+	}
+
+	return resp.(*AddOrderResponse), nil
+}
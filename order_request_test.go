@@ -0,0 +1,90 @@
+package krakenapi
+
+import (
+	"testing"
+	"time"
+)
+
+func TestOrderRequestValues(t *testing.T) {
+	start := time.Unix(1700000000, 0)
+	req := NewLimitOrder(XXBTZUSD, SideBuy, MustDecimal("1.25"), MustDecimal("30000.5"))
+	req.Flags = []OrderFlag{FlagPost, FlagFeeInQuote}
+	req.TimeInForce = TimeInForceGTD
+	req.StartTime = start
+	req.ExpireTime = start.Add(time.Hour)
+	req.Close = &CloseOrder{OrderType: OTStopLossLimit, Price: MustDecimal("29000"), Price2: MustDecimal("28900")}
+	req.UserRef = 42
+	req.Validate = true
+
+	values, err := req.Values()
+	if err != nil {
+		t.Fatalf("Values() should not return an error, got %s", err)
+	}
+
+	expected := map[string]string{
+		"pair":             XXBTZUSD,
+		"type":             "buy",
+		"ordertype":        "limit",
+		"volume":           "1.25",
+		"price":            "30000.5",
+		"oflags":           "post,fciq",
+		"timeinforce":      "GTD",
+		"starttm":          "1700000000",
+		"expiretm":         "1700003600",
+		"close[ordertype]": "stop-loss-limit",
+		"close[price]":     "29000",
+		"close[price2]":    "28900",
+		"userref":          "42",
+		"validate":         "true",
+	}
+	for key, value := range expected {
+		if values.Get(key) != value {
+			t.Errorf("Values() should set %s to %s, got %q", key, value, values.Get(key))
+		}
+	}
+	if len(values) != len(expected) {
+		t.Errorf("Values() should only set given parameters, got %v", values)
+	}
+
+	trailing := &OrderRequest{Pair: XXBTZUSD, Side: SideSell, OrderType: OTTrailingStop, Volume: MustDecimal("1"), Price: MustDecimal("50")}
+	values, err = trailing.Values()
+	if err != nil || values.Get("price") != "+50" {
+		t.Errorf("Values() should send trailing offsets with a + prefix, got %v (%v)", values, err)
+	}
+}
+
+func TestOrderRequestCheck(t *testing.T) {
+	volume := MustDecimal("1")
+	price := MustDecimal("100")
+
+	invalid := map[string]*OrderRequest{
+		"price2 on limit":       {Pair: XXBTZUSD, Side: SideBuy, OrderType: OTLimit, Volume: volume, Price: price, Price2: price},
+		"limit without price":   {Pair: XXBTZUSD, Side: SideBuy, OrderType: OTLimit, Volume: volume},
+		"price on market":       {Pair: XXBTZUSD, Side: SideBuy, OrderType: OTMarket, Volume: volume, Price: price},
+		"missing price2":        {Pair: XXBTZUSD, Side: SideBuy, OrderType: OTStopLossLimit, Volume: volume, Price: price},
+		"unknown side":          {Pair: XXBTZUSD, Side: "b", OrderType: OTMarket, Volume: volume},
+		"unknown order type":    {Pair: XXBTZUSD, Side: SideBuy, OrderType: "iceberg", Volume: volume},
+		"zero volume":           {Pair: XXBTZUSD, Side: SideBuy, OrderType: OTMarket},
+		"post on market":        {Pair: XXBTZUSD, Side: SideBuy, OrderType: OTMarket, Volume: volume, Flags: []OrderFlag{FlagPost}},
+		"viqc on limit":         {Pair: XXBTZUSD, Side: SideBuy, OrderType: OTLimit, Volume: volume, Price: price, Flags: []OrderFlag{FlagVolumeInQuote}},
+		"fcib and fciq":         {Pair: XXBTZUSD, Side: SideBuy, OrderType: OTMarket, Volume: volume, Flags: []OrderFlag{FlagFeeInBase, FlagFeeInQuote}},
+		"unknown flag":          {Pair: XXBTZUSD, Side: SideBuy, OrderType: OTMarket, Volume: volume, Flags: []OrderFlag{"typo"}},
+		"GTD without expire":    {Pair: XXBTZUSD, Side: SideBuy, OrderType: OTLimit, Volume: volume, Price: price, TimeInForce: TimeInForceGTD},
+		"expire without GTD":    {Pair: XXBTZUSD, Side: SideBuy, OrderType: OTLimit, Volume: volume, Price: price, ExpireTime: time.Now()},
+		"trigger on limit":      {Pair: XXBTZUSD, Side: SideBuy, OrderType: OTLimit, Volume: volume, Price: price, Trigger: TriggerIndex},
+		"display on market":     {Pair: XXBTZUSD, Side: SideBuy, OrderType: OTMarket, Volume: volume, DisplayVolume: volume},
+		"reduce only spot":      {Pair: XXBTZUSD, Side: SideBuy, OrderType: OTMarket, Volume: volume, ReduceOnly: true},
+		"cl_ord_id and userref": {Pair: XXBTZUSD, Side: SideBuy, OrderType: OTMarket, Volume: volume, ClientOrderID: "a", UserRef: 1},
+		"invalid close":         {Pair: XXBTZUSD, Side: SideBuy, OrderType: OTMarket, Volume: volume, Close: &CloseOrder{OrderType: OTLimit}},
+	}
+	for name, req := range invalid {
+		if err := req.Check(); err == nil {
+			t.Errorf("Check() should reject %s", name)
+		}
+	}
+
+	valid := &OrderRequest{Pair: XXBTZUSD, Side: SideSell, OrderType: OTStopLossLimit, Volume: volume, Price: price, Price2: price, Trigger: TriggerLast}
+	if err := valid.Check(); err != nil {
+		t.Errorf("Check() should accept valid requests, got %s", err)
+	}
+}
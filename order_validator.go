@@ -0,0 +1,250 @@
+package krakenapi
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ViolationKind identifies the rule an order breaks
+type ViolationKind string
+
+// Order rule violations reported by OrderValidator
+const (
+	// Volume is below the pair's ordermin
+	ViolationVolumeTooSmall ViolationKind = "volume_too_small"
+	// Limit price times volume is below the pair's costmin
+	ViolationCostTooSmall ViolationKind = "cost_too_small"
+	// Price has more decimals than the pair's pair_decimals
+	ViolationPricePrecision ViolationKind = "price_precision"
+	// Volume has more decimals than the pair's lot_decimals
+	ViolationVolumePrecision ViolationKind = "volume_precision"
+	// Price is not a multiple of the pair's tick_size
+	ViolationOffTick ViolationKind = "off_tick"
+	// Pair is unknown to Kraken
+	ViolationUnknownPair ViolationKind = "unknown_pair"
+)
+
+// OrderViolation describes one broken rule
+type OrderViolation struct {
+	Kind    ViolationKind
+	Message string
+}
+
+// OrderValidationError lists every rule an OrderRequest breaks
+type OrderValidationError struct {
+	Pair       string
+	Violations []OrderViolation
+}
+
+func (e *OrderValidationError) Error() string {
+	messages := make([]string, len(e.Violations))
+	for i, violation := range e.Violations {
+		messages[i] = violation.Message
+	}
+	return fmt.Sprintf("order for %s violates pair rules: %s", e.Pair, strings.Join(messages, "; "))
+}
+
+// Has reports whether the error contains a violation of the given kind
+func (e *OrderValidationError) Has(kind ViolationKind) bool {
+	for _, violation := range e.Violations {
+		if violation.Kind == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// OrderValidator checks orders against Kraken's pair metadata before they are sent.
+// The metadata is fetched with AssetPairsWithInfo and cached for maxAge.
+// It is safe for concurrent use.
+type OrderValidator struct {
+	api    *KrakenAPI
+	maxAge time.Duration
+	// serializes the refreshes, so concurrent stale lookups load the metadata once
+	refreshMu sync.Mutex
+	mu        sync.Mutex
+	pairs     *Registry
+	loadedAt  time.Time
+}
+
+// NewOrderValidator creates an OrderValidator that refreshes its pair metadata after maxAge.
+// A maxAge of 0 never refreshes once loaded.
+func NewOrderValidator(api *KrakenAPI, maxAge time.Duration) *OrderValidator {
+	return &OrderValidator{api: api, maxAge: maxAge}
+}
+
+// Refresh reloads the pair metadata
+func (v *OrderValidator) Refresh() error {
+	v.refreshMu.Lock()
+	defer v.refreshMu.Unlock()
+	return v.refresh()
+}
+
+// refresh reloads the pair metadata, the caller holds refreshMu
+func (v *OrderValidator) refresh() error {
+	pairs, err := v.api.AssetPairsWithInfo("", "")
+	if err != nil {
+		return err
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if v.pairs == nil {
+		v.pairs = NewRegistry(pairs, nil)
+	} else {
+		v.pairs.Load(pairs, nil)
+	}
+	v.loadedAt = time.Now()
+	return nil
+}
+
+// Pair returns the cached metadata of the pair known under alias, loading it if needed
+func (v *OrderValidator) Pair(alias string) (AssetPairInfo, bool, error) {
+	pairs, stale := v.cached()
+	if stale {
+		v.refreshMu.Lock()
+		// Another caller may have refreshed while this one waited
+		if pairs, stale = v.cached(); stale {
+			if err := v.refresh(); err != nil {
+				v.refreshMu.Unlock()
+				return AssetPairInfo{}, false, err
+			}
+			pairs, _ = v.cached()
+		}
+		v.refreshMu.Unlock()
+	}
+
+	info, found := pairs.Pair(alias)
+	return info, found, nil
+}
+
+// cached returns the cached metadata and whether it must be refreshed
+func (v *OrderValidator) cached() (*Registry, bool) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return v.pairs, v.pairs == nil || (v.maxAge > 0 && time.Since(v.loadedAt) > v.maxAge)
+}
+
+// Validate reports every rule of the pair the request breaks as an *OrderValidationError.
+// Cost is only checked when the request carries a limit price.
+func (v *OrderValidator) Validate(req *OrderRequest) error {
+	info, found, err := v.Pair(req.Pair)
+	if err != nil {
+		return err
+	}
+
+	result := &OrderValidationError{Pair: req.Pair}
+	if !found {
+		result.Violations = append(result.Violations, OrderViolation{
+			Kind:    ViolationUnknownPair,
+			Message: fmt.Sprintf("unknown pair %s", req.Pair),
+		})
+		return result
+	}
+
+	result.Violations = checkOrder(info, req)
+	if len(result.Violations) > 0 {
+		return result
+	}
+	return nil
+}
+
+// Adjust returns a copy of the request with prices rounded to the pair's tick size and
+// precision and the volume truncated to the pair's lot precision, or its cost precision
+// for viqc orders. The rounded request is validated, so an order that is still too small
+// is reported as *OrderValidationError.
+func (v *OrderValidator) Adjust(req *OrderRequest) (*OrderRequest, error) {
+	info, found, err := v.Pair(req.Pair)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, v.Validate(req)
+	}
+
+	adjusted := *req
+	adjusted.Volume = req.Volume.Truncate(volumeDecimals(info, req))
+	adjusted.DisplayVolume = req.DisplayVolume.Truncate(volumeDecimals(info, req))
+	adjusted.Price = roundToTick(info, req.Price)
+	adjusted.Price2 = roundToTick(info, req.Price2)
+	if req.Close != nil {
+		closeOrder := *req.Close
+		closeOrder.Price = roundToTick(info, closeOrder.Price)
+		closeOrder.Price2 = roundToTick(info, closeOrder.Price2)
+		adjusted.Close = &closeOrder
+	}
+
+	if violations := checkOrder(info, &adjusted); len(violations) > 0 {
+		return nil, &OrderValidationError{Pair: req.Pair, Violations: violations}
+	}
+	return &adjusted, nil
+}
+
+// roundToTick rounds price to the nearest tick and the pair's decimals
+func roundToTick(info AssetPairInfo, price Decimal) Decimal {
+	if price.IsZero() {
+		return price
+	}
+	if info.TickSize.Sign() > 0 {
+		price = price.Div(info.TickSize, 0).Mul(info.TickSize)
+	}
+	return info.RoundPrice(price)
+}
+
+// volumeDecimals returns the precision of the volume of req, which is a cost in the quote
+// currency with viqc
+func volumeDecimals(info AssetPairInfo, req *OrderRequest) int32 {
+	if !req.HasFlag(FlagVolumeInQuote) {
+		return int32(info.LotDecimals)
+	}
+	if info.CostDecimals > 0 {
+		return int32(info.CostDecimals)
+	}
+	return int32(info.PairDecimals)
+}
+
+// checkOrder returns the pair rules broken by req
+func checkOrder(info AssetPairInfo, req *OrderRequest) []OrderViolation {
+	var violations []OrderViolation
+	add := func(kind ViolationKind, format string, args ...interface{}) {
+		violations = append(violations, OrderViolation{Kind: kind, Message: fmt.Sprintf(format, args...)})
+	}
+
+	// With viqc the volume is expressed in the quote currency
+	if !req.HasFlag(FlagVolumeInQuote) {
+		if req.Volume.LessThan(info.OrderMin) {
+			add(ViolationVolumeTooSmall, "volume %s is below the minimum of %s", req.Volume, info.OrderMin)
+		}
+		if price, found := limitPrice(req.OrderType, req.Price, req.Price2); found && info.CostMin.Sign() > 0 {
+			if cost := price.Mul(req.Volume); cost.LessThan(info.CostMin) {
+				add(ViolationCostTooSmall, "cost %s is below the minimum of %s", cost, info.CostMin)
+			}
+		}
+	} else if req.Volume.LessThan(info.CostMin) {
+		add(ViolationCostTooSmall, "cost %s is below the minimum of %s", req.Volume, info.CostMin)
+	}
+	if decimals := volumeDecimals(info, req); req.Volume.Places() > decimals {
+		add(ViolationVolumePrecision, "volume %s has more than %d decimals", req.Volume, decimals)
+	}
+
+	prices := map[string]Decimal{"price": req.Price, "price2": req.Price2}
+	if req.Close != nil {
+		prices["close price"] = req.Close.Price
+		prices["close price2"] = req.Close.Price2
+	}
+	for _, name := range []string{"price", "price2", "close price", "close price2"} {
+		price := prices[name]
+		if price.IsZero() {
+			continue
+		}
+		if price.Places() > int32(info.PairDecimals) {
+			add(ViolationPricePrecision, "%s %s has more than %d decimals", name, price, info.PairDecimals)
+		}
+		if info.TickSize.Sign() > 0 && !price.Div(info.TickSize, 0).Mul(info.TickSize).Equal(price) {
+			add(ViolationOffTick, "%s %s is not a multiple of the tick size %s", name, price, info.TickSize)
+		}
+	}
+	return violations
+}
@@ -0,0 +1,88 @@
+package krakenapi
+
+import (
+	"sync"
+	"testing"
+)
+
+const testValidatorPairs = `{"error":[],"result":{"XXBTZUSD":{"altname":"XBTUSD","wsname":"XBT/USD","base":"XXBT","quote":"ZUSD","pair_decimals":1,"lot_decimals":8,"cost_decimals":5,"ordermin":"0.0001","costmin":"0.5","tick_size":"0.1"}}}`
+
+func newTestValidator() *OrderValidator {
+	return NewOrderValidator(newTestAPI(testValidatorPairs), 0)
+}
+
+func TestOrderValidatorValidate(t *testing.T) {
+	validator := newTestValidator()
+
+	if err := validator.Validate(NewLimitOrder("XBT/USD", SideBuy, MustDecimal("0.01"), MustDecimal("30000.1"))); err != nil {
+		t.Errorf("Validate() should accept valid orders, got %s", err)
+	}
+
+	cases := map[ViolationKind]*OrderRequest{
+		ViolationVolumeTooSmall:  NewLimitOrder("XBTUSD", SideBuy, MustDecimal("0.00001"), MustDecimal("30000")),
+		ViolationCostTooSmall:    NewLimitOrder("XBTUSD", SideBuy, MustDecimal("0.0001"), MustDecimal("1000")),
+		ViolationPricePrecision:  NewLimitOrder("XBTUSD", SideBuy, MustDecimal("0.01"), MustDecimal("30000.15")),
+		ViolationOffTick:         NewLimitOrder("XBTUSD", SideBuy, MustDecimal("0.01"), MustDecimal("30000.15")),
+		ViolationVolumePrecision: NewLimitOrder("XBTUSD", SideBuy, MustDecimal("0.010000001"), MustDecimal("30000")),
+		ViolationUnknownPair:     NewLimitOrder("ETHUSD", SideBuy, MustDecimal("1"), MustDecimal("3000")),
+	}
+	for kind, req := range cases {
+		err, ok := validator.Validate(req).(*OrderValidationError)
+		if !ok || !err.Has(kind) {
+			t.Errorf("Validate() should report %s, got %v", kind, err)
+		}
+	}
+
+	// The cost of triggered limit orders is checked against their limit price
+	for _, orderType := range []OrderType{OTStopLossLimit, OTTakeProfitLimit, OTStopLossAndLimit} {
+		req := &OrderRequest{Pair: XXBTZUSD, Side: SideBuy, OrderType: orderType, Volume: MustDecimal("0.0001"), Price: MustDecimal("30000"), Price2: MustDecimal("1000")}
+		if err, ok := validator.Validate(req).(*OrderValidationError); !ok || !err.Has(ViolationCostTooSmall) {
+			t.Errorf("Validate() should check the cost of %s orders, got %v", orderType, err)
+		}
+	}
+
+	viqc := NewMarketOrder(XXBTZUSD, SideBuy, MustDecimal("100.000001"))
+	viqc.Flags = []OrderFlag{FlagVolumeInQuote}
+	if err, ok := validator.Validate(viqc).(*OrderValidationError); !ok || !err.Has(ViolationVolumePrecision) {
+		t.Errorf("Validate() should check viqc volumes against the cost decimals, got %v", err)
+	}
+}
+
+func TestOrderValidatorAdjust(t *testing.T) {
+	validator := newTestValidator()
+
+	adjusted, err := validator.Adjust(NewLimitOrder(XXBTZUSD, SideSell, MustDecimal("0.123456789"), MustDecimal("30000.16")))
+	if err != nil {
+		t.Fatalf("Adjust() should not return an error, got %s", err)
+	}
+	if adjusted.Price.String() != "30000.2" || adjusted.Volume.String() != "0.12345678" {
+		t.Errorf("Adjust() should round price and volume, got %s @ %s", adjusted.Volume, adjusted.Price)
+	}
+
+	if _, err := validator.Adjust(NewLimitOrder(XXBTZUSD, SideSell, MustDecimal("0.000001"), MustDecimal("30000"))); err == nil {
+		t.Errorf("Adjust() should still reject too small orders")
+	}
+
+	viqc := NewMarketOrder(XXBTZUSD, SideBuy, MustDecimal("100.123456789"))
+	viqc.Flags = []OrderFlag{FlagVolumeInQuote}
+	if adjusted, err := validator.Adjust(viqc); err != nil || adjusted.Volume.String() != "100.12345" {
+		t.Errorf("Adjust() should truncate viqc volumes to the cost decimals, got %v (%v)", adjusted, err)
+	}
+}
+
+func TestOrderValidatorConcurrentPair(t *testing.T) {
+	// A second AssetPairs request fails the test
+	validator := NewOrderValidator(newTestOrderAPI(t, map[string][]string{"AssetPairs": {testValidatorPairs}}), 0)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, found, err := validator.Pair("XBT/USD"); err != nil || !found {
+				t.Errorf("Pair() should find the pair, got %v %v", found, err)
+			}
+		}()
+	}
+	wg.Wait()
+}
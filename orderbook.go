@@ -0,0 +1,270 @@
+package krakenapi
+
+import (
+	"context"
+	"fmt"
+	"hash/crc32"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// checksumLevels is the number of levels per side covered by the book checksum
+const checksumLevels = 10
+
+// ChecksumError reports a local order book that diverged from Kraken's
+type ChecksumError struct {
+	Pair     string
+	Expected uint32
+	Actual   uint32
+}
+
+func (e *ChecksumError) Error() string {
+	return fmt.Sprintf("order book checksum mismatch for %s: expected %d, got %d", e.Pair, e.Expected, e.Actual)
+}
+
+// DepthLevel is a price level with the volume of all levels up to and including it
+type DepthLevel struct {
+	Price  Decimal
+	Amount Decimal
+	Total  Decimal
+}
+
+// LiveOrderBook is a local copy of a pair's book built from the WebSocket book channel.
+// It is safe for concurrent use.
+type LiveOrderBook struct {
+	mu    sync.RWMutex
+	pair  string
+	depth int
+	// asks ascending and bids descending by price
+	asks   []OrderBookItem
+	bids   []OrderBookItem
+	synced bool
+}
+
+// NewLiveOrderBook creates an empty LiveOrderBook keeping depth levels per side, or all levels if depth is 0
+func NewLiveOrderBook(pair string, depth int) *LiveOrderBook {
+	return &LiveOrderBook{pair: pair, depth: depth}
+}
+
+// Pair returns the pair of the book
+func (b *LiveOrderBook) Pair() string {
+	return b.pair
+}
+
+// Synced reports whether the book holds a snapshot and all updates since
+func (b *LiveOrderBook) Synced() bool {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.synced
+}
+
+// Reset empties the book until the next snapshot
+func (b *LiveOrderBook) Reset() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.asks, b.bids, b.synced = nil, nil, false
+}
+
+// Apply applies a snapshot or an update and verifies the checksum sent with updates.
+// Updates before the first snapshot are ignored. On a *ChecksumError the book is
+// reset and needs a new snapshot.
+func (b *LiveOrderBook) Apply(event *BookEvent) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if event.Snapshot {
+		b.asks, b.bids, b.synced = nil, nil, true
+	} else if !b.synced {
+		return nil
+	}
+
+	for _, level := range event.Asks {
+		b.asks = updateLevel(b.asks, level, false)
+	}
+	for _, level := range event.Bids {
+		b.bids = updateLevel(b.bids, level, true)
+	}
+	// Levels pushed out of the subscribed depth are not deleted by Kraken
+	if b.depth > 0 {
+		if len(b.asks) > b.depth {
+			b.asks = b.asks[:b.depth]
+		}
+		if len(b.bids) > b.depth {
+			b.bids = b.bids[:b.depth]
+		}
+	}
+
+	if event.Snapshot || event.Checksum == 0 {
+		return nil
+	}
+	if actual := b.checksum(); actual != event.Checksum {
+		b.asks, b.bids, b.synced = nil, nil, false
+		return &ChecksumError{Pair: b.pair, Expected: event.Checksum, Actual: actual}
+	}
+	return nil
+}
+
+// updateLevel replaces, inserts or, for a zero amount, removes the level of a price
+func updateLevel(levels []OrderBookItem, level OrderBookItem, descending bool) []OrderBookItem {
+	i := sort.Search(len(levels), func(i int) bool {
+		if descending {
+			return levels[i].Price.Cmp(level.Price) <= 0
+		}
+		return levels[i].Price.Cmp(level.Price) >= 0
+	})
+	found := i < len(levels) && levels[i].Price.Equal(level.Price)
+
+	switch {
+	case level.Amount.IsZero():
+		if found {
+			levels = append(levels[:i], levels[i+1:]...)
+		}
+	case found:
+		levels[i] = level
+	default:
+		levels = append(levels, OrderBookItem{})
+		copy(levels[i+1:], levels[i:])
+		levels[i] = level
+	}
+	return levels
+}
+
+// Checksum returns the CRC32 checksum of the top 10 levels per side as computed by Kraken
+func (b *LiveOrderBook) Checksum() uint32 {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.checksum()
+}
+
+// checksum concatenates price and volume of the top asks and then the top bids as sent
+// by Kraken, without the decimal point and leading zeros
+func (b *LiveOrderBook) checksum() uint32 {
+	var s strings.Builder
+	for _, levels := range [][]OrderBookItem{b.asks, b.bids} {
+		for i, level := range levels {
+			if i == checksumLevels {
+				break
+			}
+			s.WriteString(level.Price.int().String())
+			s.WriteString(level.Amount.int().String())
+		}
+	}
+	return crc32.ChecksumIEEE([]byte(s.String()))
+}
+
+// BestBid returns the highest bid, false if there is none
+func (b *LiveOrderBook) BestBid() (OrderBookItem, bool) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	if len(b.bids) == 0 {
+		return OrderBookItem{}, false
+	}
+	return b.bids[0], true
+}
+
+// BestAsk returns the lowest ask, false if there is none
+func (b *LiveOrderBook) BestAsk() (OrderBookItem, bool) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	if len(b.asks) == 0 {
+		return OrderBookItem{}, false
+	}
+	return b.asks[0], true
+}
+
+// Top returns up to n levels per side, asks ascending and bids descending by price
+func (b *LiveOrderBook) Top(n int) (asks []OrderBookItem, bids []OrderBookItem) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return topLevels(b.asks, n), topLevels(b.bids, n)
+}
+
+func topLevels(levels []OrderBookItem, n int) []OrderBookItem {
+	if n > len(levels) || n < 0 {
+		n = len(levels)
+	}
+	return append([]OrderBookItem(nil), levels[:n]...)
+}
+
+// CumulativeDepth returns up to n levels per side with the volume available up to each level
+func (b *LiveOrderBook) CumulativeDepth(n int) (asks []DepthLevel, bids []DepthLevel) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return cumulate(topLevels(b.asks, n)), cumulate(topLevels(b.bids, n))
+}
+
+func cumulate(levels []OrderBookItem) []DepthLevel {
+	depth := make([]DepthLevel, len(levels))
+	var total Decimal
+	for i, level := range levels {
+		total = total.Add(level.Amount)
+		depth[i] = DepthLevel{Price: level.Price, Amount: level.Amount, Total: total}
+	}
+	return depth
+}
+
+// BookMaintainer keeps the LiveOrderBooks of a WebSocket's book subscriptions up to date
+// and resubscribes a pair whose book fails the checksum. It is safe for concurrent use.
+type BookMaintainer struct {
+	ws    *WebSocket
+	depth int
+	mu    sync.Mutex
+	books map[string]*LiveOrderBook
+}
+
+// NewBookMaintainer creates a BookMaintainer for books of the given depth
+// (10, 25, 100, 500 or 1000) on ws
+func NewBookMaintainer(ws *WebSocket, depth int) *BookMaintainer {
+	return &BookMaintainer{ws: ws, depth: depth, books: map[string]*LiveOrderBook{}}
+}
+
+// Subscribe subscribes to the books of the given pairs
+func (m *BookMaintainer) Subscribe(ctx context.Context, pairs ...string) error {
+	m.mu.Lock()
+	for _, pair := range pairs {
+		name := m.ws.pairName(pair)
+		if m.books[name] == nil {
+			m.books[name] = NewLiveOrderBook(name, m.depth)
+		}
+	}
+	m.mu.Unlock()
+
+	return m.ws.Subscribe(ctx, m.subscription(), pairs...)
+}
+
+func (m *BookMaintainer) subscription() Subscription {
+	return Subscription{Name: ChannelBook, Depth: m.depth}
+}
+
+// Book returns the book of a subscribed pair, nil if it is not subscribed
+func (m *BookMaintainer) Book(pair string) *LiveOrderBook {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.books[m.ws.pairName(pair)]
+}
+
+// Handle applies book events of the maintained pairs and ignores all other events.
+// When a book fails the checksum it is resubscribed to get a new snapshot and the
+// *ChecksumError is returned, unless resubscribing failed.
+func (m *BookMaintainer) Handle(ctx context.Context, event WSEvent) error {
+	bookEvent, ok := event.(*BookEvent)
+	if !ok || bookEvent.Depth != m.depth {
+		return nil
+	}
+	book := m.Book(bookEvent.Pair)
+	if book == nil {
+		return nil
+	}
+
+	err := book.Apply(bookEvent)
+	if _, mismatch := err.(*ChecksumError); mismatch {
+		if err := m.ws.Unsubscribe(ctx, m.subscription(), book.Pair()); err != nil {
+			return err
+		}
+		if err := m.ws.Subscribe(ctx, m.subscription(), book.Pair()); err != nil {
+			return err
+		}
+	}
+	return err
+}
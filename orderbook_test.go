@@ -0,0 +1,103 @@
+package krakenapi
+
+import (
+	"context"
+	"hash/crc32"
+	"testing"
+
+	"github.com/gorilla/websocket"
+)
+
+func bookLevel(price string, amount string) OrderBookItem {
+	return OrderBookItem{Price: MustDecimal(price), Amount: MustDecimal(amount)}
+}
+
+func TestLiveOrderBook(t *testing.T) {
+	book := NewLiveOrderBook("XBT/USD", 3)
+	if err := book.Apply(&BookEvent{Asks: []OrderBookItem{bookLevel("1.0", "1")}, Checksum: 1}); err != nil || book.Synced() {
+		t.Fatalf("Apply() should ignore updates before the snapshot, got %v", err)
+	}
+
+	err := book.Apply(&BookEvent{
+		Snapshot: true,
+		Asks:     []OrderBookItem{bookLevel("0.05005", "0.00000500"), bookLevel("0.05010", "0.00000500")},
+		Bids:     []OrderBookItem{bookLevel("0.05000", "0.00000500"), bookLevel("0.04995", "0.00002000")},
+	})
+	if err != nil || !book.Synced() {
+		t.Fatalf("Apply() should accept the snapshot, got %v", err)
+	}
+
+	// Insert a better ask, remove a bid and push the worst ask out of the depth
+	update := &BookEvent{
+		Asks: []OrderBookItem{bookLevel("0.05001", "0.00001000"), bookLevel("0.04999", "0.00000100")},
+		Bids: []OrderBookItem{bookLevel("0.04995", "0.00000000")},
+	}
+	update.Checksum = crc32.ChecksumIEEE([]byte("4999100" + "50011000" + "5005500" + "5000500"))
+	if err := book.Apply(update); err != nil {
+		t.Fatalf("Apply() should accept the update, got %s", err)
+	}
+
+	asks, bids := book.Top(10)
+	if len(asks) != 3 || asks[0].Price.String() != "0.04999" || asks[2].Price.String() != "0.05005" || len(bids) != 1 {
+		t.Errorf("Top() returned wrong levels, got %v %v", asks, bids)
+	}
+	if ask, ok := book.BestAsk(); !ok || ask.Price.String() != "0.04999" {
+		t.Errorf("BestAsk() is wrong, got %v", ask)
+	}
+	if bid, ok := book.BestBid(); !ok || bid.Price.String() != "0.05" {
+		t.Errorf("BestBid() is wrong, got %v", bid)
+	}
+	depth, _ := book.CumulativeDepth(2)
+	if len(depth) != 2 || depth[1].Total.String() != "0.000011" {
+		t.Errorf("CumulativeDepth() is wrong, got %v", depth)
+	}
+
+	err = book.Apply(&BookEvent{Bids: []OrderBookItem{bookLevel("0.04990", "0.00000100")}, Checksum: 1})
+	if _, ok := err.(*ChecksumError); !ok || book.Synced() {
+		t.Errorf("Apply() should detect a checksum mismatch, got %v", err)
+	}
+	if _, ok := book.BestBid(); ok {
+		t.Error("a book failing the checksum should be reset")
+	}
+}
+
+func TestBookMaintainerResubscribes(t *testing.T) {
+	server := newWSTestServer(t)
+	ws, conn := server.connect(t)
+	maintainer := NewBookMaintainer(ws, 10)
+	ctx := context.Background()
+
+	if err := maintainer.Subscribe(ctx, "XBT/USD"); err != nil {
+		t.Fatal(err)
+	}
+	var req map[string]interface{}
+	conn.ReadJSON(&req)
+
+	messages := []string{
+		`[0,{"as":[["5541.30000","2.50700000","1534614248.123678"]],"bs":[["5541.20000","1.52900000","1534614248.765567"]]},"book-10","XBT/USD"]`,
+		`[0,{"a":[["5541.30000","2.00000000","1534614248.456738"]],"c":"12345"},"book-10","XBT/USD"]`,
+	}
+	for _, msg := range messages {
+		conn.WriteMessage(websocket.TextMessage, []byte(msg))
+	}
+
+	if err := maintainer.Handle(ctx, nextEvent(t, ws)); err != nil {
+		t.Fatalf("Handle() should apply the snapshot, got %s", err)
+	}
+	if bid, _ := maintainer.Book("XBT/USD").BestBid(); bid.Amount.String() != "1.529" {
+		t.Errorf("Book() should return the maintained book, got %v", bid)
+	}
+	if _, ok := maintainer.Handle(ctx, nextEvent(t, ws)).(*ChecksumError); !ok {
+		t.Fatal("Handle() should report the checksum mismatch")
+	}
+
+	for _, event := range []string{"unsubscribe", "subscribe"} {
+		req = nil
+		if err := conn.ReadJSON(&req); err != nil {
+			t.Fatal(err)
+		}
+		if req["event"] != event || req["subscription"].(map[string]interface{})["depth"] != 10.0 {
+			t.Errorf("Handle() should %s the book, got %v", event, req)
+		}
+	}
+}
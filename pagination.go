@@ -0,0 +1,308 @@
+package krakenapi
+
+import (
+	"context"
+	"math"
+	"net/url"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// pageSize is the maximum number of records Kraken returns per call
+const pageSize = 50
+
+// PageOptions selects the records an iterator walks
+type PageOptions struct {
+	// Start of the time window (exclusive), zero for no lower bound
+	Start time.Time
+	// End of the time window (inclusive), zero for now
+	End time.Time
+	// Additional parameters of the endpoint, e.g. "asset" and "type" for Ledgers
+	Args map[string]string
+	// Resume from a checkpoint of an earlier iterator, Start and End are taken from it
+	Checkpoint *PageCheckpoint
+}
+
+// PageCheckpoint records the progress of an iterator so it can be resumed
+type PageCheckpoint struct {
+	// Start of the time window (exclusive) as unix timestamp
+	Start int64 `json:"start"`
+	// Time cursor (inclusive) of the page being returned as unix timestamp, 0 for now
+	End int64 `json:"end"`
+	// Offset within the records at End, used when more than a page shares one second
+	Offset int `json:"ofs"`
+	// Times of the records at the cursor which were already returned, keyed by ID
+	Seen map[string]float64 `json:"seen,omitempty"`
+}
+
+// pageRecord is a record of any paginated endpoint
+type pageRecord struct {
+	id    string
+	time  float64
+	value interface{}
+}
+
+// pageFetcher returns the records of one page
+type pageFetcher func(ctx context.Context, values url.Values) ([]pageRecord, error)
+
+// pager walks a paginated endpoint from the newest to the oldest record. It pages with
+// the end time of the oldest record seen so far, which keeps pages stable while new
+// records arrive, and drops the records repeated at the page boundary by ID.
+type pager struct {
+	ctx     context.Context
+	fetch   pageFetcher
+	args    map[string]string
+	cursor  PageCheckpoint
+	next    PageCheckpoint
+	buffer  []pageRecord
+	current pageRecord
+	last    bool
+	done    bool
+	err     error
+}
+
+func newPager(ctx context.Context, opts PageOptions, fetch pageFetcher) *pager {
+	p := &pager{ctx: ctx, fetch: fetch, args: opts.Args}
+	if opts.Checkpoint != nil {
+		p.cursor = *opts.Checkpoint
+	} else {
+		if !opts.Start.IsZero() {
+			p.cursor.Start = opts.Start.Unix()
+		}
+		if !opts.End.IsZero() {
+			p.cursor.End = opts.End.Unix()
+		}
+	}
+	if p.cursor.Seen == nil {
+		p.cursor.Seen = map[string]float64{}
+	}
+	return p
+}
+
+// advance returns the next record, fetching pages as needed
+func (p *pager) advance() bool {
+	for len(p.buffer) == 0 {
+		if p.done || p.err != nil {
+			return false
+		}
+		if p.last {
+			p.done = true
+			return false
+		}
+		if p.next.Seen != nil {
+			// Keep the returned records which the next page fetches again
+			for id, t := range p.cursor.Seen {
+				if t <= float64(p.next.End) {
+					p.next.Seen[id] = t
+				}
+			}
+			p.cursor, p.next = p.next, PageCheckpoint{}
+		}
+		if err := p.load(); err != nil {
+			p.err = err
+			return false
+		}
+	}
+
+	p.current, p.buffer = p.buffer[0], p.buffer[1:]
+	p.cursor.Seen[p.current.id] = p.current.time
+	return true
+}
+
+// load fetches the page at the cursor and computes the cursor of the following page
+func (p *pager) load() error {
+	values := url.Values{}
+	for key, value := range p.args {
+		values.Set(key, value)
+	}
+	if p.cursor.Start > 0 {
+		values.Set("start", strconv.FormatInt(p.cursor.Start, 10))
+	}
+	if p.cursor.End > 0 {
+		values.Set("end", strconv.FormatInt(p.cursor.End, 10))
+	}
+	if p.cursor.Offset > 0 {
+		values.Set("ofs", strconv.Itoa(p.cursor.Offset))
+	}
+
+	records, err := p.fetch(p.ctx, values)
+	if err != nil {
+		return err
+	}
+	sort.SliceStable(records, func(i, j int) bool {
+		if records[i].time != records[j].time {
+			return records[i].time > records[j].time
+		}
+		return records[i].id > records[j].id
+	})
+
+	for _, record := range records {
+		if _, seen := p.cursor.Seen[record.id]; !seen {
+			p.buffer = append(p.buffer, record)
+		}
+	}
+	if len(records) < pageSize {
+		p.last = true
+		return nil
+	}
+
+	// Continue below the oldest record. The end is inclusive, so the records of its
+	// second are fetched again and dropped as seen.
+	oldest := records[len(records)-1].time
+	next := PageCheckpoint{Start: p.cursor.Start, End: int64(math.Ceil(oldest)), Seen: map[string]float64{}}
+	if p.cursor.End > 0 && next.End >= p.cursor.End {
+		// The whole page shares one second, continue with an offset instead
+		next.End = p.cursor.End
+		next.Offset = p.cursor.Offset + len(records)
+	}
+	p.next = next
+	return nil
+}
+
+// checkpoint returns the position after the last returned record
+func (p *pager) checkpoint() PageCheckpoint {
+	cp := p.cursor
+	cp.Seen = map[string]float64{}
+	for id, t := range p.cursor.Seen {
+		// Records newer than the cursor cannot be returned again
+		if p.cursor.End == 0 || t <= float64(p.cursor.End) {
+			cp.Seen[id] = t
+		}
+	}
+	return cp
+}
+
+// ClosedOrdersIterator walks all closed orders of a time window, newest first
+type ClosedOrdersIterator struct {
+	pager *pager
+}
+
+// IterateClosedOrders returns an iterator over the closed orders in the window of opts,
+// selected by their close time
+func (api *KrakenAPI) IterateClosedOrders(ctx context.Context, opts PageOptions) *ClosedOrdersIterator {
+	return &ClosedOrdersIterator{pager: newPager(ctx, opts, func(ctx context.Context, values url.Values) ([]pageRecord, error) {
+		values.Set("closetime", "close")
+		resp, err := api.queryPrivateContext(ctx, "ClosedOrders", values, &ClosedOrdersResponse{})
+		if err != nil {
+			return nil, err
+		}
+
+		closed := resp.(*ClosedOrdersResponse).Closed
+		records := make([]pageRecord, 0, len(closed))
+		for id, order := range closed {
+			order.TransactionID = id
+			records = append(records, pageRecord{id: id, time: order.CloseTime, value: order})
+		}
+		return records, nil
+	})}
+}
+
+// Next advances to the next order, it returns false at the end or on error
+func (it *ClosedOrdersIterator) Next() bool {
+	return it.pager.advance()
+}
+
+// Order returns the current order
+func (it *ClosedOrdersIterator) Order() Order {
+	return it.pager.current.value.(Order)
+}
+
+// Err returns the error that stopped the iteration
+func (it *ClosedOrdersIterator) Err() error {
+	return it.pager.err
+}
+
+// Checkpoint returns the position after the current order
+func (it *ClosedOrdersIterator) Checkpoint() PageCheckpoint {
+	return it.pager.checkpoint()
+}
+
+// TradesHistoryIterator walks all own trades of a time window, newest first
+type TradesHistoryIterator struct {
+	pager *pager
+}
+
+// IterateTradesHistory returns an iterator over the trades in the window of opts
+func (api *KrakenAPI) IterateTradesHistory(ctx context.Context, opts PageOptions) *TradesHistoryIterator {
+	return &TradesHistoryIterator{pager: newPager(ctx, opts, func(ctx context.Context, values url.Values) ([]pageRecord, error) {
+		resp, err := api.queryPrivateContext(ctx, "TradesHistory", values, &TradesHistoryResponse{})
+		if err != nil {
+			return nil, err
+		}
+
+		trades := resp.(*TradesHistoryResponse).Trades
+		records := make([]pageRecord, 0, len(trades))
+		for id, trade := range trades {
+			trade.TradeID = id
+			records = append(records, pageRecord{id: id, time: trade.Time, value: trade})
+		}
+		return records, nil
+	})}
+}
+
+// Next advances to the next trade, it returns false at the end or on error
+func (it *TradesHistoryIterator) Next() bool {
+	return it.pager.advance()
+}
+
+// Trade returns the current trade
+func (it *TradesHistoryIterator) Trade() TradeHistoryInfo {
+	return it.pager.current.value.(TradeHistoryInfo)
+}
+
+// Err returns the error that stopped the iteration
+func (it *TradesHistoryIterator) Err() error {
+	return it.pager.err
+}
+
+// Checkpoint returns the position after the current trade
+func (it *TradesHistoryIterator) Checkpoint() PageCheckpoint {
+	return it.pager.checkpoint()
+}
+
+// LedgersIterator walks all ledger entries of a time window, newest first
+type LedgersIterator struct {
+	pager *pager
+}
+
+// IterateLedgers returns an iterator over the ledger entries in the window of opts
+func (api *KrakenAPI) IterateLedgers(ctx context.Context, opts PageOptions) *LedgersIterator {
+	return &LedgersIterator{pager: newPager(ctx, opts, func(ctx context.Context, values url.Values) ([]pageRecord, error) {
+		if asset := values.Get("asset"); asset != "" {
+			values.Set("asset", api.assetList(asset))
+		}
+		resp, err := api.queryPrivateContext(ctx, "Ledgers", values, &LedgersResponse{})
+		if err != nil {
+			return nil, err
+		}
+
+		ledger := resp.(*LedgersResponse).Ledger
+		records := make([]pageRecord, 0, len(ledger))
+		for id, entry := range ledger {
+			entry.LedgerID = id
+			records = append(records, pageRecord{id: id, time: entry.Time, value: entry})
+		}
+		return records, nil
+	})}
+}
+
+// Next advances to the next ledger entry, it returns false at the end or on error
+func (it *LedgersIterator) Next() bool {
+	return it.pager.advance()
+}
+
+// Ledger returns the current ledger entry
+func (it *LedgersIterator) Ledger() LedgerInfo {
+	return it.pager.current.value.(LedgerInfo)
+}
+
+// Err returns the error that stopped the iteration
+func (it *LedgersIterator) Err() error {
+	return it.pager.err
+}
+
+// Checkpoint returns the position after the current ledger entry
+func (it *LedgersIterator) Checkpoint() PageCheckpoint {
+	return it.pager.checkpoint()
+}
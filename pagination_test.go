@@ -0,0 +1,107 @@
+package krakenapi
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"testing"
+)
+
+// fakeLedger answers page requests like Kraken: newest first, end inclusive, start exclusive
+func fakeLedger(records []pageRecord, calls *int) pageFetcher {
+	return func(ctx context.Context, values url.Values) ([]pageRecord, error) {
+		*calls++
+		end, _ := strconv.ParseFloat(values.Get("end"), 64)
+		start, _ := strconv.ParseFloat(values.Get("start"), 64)
+		ofs, _ := strconv.Atoi(values.Get("ofs"))
+
+		var matching []pageRecord
+		for _, record := range records {
+			if (end == 0 || record.time <= end) && record.time > start {
+				matching = append(matching, record)
+			}
+		}
+		sort.Slice(matching, func(i, j int) bool { return matching[i].time > matching[j].time })
+		if ofs >= len(matching) {
+			return nil, nil
+		}
+		matching = matching[ofs:]
+		if len(matching) > pageSize {
+			matching = matching[:pageSize]
+		}
+		return matching, nil
+	}
+}
+
+func testRecords() []pageRecord {
+	var records []pageRecord
+	// 70 records in one second force paging with an offset
+	for i := 0; i < 70; i++ {
+		records = append(records, pageRecord{id: fmt.Sprintf("A%02d", i), time: 1000.5})
+	}
+	for i := 0; i < 80; i++ {
+		records = append(records, pageRecord{id: fmt.Sprintf("B%02d", i), time: 900 + float64(i)/3})
+	}
+	return records
+}
+
+func TestPagerWalksAllRecords(t *testing.T) {
+	records := testRecords()
+	calls := 0
+	p := newPager(context.Background(), PageOptions{}, fakeLedger(records, &calls))
+
+	seen := map[string]bool{}
+	previous := 2000.0
+	for p.advance() {
+		if seen[p.current.id] {
+			t.Errorf("pager returned %s twice", p.current.id)
+		}
+		if p.current.time > previous {
+			t.Errorf("pager should return the newest records first")
+		}
+		seen[p.current.id] = true
+		previous = p.current.time
+	}
+
+	if p.err != nil {
+		t.Fatalf("pager should not fail, got %s", p.err)
+	}
+	if len(seen) != len(records) {
+		t.Errorf("pager should return all %d records, got %d in %d calls", len(records), len(seen), calls)
+	}
+}
+
+func TestPagerResumesFromCheckpoint(t *testing.T) {
+	records := testRecords()
+	calls := 0
+	first := newPager(context.Background(), PageOptions{}, fakeLedger(records, &calls))
+
+	seen := map[string]bool{}
+	for i := 0; i < 95 && first.advance(); i++ {
+		seen[first.current.id] = true
+	}
+	cp := first.checkpoint()
+
+	second := newPager(context.Background(), PageOptions{Checkpoint: &cp}, fakeLedger(records, &calls))
+	for second.advance() {
+		if seen[second.current.id] {
+			t.Errorf("resumed pager returned %s again", second.current.id)
+		}
+		seen[second.current.id] = true
+	}
+	if len(seen) != len(records) {
+		t.Errorf("resumed pager should complete the records, got %d of %d", len(seen), len(records))
+	}
+}
+
+func TestPagerRespectsContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	it := New("", "").IterateLedgers(ctx, PageOptions{})
+	if it.Next() || it.Err() != context.Canceled {
+		t.Errorf("Iterators should stop with a canceled context, got %v", it.Err())
+	}
+}
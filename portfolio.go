@@ -0,0 +1,245 @@
+package krakenapi
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// valuationPlaces is the precision of the divisions when converting through an inverse pair
+const valuationPlaces = 18
+
+// routeAssets are the assets a holding is converted through when no pair with the
+// reference currency exists, in order of preference
+var routeAssets = []string{"XBT", "USD"}
+
+// HoldingValuation is the value of one balance in the reference currency
+type HoldingValuation struct {
+	// Asset name as returned by Balance, e.g. "XXBT" or "DOT.S"
+	Asset  string
+	Amount Decimal
+	// Price of one unit in the reference currency
+	Price Decimal
+	Value Decimal
+	// Pairs the price was derived from, empty for the reference currency itself
+	Route []string
+	// No price was found, Value is zero and not part of the total
+	Unpriced bool
+}
+
+// PortfolioValuation is the value of all balances in a reference currency
+type PortfolioValuation struct {
+	// Reference currency, e.g. "ZUSD"
+	Quote    string
+	Holdings []HoldingValuation
+	Total    Decimal
+	// Last trade prices of the pairs used, keyed by pair name
+	Prices map[string]Decimal
+	Time   time.Time
+}
+
+// Portfolio values the account's balances in a reference currency with the last trade
+// prices. Holdings without a pair to the reference currency are converted through XBT
+// or USD.
+type Portfolio struct {
+	api *KrakenAPI
+}
+
+// pairLeg converts an amount of one asset into another through a pair
+type pairLeg struct {
+	pair string
+	// The converted asset is the quote of the pair, the amount is divided by the price
+	inverse bool
+}
+
+// NewPortfolio creates a Portfolio. It uses the registry attached to api and loads one
+// with LoadRegistry when there is none.
+func NewPortfolio(api *KrakenAPI) *Portfolio {
+	return &Portfolio{api: api}
+}
+
+// Value fetches the balances and values them in quote, e.g. "USD", "ZEUR" or "BTC"
+func (p *Portfolio) Value(ctx context.Context, quote string) (*PortfolioValuation, error) {
+	balances, err := p.api.BalancesContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return p.ValueBalances(ctx, balances, quote)
+}
+
+// ValueBalances values balances in quote. Balances of staked or earning assets like
+// "DOT.S" or "XBT.M" are valued as the underlying asset.
+func (p *Portfolio) ValueBalances(ctx context.Context, balances BalanceMap, quote string) (*PortfolioValuation, error) {
+	registry := p.api.registry
+	if registry == nil {
+		var err error
+		if registry, err = p.api.LoadRegistry(); err != nil {
+			return nil, err
+		}
+	}
+	quoteName, found := registry.AssetName(quote)
+	if !found {
+		return nil, fmt.Errorf("unknown asset %s", quote)
+	}
+	pairs := pairsByAssets(registry)
+
+	// Collect every possible route before fetching the prices in one request
+	routes := map[string][][]pairLeg{}
+	needed := map[string]bool{}
+	for asset, amount := range balances {
+		if amount.IsZero() {
+			continue
+		}
+		routes[asset] = findRoutes(registry, pairs, asset, quoteName)
+		for _, route := range routes[asset] {
+			for _, leg := range route {
+				needed[leg.pair] = true
+			}
+		}
+	}
+
+	prices := map[string]Decimal{}
+	if len(needed) > 0 {
+		names := make([]string, 0, len(needed))
+		for name := range needed {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		tickers, err := p.api.TickersContext(ctx, names...)
+		if err != nil {
+			return nil, err
+		}
+		for name, ticker := range tickers {
+			if len(ticker.Close) == 0 {
+				continue
+			}
+			if price := ticker.Close[0]; price.Sign() > 0 {
+				prices[name] = price
+			}
+		}
+	}
+
+	valuation := &PortfolioValuation{Quote: quoteName, Prices: map[string]Decimal{}, Time: time.Now()}
+	for asset, amount := range balances {
+		if amount.IsZero() {
+			continue
+		}
+		holding := HoldingValuation{Asset: asset, Amount: amount, Unpriced: true}
+		for _, route := range routes[asset] {
+			price, ok := routePrice(route, prices)
+			if !ok {
+				continue
+			}
+			holding.Unpriced = false
+			holding.Price = price
+			holding.Value = convert(amount, route, prices)
+			if info, ok := registry.Asset(quoteName); ok {
+				holding.Value = holding.Value.Round(int32(info.Decimals))
+			}
+			holding.Route = []string{}
+			for _, leg := range route {
+				holding.Route = append(holding.Route, leg.pair)
+				valuation.Prices[leg.pair] = prices[leg.pair]
+			}
+			valuation.Total = valuation.Total.Add(holding.Value)
+			break
+		}
+		valuation.Holdings = append(valuation.Holdings, holding)
+	}
+
+	sort.Slice(valuation.Holdings, func(i, j int) bool {
+		return valuation.Holdings[i].Asset < valuation.Holdings[j].Asset
+	})
+	return valuation, nil
+}
+
+// pairsByAssets returns the names of the pairs keyed by their base and quote asset
+func pairsByAssets(registry *Registry) map[[2]string]string {
+	pairs := map[[2]string]string{}
+	names := registry.Pairs()
+	sort.Strings(names)
+	for _, name := range names {
+		// dark pool pairs share base and quote with the regular pair
+		if strings.HasSuffix(name, ".d") {
+			continue
+		}
+		info, _ := registry.Pair(name)
+		key := [2]string{info.Base, info.Quote}
+		if _, taken := pairs[key]; !taken {
+			pairs[key] = name
+		}
+	}
+	return pairs
+}
+
+// findRoutes returns the routes from the balance asset to quote, most direct first.
+// The name of an earning asset like "DOT.S" falls back to its underlying asset.
+func findRoutes(registry *Registry, pairs map[[2]string]string, asset string, quote string) [][]pairLeg {
+	candidates := []string{asset}
+	if i := strings.Index(asset, "."); i > 0 {
+		candidates = append(candidates, asset[:i])
+	}
+
+	var routes [][]pairLeg
+	for _, candidate := range candidates {
+		name, found := registry.AssetName(candidate)
+		if !found {
+			continue
+		}
+		if name == quote {
+			return append(routes, []pairLeg{})
+		}
+		if leg, ok := findLeg(pairs, name, quote); ok {
+			routes = append(routes, []pairLeg{leg})
+		}
+		for _, via := range routeAssets {
+			viaName, found := registry.AssetName(via)
+			if !found || viaName == name || viaName == quote {
+				continue
+			}
+			first, ok := findLeg(pairs, name, viaName)
+			if !ok {
+				continue
+			}
+			if second, ok := findLeg(pairs, viaName, quote); ok {
+				routes = append(routes, []pairLeg{first, second})
+			}
+		}
+	}
+	return routes
+}
+
+// findLeg returns the pair converting from into to
+func findLeg(pairs map[[2]string]string, from string, to string) (pairLeg, bool) {
+	if name, ok := pairs[[2]string{from, to}]; ok {
+		return pairLeg{pair: name}, true
+	}
+	if name, ok := pairs[[2]string{to, from}]; ok {
+		return pairLeg{pair: name, inverse: true}, true
+	}
+	return pairLeg{}, false
+}
+
+// routePrice returns the price of one unit converted along route, false if a price is missing
+func routePrice(route []pairLeg, prices map[string]Decimal) (Decimal, bool) {
+	for _, leg := range route {
+		if _, ok := prices[leg.pair]; !ok {
+			return Decimal{}, false
+		}
+	}
+	return convert(NewDecimalFromInt(1), route, prices), true
+}
+
+// convert converts amount along route
+func convert(amount Decimal, route []pairLeg, prices map[string]Decimal) Decimal {
+	for _, leg := range route {
+		if leg.inverse {
+			amount = amount.Div(prices[leg.pair], valuationPlaces)
+		} else {
+			amount = amount.Mul(prices[leg.pair])
+		}
+	}
+	return amount
+}
@@ -0,0 +1,86 @@
+package krakenapi
+
+import (
+	"context"
+	"testing"
+)
+
+func TestPortfolioValueBalances(t *testing.T) {
+	api := newTestAPI(`{"error":[],"result":{
+		"XXBTZEUR":{"c":["25000.0","0.1"]},
+		"XXBTZUSD":{"c":["27000.0","0.1"]},
+		"XETHXXBT":{"c":["0.05","1"]},
+		"DOTUSD":{"c":["5.0000","1"]},
+		"ZEURZUSD":{"c":["1.08","100"]}
+	}}`)
+	api.WithRegistry(NewRegistry(AssetPairMap{
+		"XXBTZEUR": {Altname: "XBTEUR", Base: "XXBT", Quote: "ZEUR"},
+		"XXBTZUSD": {Altname: "XBTUSD", Base: "XXBT", Quote: "ZUSD"},
+		"XETHXXBT": {Altname: "ETHXBT", Base: "XETH", Quote: "XXBT"},
+		"DOTUSD":   {Altname: "DOTUSD", Base: "DOT", Quote: "ZUSD"},
+		"ZEURZUSD": {Altname: "EURUSD", Base: "ZEUR", Quote: "ZUSD"},
+	}, AssetMap{
+		"XXBT": {Altname: "XBT", Decimals: 10},
+		"XETH": {Altname: "ETH", Decimals: 10},
+		"DOT":  {Altname: "DOT", Decimals: 10},
+		"ZUSD": {Altname: "USD", Decimals: 4},
+		"ZEUR": {Altname: "EUR", Decimals: 4},
+	}))
+
+	balances := BalanceMap{
+		"XXBT":  MustDecimal("0.5"),
+		"XETH":  MustDecimal("2"),
+		"DOT.S": MustDecimal("10"),
+		"ZUSD":  MustDecimal("100"),
+		"ZEUR":  MustDecimal("0"),
+		"KFEE":  MustDecimal("100"),
+	}
+	valuation, err := NewPortfolio(api).ValueBalances(context.Background(), balances, "EUR")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if valuation.Quote != "ZEUR" || len(valuation.Holdings) != 5 {
+		t.Fatalf("ValueBalances() returned %+v", valuation)
+	}
+
+	expected := map[string]struct {
+		value string
+		route []string
+	}{
+		"XXBT":  {"12500", []string{"XXBTZEUR"}},
+		"XETH":  {"2500", []string{"XETHXXBT", "XXBTZEUR"}},
+		"DOT.S": {"46.2963", []string{"DOTUSD", "ZEURZUSD"}},
+		"ZUSD":  {"92.5926", []string{"ZEURZUSD"}},
+	}
+	for _, holding := range valuation.Holdings {
+		if holding.Asset == "KFEE" {
+			if !holding.Unpriced || !holding.Value.IsZero() {
+				t.Errorf("KFEE should not be priced, got %+v", holding)
+			}
+			continue
+		}
+		want := expected[holding.Asset]
+		if holding.Unpriced || holding.Value.String() != want.value || len(holding.Route) != len(want.route) {
+			t.Errorf("%s: got %+v, want value %s through %v", holding.Asset, holding, want.value, want.route)
+			continue
+		}
+		for i := range want.route {
+			if holding.Route[i] != want.route[i] {
+				t.Errorf("%s: got route %v, want %v", holding.Asset, holding.Route, want.route)
+			}
+		}
+	}
+	if holding := valuation.Holdings[2]; holding.Asset != "XETH" || holding.Price.String() != "1250" {
+		t.Errorf("XETH should cost 1250 EUR, got %+v", holding)
+	}
+	if valuation.Total.String() != "15138.8889" {
+		t.Errorf("Total is %s", valuation.Total)
+	}
+	if len(valuation.Prices) != 4 || valuation.Prices["ZEURZUSD"].String() != "1.08" {
+		t.Errorf("Prices should hold the prices used, got %v", valuation.Prices)
+	}
+
+	if _, err := NewPortfolio(api).ValueBalances(context.Background(), balances, "NOPE"); err == nil {
+		t.Error("ValueBalances() should reject unknown reference currencies")
+	}
+}
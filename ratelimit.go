@@ -0,0 +1,125 @@
+package krakenapi
+
+import (
+	"context"
+	"math"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RateLimiter throttles requests to the Kraken API
+type RateLimiter interface {
+	// Wait blocks until method may be called or ctx is done
+	Wait(ctx context.Context, method string) error
+}
+
+// Call counter limits of the Kraken verification tiers
+// See https://docs.kraken.com/rest/#section/Rate-Limits
+const (
+	StarterMaxCounter      = 15
+	StarterDecay           = 0.33
+	IntermediateMaxCounter = 20
+	IntermediateDecay      = 0.5
+	ProMaxCounter          = 20
+	ProDecay               = 1
+)
+
+// PublicCallInterval is the time between two public calls Kraken allows
+const PublicCallInterval = time.Second
+
+// CounterRateLimiter implements Kraken's call counter for private methods,
+// which every call increases and which decays over time, and spaces public calls.
+// Order placement is limited by the matching engine, not the counter, and is not throttled.
+// It is safe for concurrent use.
+type CounterRateLimiter struct {
+	mu         sync.Mutex
+	maxCounter float64
+	decay      float64
+	counter    float64
+	updated    time.Time
+	nextPublic time.Time
+
+	// Replaced by tests
+	now   func() time.Time
+	sleep func(ctx context.Context, d time.Duration) error
+}
+
+// NewRateLimiter creates a CounterRateLimiter for the given call counter limit and
+// decay per second, e.g. NewRateLimiter(StarterMaxCounter, StarterDecay)
+func NewRateLimiter(maxCounter float64, decayPerSecond float64) *CounterRateLimiter {
+	return &CounterRateLimiter{maxCounter: maxCounter, decay: decayPerSecond, now: time.Now, sleep: sleepContext}
+}
+
+// callCost returns how much a private method increases the call counter
+func callCost(method string) float64 {
+	switch method {
+	case "Ledgers", "QueryLedgers", "TradesHistory", "QueryTrades":
+		return 2
+	case "AddOrder", "AddOrderBatch", "EditOrder", "CancelOrder", "CancelOrderBatch", "CancelAll", "CancelAllOrdersAfter":
+		return 0
+	}
+	return 1
+}
+
+// Wait blocks until method may be called or ctx is done
+func (l *CounterRateLimiter) Wait(ctx context.Context, method string) error {
+	if isStringInSlice(method, publicMethods) {
+		l.mu.Lock()
+		now := l.now()
+		at := l.nextPublic
+		if at.Before(now) {
+			at = now
+		}
+		l.nextPublic = at.Add(PublicCallInterval)
+		l.mu.Unlock()
+
+		return l.sleep(ctx, at.Sub(now))
+	}
+
+	cost := callCost(method)
+	for {
+		l.mu.Lock()
+		now := l.now()
+		if !l.updated.IsZero() {
+			l.counter = math.Max(0, l.counter-now.Sub(l.updated).Seconds()*l.decay)
+		}
+		l.updated = now
+		if l.counter+cost <= l.maxCounter {
+			l.counter += cost
+			l.mu.Unlock()
+			return ctx.Err()
+		}
+		delay := time.Duration((l.counter + cost - l.maxCounter) / l.decay * float64(time.Second))
+		l.mu.Unlock()
+
+		if err := l.sleep(ctx, delay); err != nil {
+			return err
+		}
+	}
+}
+
+// sleepContext pauses for d or until ctx is done
+func sleepContext(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// isRateLimitError reports whether Kraken rejected a call for exceeding a rate limit
+func isRateLimitError(err error) bool {
+	if err == nil {
+		return false
+	}
+	msg := err.Error()
+	return strings.Contains(msg, "EAPI:Rate limit exceeded") || strings.Contains(msg, "EGeneral:Too many requests")
+}
@@ -0,0 +1,87 @@
+package krakenapi
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testClock is the clock of a CounterRateLimiter in tests, it moves when the limiter sleeps
+type testClock struct {
+	mu    sync.Mutex
+	now   time.Time
+	slept []time.Duration
+}
+
+// newTestLimiter returns a limiter running on a testClock
+func newTestLimiter(maxCounter float64, decayPerSecond float64) (*CounterRateLimiter, *testClock) {
+	clock := &testClock{now: time.Unix(1700000000, 0)}
+	limiter := NewRateLimiter(maxCounter, decayPerSecond)
+	limiter.now = func() time.Time {
+		clock.mu.Lock()
+		defer clock.mu.Unlock()
+		return clock.now
+	}
+	limiter.sleep = func(ctx context.Context, d time.Duration) error {
+		if d <= 0 || ctx.Err() != nil {
+			return ctx.Err()
+		}
+		clock.mu.Lock()
+		defer clock.mu.Unlock()
+		clock.now = clock.now.Add(d)
+		clock.slept = append(clock.slept, d)
+		return nil
+	}
+	return limiter, clock
+}
+
+func TestCounterRateLimiter(t *testing.T) {
+	limiter, clock := newTestLimiter(4, 2)
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		if err := limiter.Wait(ctx, "Ledgers"); err != nil {
+			t.Fatalf("Wait() should not return an error, got %s", err)
+		}
+	}
+	if len(clock.slept) != 0 || limiter.counter != 4 {
+		t.Errorf("Wait() should not block below the maximum counter, slept %v with counter %v", clock.slept, limiter.counter)
+	}
+
+	// The counter is full, the next call waits until 2 points decayed
+	if err := limiter.Wait(ctx, "TradesHistory"); err != nil {
+		t.Fatalf("Wait() should not return an error, got %s", err)
+	}
+	if len(clock.slept) != 1 || clock.slept[0] != time.Second || limiter.counter != 4 {
+		t.Errorf("Wait() should block until the counter decayed, slept %v with counter %v", clock.slept, limiter.counter)
+	}
+
+	if err := limiter.Wait(ctx, "AddOrder"); err != nil || len(clock.slept) != 1 {
+		t.Errorf("Wait() should not throttle order placement, got %v", err)
+	}
+
+	// Points decay while no calls are made
+	clock.now = clock.now.Add(1500 * time.Millisecond)
+	if err := limiter.Wait(ctx, "Balance"); err != nil || len(clock.slept) != 1 || limiter.counter != 2 {
+		t.Errorf("Wait() should decay the counter, slept %v with counter %v (%v)", clock.slept, limiter.counter, err)
+	}
+}
+
+func TestCounterRateLimiterPublic(t *testing.T) {
+	limiter, clock := newTestLimiter(StarterMaxCounter, StarterDecay)
+	for i := 0; i < 3; i++ {
+		if err := limiter.Wait(context.Background(), "Trades"); err != nil {
+			t.Fatalf("Wait() should not return an error, got %s", err)
+		}
+	}
+	if len(clock.slept) != 2 || clock.slept[0] != PublicCallInterval || clock.slept[1] != PublicCallInterval {
+		t.Errorf("Wait() should space public calls, slept %v", clock.slept)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := limiter.Wait(ctx, "Trades"); err != context.Canceled || len(clock.slept) != 2 {
+		t.Errorf("Wait() should respect the context, got %v", err)
+	}
+}
@@ -0,0 +1,233 @@
+package krakenapi
+
+import (
+	"sort"
+	"strings"
+	"sync"
+)
+
+// displayNames maps the asset names shown on kraken.com to Kraken's altnames
+var displayNames = map[string]string{
+	"BTC":  "XBT",
+	"DOGE": "XDG",
+}
+
+// Registry resolves the different names Kraken uses for one asset or pair
+// (XBTUSD, XXBTZUSD, XBT/USD, BTC/USD...) to the canonical name used as key in API results.
+// It is safe for concurrent use.
+type Registry struct {
+	mu           sync.RWMutex
+	pairs        AssetPairMap
+	assets       AssetMap
+	pairAliases  map[string]string
+	assetAliases map[string]string
+}
+
+// NewRegistry builds a Registry from the results of AssetPairsWithInfo and AssetsWithClass
+func NewRegistry(pairs AssetPairMap, assets AssetMap) *Registry {
+	r := &Registry{}
+	r.Load(pairs, assets)
+	return r
+}
+
+// LoadRegistry fetches all pairs and assets, builds a Registry from them and
+// attaches it to the client so every endpoint accepts any alias
+func (api *KrakenAPI) LoadRegistry() (*Registry, error) {
+	pairs, err := api.AssetPairsWithInfo("", "")
+	if err != nil {
+		return nil, err
+	}
+	assets, err := api.AssetsWithClass("")
+	if err != nil {
+		return nil, err
+	}
+
+	registry := NewRegistry(pairs, assets)
+	api.WithRegistry(registry)
+	return registry, nil
+}
+
+// Load replaces the registry's pairs and assets
+func (r *Registry) Load(pairs AssetPairMap, assets AssetMap) {
+	assetAliases := map[string]string{}
+	for name, info := range assets {
+		addAlias(assetAliases, name, name)
+		addAlias(assetAliases, info.Altname, name)
+	}
+	for display, altname := range displayNames {
+		if name, found := assetAliases[altname]; found {
+			addAlias(assetAliases, display, name)
+		}
+	}
+
+	// Explicit names win over names combined from base and quote aliases
+	pairAliases := map[string]string{}
+	for name, info := range pairs {
+		addAlias(pairAliases, name, name)
+		addAlias(pairAliases, info.Altname, name)
+		addAlias(pairAliases, info.WSName, name)
+	}
+	names := make([]string, 0, len(pairs))
+	for name := range pairs {
+		// dark pool pairs like XBTUSD.d share base and quote with the regular pair
+		if !strings.Contains(name, ".") {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		info := pairs[name]
+		for _, base := range aliasesOf(assetAliases, info.Base) {
+			for _, quote := range aliasesOf(assetAliases, info.Quote) {
+				addAlias(pairAliases, base+quote, name)
+				addAlias(pairAliases, base+"/"+quote, name)
+			}
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.pairs = pairs
+	r.assets = assets
+	r.pairAliases = pairAliases
+	r.assetAliases = assetAliases
+}
+
+// PairName returns the canonical name of the pair known under alias
+func (r *Registry) PairName(alias string) (string, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	name, found := r.pairAliases[normalizeName(alias)]
+	return name, found
+}
+
+// Pair returns the information of the pair known under alias,
+// including its Altname and WSName
+func (r *Registry) Pair(alias string) (AssetPairInfo, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	info, found := r.pairs[r.pairAliases[normalizeName(alias)]]
+	return info, found
+}
+
+// AssetName returns the canonical name of the asset known under alias
+func (r *Registry) AssetName(alias string) (string, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	name, found := r.assetAliases[normalizeName(alias)]
+	return name, found
+}
+
+// Asset returns the information of the asset known under alias
+func (r *Registry) Asset(alias string) (AssetInfo, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	info, found := r.assets[r.assetAliases[normalizeName(alias)]]
+	return info, found
+}
+
+// Pairs returns the canonical names of all known pairs
+func (r *Registry) Pairs() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	names := make([]string, 0, len(r.pairs))
+	for name := range r.pairs {
+		names = append(names, name)
+	}
+	return names
+}
+
+// addAlias registers alias for name unless alias is already taken
+func addAlias(aliases map[string]string, alias, name string) {
+	alias = normalizeName(alias)
+	if alias == "" {
+		return
+	}
+	if _, taken := aliases[alias]; !taken {
+		aliases[alias] = name
+	}
+}
+
+// aliasesOf returns every alias registered for the canonical name
+func aliasesOf(aliases map[string]string, name string) []string {
+	var result []string
+	for alias, target := range aliases {
+		if target == name {
+			result = append(result, alias)
+		}
+	}
+	return result
+}
+
+func normalizeName(name string) string {
+	return strings.ToUpper(strings.TrimSpace(name))
+}
+
+// pairName returns the canonical name for pair if a registry is attached
+func (api *KrakenAPI) pairName(pair string) string {
+	if api.registry == nil {
+		return pair
+	}
+	if name, found := api.registry.PairName(pair); found {
+		return name
+	}
+	return pair
+}
+
+// pairList returns the canonical names of a comma separated list of pairs
+func (api *KrakenAPI) pairList(pairs string) string {
+	names := strings.Split(pairs, ",")
+	for i, name := range names {
+		names[i] = api.pairName(name)
+	}
+	return strings.Join(names, ",")
+}
+
+// assetName returns the canonical name for asset if a registry is attached
+func (api *KrakenAPI) assetName(asset string) string {
+	if api.registry == nil {
+		return asset
+	}
+	if name, found := api.registry.AssetName(asset); found {
+		return name
+	}
+	return asset
+}
+
+// assetList returns the canonical names of a comma separated list of assets
+func (api *KrakenAPI) assetList(assets string) string {
+	names := strings.Split(assets, ",")
+	for i, name := range names {
+		names[i] = api.assetName(name)
+	}
+	return strings.Join(names, ",")
+}
+
+// resultKey returns the key under which Kraken returned the data requested for pair.
+// Kraken keys results by the canonical pair name, which need not be the name passed in.
+func (api *KrakenAPI) resultKey(pair string, keys []string) (string, bool) {
+	candidates := []string{}
+	for _, key := range keys {
+		if key == pair {
+			return key, true
+		}
+		if key != "last" {
+			candidates = append(candidates, key)
+		}
+	}
+
+	if api.registry != nil {
+		if name, found := api.registry.PairName(pair); found {
+			for _, key := range candidates {
+				if other, _ := api.registry.PairName(key); other == name {
+					return key, true
+				}
+			}
+		}
+	}
+
+	if len(candidates) == 1 {
+		return candidates[0], true
+	}
+	return "", false
+}
@@ -0,0 +1,100 @@
+package krakenapi
+
+import "testing"
+
+func testRegistry() *Registry {
+	return NewRegistry(AssetPairMap{
+		XXBTZUSD:   {Altname: "XBTUSD", WSName: "XBT/USD", Base: "XXBT", Quote: "ZUSD"},
+		"XBTUSD.d": {Altname: "XBTUSD.d", Base: "XXBT", Quote: "ZUSD"},
+		"XDGUSD":   {Altname: "XDGUSD", WSName: "XDG/USD", Base: "XXDG", Quote: "ZUSD"},
+	}, AssetMap{
+		"XXBT": {Altname: "XBT"},
+		"XXDG": {Altname: "XDG"},
+		"ZUSD": {Altname: "USD"},
+	})
+}
+
+func TestRegistryPairName(t *testing.T) {
+	registry := testRegistry()
+
+	aliases := map[string]string{
+		"XXBTZUSD": XXBTZUSD,
+		"XBTUSD":   XXBTZUSD,
+		"XBT/USD":  XXBTZUSD,
+		"btc/usd":  XXBTZUSD,
+		"BTCUSD":   XXBTZUSD,
+		"XBTUSD.d": "XBTUSD.d",
+		"DOGEUSD":  "XDGUSD",
+	}
+	for alias, expected := range aliases {
+		name, found := registry.PairName(alias)
+		if !found || name != expected {
+			t.Errorf("PairName(%q) should be %s, got %q", alias, expected, name)
+		}
+	}
+
+	if _, found := registry.PairName("ETHUSD"); found {
+		t.Errorf("PairName() should not resolve unknown pairs")
+	}
+
+	info, found := registry.Pair("BTC/USD")
+	if !found || info.Altname != "XBTUSD" || info.WSName != "XBT/USD" {
+		t.Errorf("Pair() should return the pair information, got %+v", info)
+	}
+}
+
+func TestRegistryAssetName(t *testing.T) {
+	registry := testRegistry()
+
+	for _, alias := range []string{"XXBT", "XBT", "BTC", "btc"} {
+		if name, _ := registry.AssetName(alias); name != "XXBT" {
+			t.Errorf("AssetName(%q) should be XXBT, got %q", alias, name)
+		}
+	}
+
+	if info, found := registry.Asset("DOGE"); !found || info.Altname != "XDG" {
+		t.Errorf("Asset() should return the asset information, got %+v", info)
+	}
+}
+
+func TestResultKey(t *testing.T) {
+	api := New("", "").WithRegistry(testRegistry())
+
+	key, found := api.resultKey("BTC/USD", []string{"last", XXBTZUSD, "XDGUSD"})
+	if !found || key != XXBTZUSD {
+		t.Errorf("resultKey() should resolve aliases, got %q", key)
+	}
+
+	key, found = New("", "").resultKey("XBTUSD", []string{XXBTZUSD, "last"})
+	if !found || key != XXBTZUSD {
+		t.Errorf("resultKey() should fall back to the only pair in the result, got %q", key)
+	}
+}
+
+func TestRegistryAliasedRequests(t *testing.T) {
+	sent := make(chan testRequest, 1)
+	api := NewWithClient("", "", testClient(`{"error":[],"result":{}}`, sent)).WithRegistry(testRegistry())
+
+	requests := []struct {
+		call     func() error
+		key      string
+		expected string
+	}{
+		{func() error { _, err := api.AssetsWithClass("", "BTC", "USD"); return err }, "asset", "XXBT,ZUSD"},
+		{func() error { _, err := api.AssetPairsWithInfo("", "", "BTC/USD", "XDG/USD"); return err }, "pair", "XXBTZUSD,XDGUSD"},
+		{func() error { _, err := api.TradeVolume(map[string]string{"pair": "XBT/USD,DOGEUSD"}); return err }, "pair", "XXBTZUSD,XDGUSD"},
+		{func() error { _, err := api.TradeBalance(map[string]string{"asset": "BTC"}); return err }, "asset", "XXBT"},
+		{func() error { _, err := api.AddOrder("btc/usd", "buy", "market", "1", nil); return err }, "pair", XXBTZUSD},
+		{func() error { _, err := api.Ledgers(map[string]string{"asset": "BTC,USD"}); return err }, "asset", "XXBT,ZUSD"},
+		{func() error { _, err := api.DepositAddresses("BTC", "Bitcoin"); return err }, "asset", "XXBT"},
+		{func() error { _, err := api.WithdrawInfo("DOGE", "wallet", MustDecimal("1")); return err }, "asset", "XXDG"},
+		{func() error { _, err := api.AccountTransfer("BTC", MustDecimal("1"), "from", "to"); return err }, "asset", "XXBT"},
+	}
+	for _, request := range requests {
+		request.call()
+		req := <-sent
+		if req.Values.Get(request.key) != request.expected {
+			t.Errorf("%s should send %s=%s, got %q", req.Endpoint, request.key, request.expected, req.Values.Get(request.key))
+		}
+	}
+}
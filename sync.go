@@ -0,0 +1,387 @@
+package krakenapi
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// SyncRecord is a trade or ledger entry kept in a SyncStore
+type SyncRecord struct {
+	ID   string          `json:"id"`
+	Time float64         `json:"time"`
+	Data json.RawMessage `json:"data"`
+}
+
+// Trade decodes the record as a TradeHistoryInfo
+func (r SyncRecord) Trade() (TradeHistoryInfo, error) {
+	var trade TradeHistoryInfo
+	if err := json.Unmarshal(r.Data, &trade); err != nil {
+		return trade, fmt.Errorf("invalid trade %s: %s", r.ID, err)
+	}
+	trade.TradeID = r.ID
+	return trade, nil
+}
+
+// Ledger decodes the record as a LedgerInfo
+func (r SyncRecord) Ledger() (LedgerInfo, error) {
+	var entry LedgerInfo
+	if err := json.Unmarshal(r.Data, &entry); err != nil {
+		return entry, fmt.Errorf("invalid ledger entry %s: %s", r.ID, err)
+	}
+	entry.LedgerID = r.ID
+	return entry, nil
+}
+
+// SyncStore persists the records of one stream in time order
+type SyncStore interface {
+	// Last returns the records sharing the newest time, nil if the store is empty
+	Last() ([]SyncRecord, error)
+	// Append adds records ordered by time, none older than the ones returned by Last
+	Append(records []SyncRecord) error
+}
+
+// MemoryStore is a SyncStore kept in memory. It is safe for concurrent use.
+type MemoryStore struct {
+	mu      sync.Mutex
+	records []SyncRecord
+}
+
+// NewMemoryStore creates an empty MemoryStore
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{}
+}
+
+// Last returns the records sharing the newest time
+func (s *MemoryStore) Last() ([]SyncRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return lastRecords(s.records), nil
+}
+
+// Append adds records
+func (s *MemoryStore) Append(records []SyncRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.records = append(s.records, records...)
+	return nil
+}
+
+// Records returns all stored records, oldest first
+func (s *MemoryStore) Records() []SyncRecord {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]SyncRecord(nil), s.records...)
+}
+
+// FileStore is a SyncStore writing one JSON object per line (JSON Lines).
+// A line left incomplete by an interrupted write is discarded on the next Append.
+// It is safe for concurrent use within one process. The file is read once, so it must
+// not be written by others while the store is in use.
+type FileStore struct {
+	mu   sync.Mutex
+	path string
+
+	// Cached after the first read: the size up to the last complete line and the
+	// records sharing the newest time
+	loaded bool
+	size   int64
+	last   []SyncRecord
+}
+
+// NewFileStore creates a FileStore at path, the file is created on the first Append
+func NewFileStore(path string) *FileStore {
+	return &FileStore{path: path}
+}
+
+// Last returns the records sharing the newest time
+func (s *FileStore) Last() ([]SyncRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	return append([]SyncRecord(nil), s.last...), nil
+}
+
+// Records returns all stored records, oldest first
+func (s *FileStore) Records() ([]SyncRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	records, _, err := s.read()
+	return records, err
+}
+
+// load reads the file unless it was read before
+func (s *FileStore) load() error {
+	if s.loaded {
+		return nil
+	}
+	records, size, err := s.read()
+	if err != nil {
+		return err
+	}
+	s.loaded, s.size, s.last = true, size, lastRecords(records)
+	return nil
+}
+
+// read returns all complete records and the size of the file up to the last complete line
+func (s *FileStore) read() ([]SyncRecord, int64, error) {
+	file, err := os.Open(s.path)
+	if os.IsNotExist(err) {
+		return nil, 0, nil
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+	defer file.Close()
+
+	var records []SyncRecord
+	var size int64
+	reader := bufio.NewReader(file)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err == io.EOF {
+			// An incomplete last line is the remainder of an interrupted write
+			return records, size, nil
+		}
+		if err != nil {
+			return nil, 0, err
+		}
+
+		size += int64(len(line))
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		var record SyncRecord
+		if err := json.Unmarshal(line, &record); err != nil {
+			return nil, 0, fmt.Errorf("invalid record in %s at byte %d: %s", s.path, size-int64(len(line)), err)
+		}
+		records = append(records, record)
+	}
+}
+
+// Append adds records and syncs them to disk
+func (s *FileStore) Append(records []SyncRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.load(); err != nil {
+		return err
+	}
+	// A failed write leaves the file in an unknown state, it is read again next time
+	s.loaded = false
+
+	file, err := os.OpenFile(s.path, os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	if err := file.Truncate(s.size); err != nil {
+		return err
+	}
+	if _, err := file.Seek(s.size, io.SeekStart); err != nil {
+		return err
+	}
+
+	writer := bufio.NewWriter(file)
+	written := 0
+	for _, record := range records {
+		line, err := json.Marshal(record)
+		if err != nil {
+			return err
+		}
+		writer.Write(line)
+		writer.WriteByte('\n')
+		written += len(line) + 1
+	}
+	if err := writer.Flush(); err != nil {
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		return err
+	}
+
+	s.loaded, s.size = true, s.size+int64(written)
+	s.last = lastRecords(append(s.last, records...))
+	return nil
+}
+
+// lastRecords returns the records sharing the newest time
+func lastRecords(records []SyncRecord) []SyncRecord {
+	var last []SyncRecord
+	for _, record := range records {
+		switch {
+		case len(last) == 0 || record.Time > last[0].Time:
+			last = []SyncRecord{record}
+		case record.Time == last[0].Time:
+			last = append(last, record)
+		}
+	}
+	return last
+}
+
+// Syncer keeps local stores of the account's trades and ledger entries up to date,
+// fetching only records newer than the newest stored one. New records are fetched and
+// appended page by page from the oldest, so an interrupted sync keeps the pages it
+// stored and resumes where the store ends without leaving gaps.
+type Syncer struct {
+	api     *KrakenAPI
+	trades  SyncStore
+	ledgers SyncStore
+}
+
+// syncFetcher returns the records of one page and the number of records in its window
+type syncFetcher func(ctx context.Context, values url.Values) ([]SyncRecord, int, error)
+
+// NewSyncer creates a Syncer. A nil store skips that stream.
+func NewSyncer(api *KrakenAPI, trades SyncStore, ledgers SyncStore) *Syncer {
+	return &Syncer{api: api, trades: trades, ledgers: ledgers}
+}
+
+// Sync updates both stores
+func (s *Syncer) Sync(ctx context.Context) error {
+	if _, err := s.SyncTrades(ctx); err != nil {
+		return err
+	}
+	_, err := s.SyncLedgers(ctx)
+	return err
+}
+
+// SyncTrades appends the trades newer than the store's newest and returns their number.
+// On error the number of trades appended before it is returned.
+func (s *Syncer) SyncTrades(ctx context.Context) (int, error) {
+	if s.trades == nil {
+		return 0, nil
+	}
+	return syncStore(ctx, s.trades, func(ctx context.Context, values url.Values) ([]SyncRecord, int, error) {
+		resp, err := s.api.queryPrivateContext(ctx, "TradesHistory", values, &TradesHistoryResponse{})
+		if err != nil {
+			return nil, 0, err
+		}
+
+		history := resp.(*TradesHistoryResponse)
+		records := make([]SyncRecord, 0, len(history.Trades))
+		for id, trade := range history.Trades {
+			trade.TradeID = id
+			data, err := json.Marshal(trade)
+			if err != nil {
+				return nil, 0, err
+			}
+			records = append(records, SyncRecord{ID: id, Time: trade.Time, Data: data})
+		}
+		return records, history.Count, nil
+	})
+}
+
+// SyncLedgers appends the ledger entries newer than the store's newest and returns their number.
+// On error the number of entries appended before it is returned.
+func (s *Syncer) SyncLedgers(ctx context.Context) (int, error) {
+	if s.ledgers == nil {
+		return 0, nil
+	}
+	return syncStore(ctx, s.ledgers, func(ctx context.Context, values url.Values) ([]SyncRecord, int, error) {
+		resp, err := s.api.queryPrivateContext(ctx, "Ledgers", values, &LedgersResponse{})
+		if err != nil {
+			return nil, 0, err
+		}
+
+		ledgers := resp.(*LedgersResponse)
+		records := make([]SyncRecord, 0, len(ledgers.Ledger))
+		for id, entry := range ledgers.Ledger {
+			entry.LedgerID = id
+			data, err := json.Marshal(entry)
+			if err != nil {
+				return nil, 0, err
+			}
+			records = append(records, SyncRecord{ID: id, Time: entry.Time, Data: data})
+		}
+		return records, ledgers.Count, nil
+	})
+}
+
+// syncStore appends the records after the store's newest ones, oldest first. Kraken returns
+// the newest records first, so the window is fixed to end now and walked by decreasing offset.
+func syncStore(ctx context.Context, store SyncStore, fetch syncFetcher) (int, error) {
+	last, err := store.Last()
+	if err != nil {
+		return 0, err
+	}
+
+	values := url.Values{}
+	values.Set("end", strconv.FormatInt(time.Now().Unix(), 10))
+	lastTime := 0.0
+	stored := map[string]bool{}
+	if len(last) > 0 {
+		lastTime = last[0].Time
+		// start is exclusive and in whole seconds, the stored records of that second are skipped below
+		values.Set("start", strconv.FormatInt(int64(math.Floor(lastTime))-1, 10))
+		for _, record := range last {
+			stored[record.ID] = true
+		}
+	}
+
+	// appendPage stores the records of a page which are newer than the stored ones
+	count := 0
+	appendPage := func(page []SyncRecord) error {
+		var records []SyncRecord
+		for _, record := range page {
+			if record.Time > lastTime || (record.Time == lastTime && !stored[record.ID]) {
+				records = append(records, record)
+			}
+		}
+		if len(records) == 0 {
+			return nil
+		}
+		sort.SliceStable(records, func(i, j int) bool {
+			if records[i].Time != records[j].Time {
+				return records[i].Time < records[j].Time
+			}
+			return records[i].ID < records[j].ID
+		})
+		if err := store.Append(records); err != nil {
+			return err
+		}
+
+		count += len(records)
+		if newest := records[len(records)-1].Time; newest > lastTime {
+			lastTime = newest
+			stored = map[string]bool{}
+		}
+		for _, record := range records {
+			if record.Time == lastTime {
+				stored[record.ID] = true
+			}
+		}
+		return nil
+	}
+
+	newest, total, err := fetch(ctx, values)
+	if err != nil {
+		return 0, err
+	}
+	for offset := total - pageSize; offset > 0; offset -= pageSize {
+		values.Set("ofs", strconv.Itoa(offset))
+		page, _, err := fetch(ctx, values)
+		if err != nil {
+			return count, err
+		}
+		if err := appendPage(page); err != nil {
+			return count, err
+		}
+	}
+	return count, appendPage(newest)
+}
@@ -0,0 +1,139 @@
+package krakenapi
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+const testTradesHistory = `{"error":[],"result":{"count":3,"trades":{
+	"T1":{"ordertxid":"O1","pair":"XXBTZUSD","time":1688667796.8802,"type":"buy","ordertype":"limit","price":"30010.0","cost":"300.1","fee":"0.78","vol":"0.01","margin":"0.0","misc":""},
+	"T2":{"ordertxid":"O2","pair":"XXBTZUSD","time":1688667796.8802,"type":"sell","ordertype":"market","price":"30011.0","cost":"300.11","fee":"0.78","vol":"0.01","margin":"0.0","misc":""},
+	"T3":{"ordertxid":"O3","pair":"XXBTZUSD","time":1688667800.1,"type":"buy","ordertype":"limit","price":"30012.0","cost":"300.12","fee":"0.78","vol":"0.01","margin":"0.0","misc":"closing"}}}}`
+
+func TestSyncerTrades(t *testing.T) {
+	store := NewMemoryStore()
+	syncer := NewSyncer(newTestAPI(testTradesHistory), store, nil)
+
+	count, err := syncer.SyncTrades(context.Background())
+	if err != nil {
+		t.Fatalf("SyncTrades() should not return an error, got %s", err)
+	}
+	records := store.Records()
+	if count != 3 || len(records) != 3 || records[0].ID != "T1" || records[2].ID != "T3" {
+		t.Errorf("SyncTrades() should store all trades oldest first, got %+v", records)
+	}
+
+	trade, err := records[2].Trade()
+	if err != nil || trade.TradeID != "T3" || !trade.IsClosing() || trade.Price.String() != "30012" {
+		t.Errorf("Stored trades should decode, got %+v (%v)", trade, err)
+	}
+
+	count, err = syncer.SyncTrades(context.Background())
+	if err != nil || count != 0 || len(store.Records()) != 3 {
+		t.Errorf("SyncTrades() should only add new trades, got %d (%v)", count, err)
+	}
+}
+
+func TestSyncerResumes(t *testing.T) {
+	// 120 ledger entries L000 to L119, one per second, pages of 50 newest first
+	failAt := "20"
+	api := NewWithClient("", "", &http.Client{Transport: testTransport{answer: func(req testRequest) string {
+		offset := req.Values.Get("ofs")
+		if offset == failAt {
+			return `{"error":["EService:Unavailable"]}`
+		}
+		start, _ := strconv.Atoi(offset)
+		var entries []string
+		for i := 119 - start; i >= 0 && i > 69-start; i-- {
+			entries = append(entries, fmt.Sprintf(`"L%03d":{"refid":"R%d","time":%d,"type":"deposit","asset":"ZUSD","amount":"1","fee":"0","balance":"%d"}`, i, i, 1000+i, i+1))
+		}
+		return fmt.Sprintf(`{"error":[],"result":{"ledger":{%s},"count":120}}`, strings.Join(entries, ","))
+	}}})
+	store := NewMemoryStore()
+	syncer := NewSyncer(api, nil, store)
+
+	count, err := syncer.SyncLedgers(context.Background())
+	records := store.Records()
+	if err == nil || count != 50 || len(records) != 50 || records[0].ID != "L000" || records[49].ID != "L049" {
+		t.Fatalf("SyncLedgers() should keep the oldest page on error, got %d records (%v)", len(records), err)
+	}
+
+	failAt = "none"
+	count, err = syncer.SyncLedgers(context.Background())
+	records = store.Records()
+	if err != nil || count != 70 || len(records) != 120 {
+		t.Fatalf("SyncLedgers() should resume after the stored records, got %d (%v)", count, err)
+	}
+	for i, record := range records {
+		if record.ID != fmt.Sprintf("L%03d", i) {
+			t.Fatalf("SyncLedgers() should store the entries oldest first without gaps, got %s at %d", record.ID, i)
+		}
+	}
+}
+
+func TestFileStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "krakenapi")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "ledgers.jsonl")
+	store := NewFileStore(path)
+
+	if last, err := store.Last(); err != nil || last != nil {
+		t.Errorf("Last() of a new store should be empty, got %v (%v)", last, err)
+	}
+
+	records := []SyncRecord{
+		{ID: "L1", Time: 10, Data: []byte(`{"type":"deposit"}`)},
+		{ID: "L2", Time: 11, Data: []byte(`{"type":"trade"}`)},
+		{ID: "L3", Time: 11, Data: []byte(`{"type":"trade"}`)},
+	}
+	if err := store.Append(records); err != nil {
+		t.Fatalf("Append() should not return an error, got %s", err)
+	}
+
+	// Simulate an interrupted write
+	file, _ := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0600)
+	file.WriteString(`{"id":"L4","ti`)
+	file.Close()
+
+	last, err := store.Last()
+	if err != nil || len(last) != 2 || last[0].ID != "L2" || last[1].ID != "L3" {
+		t.Errorf("Last() should return the newest records, got %+v (%v)", last, err)
+	}
+
+	if err := store.Append([]SyncRecord{{ID: "L4", Time: 12, Data: []byte(`{"type":"withdrawal"}`)}}); err != nil {
+		t.Fatalf("Append() should not return an error, got %s", err)
+	}
+	all, err := store.Records()
+	if err != nil || len(all) != 4 || all[3].ID != "L4" {
+		t.Errorf("Append() should replace the incomplete line, got %+v (%v)", all, err)
+	}
+	if entry, err := all[3].Ledger(); err != nil || entry.Type != LedgerTypeWithdrawal {
+		t.Errorf("Stored ledger entries should decode, got %+v (%v)", entry, err)
+	}
+
+	// The cached size and newest records match what a new store reads from the file
+	if last, err := store.Last(); err != nil || len(last) != 1 || last[0].ID != "L4" {
+		t.Errorf("Last() should return the appended records, got %+v (%v)", last, err)
+	}
+	reopened := NewFileStore(path)
+	if err := reopened.Append([]SyncRecord{{ID: "L5", Time: 12, Data: []byte(`{"type":"trade"}`)}}); err != nil {
+		t.Fatalf("Append() should not return an error, got %s", err)
+	}
+	if last, err := reopened.Last(); err != nil || len(last) != 2 || last[0].ID != "L4" || last[1].ID != "L5" {
+		t.Errorf("Last() of a reopened store should return the newest records, got %+v (%v)", last, err)
+	}
+	if all, err := reopened.Records(); err != nil || len(all) != 5 {
+		t.Errorf("Append() should keep the complete lines, got %+v (%v)", all, err)
+	}
+}
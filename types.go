@@ -1,11 +1,10 @@
 package krakenapi
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
-	"math/big"
 	"reflect"
-	"strconv"
 	"time"
 )
 
@@ -31,8 +30,8 @@ const (
 	GNOEUR   = "GNOEUR"
 	GNOUSD   = "GNOUSD"
 	GNOXBT   = "GNOXBT"
-	LINKUSD   = "LINKUSD"
-	LINKXBT   = "LINKXBT"
+	LINKUSD  = "LINKUSD"
+	LINKXBT  = "LINKXBT"
 	QTUMCAD  = "QTUMCAD"
 	QTUMETH  = "QTUMETH"
 	QTUMEUR  = "QTUMEUR"
@@ -112,6 +111,8 @@ type TimeResponse struct {
 }
 
 // AssetPairsResponse includes asset pair informations
+//
+// Deprecated: AssetPairsResponse only knows a fixed set of pairs, use AssetPairMap instead.
 type AssetPairsResponse struct {
 	ADACAD   AssetPairInfo
 	AAVEUSD  AssetPairInfo
@@ -190,10 +191,30 @@ type AssetPairsResponse struct {
 	XZECZUSD AssetPairInfo
 }
 
+// Info levels for AssetPairsWithInfo
+const (
+	InfoAll      = "info"
+	InfoLeverage = "leverage"
+	InfoFees     = "fees"
+	InfoMargin   = "margin"
+)
+
+// AssetPairMap includes asset pair informations, keyed by the pair names returned by Kraken
+type AssetPairMap map[string]AssetPairInfo
+
+// Legacy returns the pairs as an AssetPairsResponse, dropping unknown pairs
+func (m AssetPairMap) Legacy() *AssetPairsResponse {
+	resp := &AssetPairsResponse{}
+	fillLegacyView(resp, m)
+	return resp
+}
+
 // AssetPairInfo represents asset pair information
 type AssetPairInfo struct {
 	// Alternate pair name
 	Altname string `json:"altname"`
+	// WebSocket pair name
+	WSName string `json:"wsname"`
 	// Asset class of base component
 	AssetClassBase string `json:"aclass_base"`
 	// Asset id of base component
@@ -208,6 +229,8 @@ type AssetPairInfo struct {
 	PairDecimals int `json:"pair_decimals"`
 	// Scaling decimal places for volume
 	LotDecimals int `json:"lot_decimals"`
+	// Scaling decimal places for cost
+	CostDecimals int `json:"cost_decimals"`
 	// Amount to multiply lot volume by to get currency volume
 	LotMultiplier int `json:"lot_multiplier"`
 	// Array of leverage amounts available when buying
@@ -215,20 +238,28 @@ type AssetPairInfo struct {
 	// Array of leverage amounts available when selling
 	LeverageSell []float64 `json:"leverage_sell"`
 	// Fee schedule array in [volume, percent fee] tuples
-	Fees [][]float64 `json:"fees"`
+	Fees [][]Decimal `json:"fees"`
 	// // Maker fee schedule array in [volume, percent fee] tuples (if on maker/taker)
-	FeesMaker [][]float64 `json:"fees_maker"`
+	FeesMaker [][]Decimal `json:"fees_maker"`
 	// // Volume discount currency
 	FeeVolumeCurrency string `json:"fee_volume_currency"`
 	// Margin call level
 	MarginCall int `json:"margin_call"`
 	// Stop-out/Liquidation margin level
 	MarginStop int `json:"margin_stop"`
+	// Maximum allowed margin level
+	MarginLevel int `json:"margin_level"`
 	// Order minimum
-	OrderMin float64 `json:"ordermin"`
+	OrderMin Decimal `json:"ordermin"`
+	// Minimum order cost in quote currency
+	CostMin Decimal `json:"costmin"`
+	// Minimum price increment
+	TickSize Decimal `json:"tick_size"`
 }
 
 // AssetsResponse includes asset informations
+//
+// Deprecated: AssetsResponse only knows a fixed set of assets, use AssetMap instead.
 type AssetsResponse struct {
 	ADA  AssetInfo
 	AAVE AssetInfo
@@ -265,6 +296,26 @@ type AssetsResponse struct {
 	ZUSD AssetInfo
 }
 
+// RoundPrice rounds the price to the pair's price precision
+func (p AssetPairInfo) RoundPrice(price Decimal) Decimal {
+	return price.Round(int32(p.PairDecimals))
+}
+
+// RoundVolume rounds the volume to the pair's volume precision
+func (p AssetPairInfo) RoundVolume(volume Decimal) Decimal {
+	return volume.Round(int32(p.LotDecimals))
+}
+
+// AssetMap includes asset informations, keyed by the asset names returned by Kraken
+type AssetMap map[string]AssetInfo
+
+// Legacy returns the assets as an AssetsResponse, dropping unknown assets
+func (m AssetMap) Legacy() *AssetsResponse {
+	resp := &AssetsResponse{}
+	fillLegacyView(resp, m)
+	return resp
+}
+
 // AssetInfo represents an asset information
 type AssetInfo struct {
 	// Alternate name
@@ -277,58 +328,82 @@ type AssetInfo struct {
 	DisplayDecimals int `json:"display_decimals"`
 }
 
+// BalanceMap represents the account's balances, keyed by the asset names returned by Kraken
+type BalanceMap map[string]Decimal
+
+// Legacy returns the balances as a BalanceResponse, dropping unknown assets
+func (m BalanceMap) Legacy() *BalanceResponse {
+	resp := &BalanceResponse{}
+	fillLegacyView(resp, m)
+	return resp
+}
+
 // BalanceResponse represents the account's balances (list of currencies)
+//
+// Deprecated: BalanceResponse only knows a fixed set of assets, use BalanceMap instead.
 type BalanceResponse struct {
-	ADA  float64 `json:"ADA,string"`
-	AAVE float64 `json:"AAVE,string"`
-	BCH  float64 `json:"BCH,string"`
-	DASH float64 `json:"DASH,string"`
-	EOS  float64 `json:"EOS,string"`
-	GNO  float64 `json:"GNO,string"`
-	QTUM float64 `json:"QTUM,string"`
-	KFEE float64 `json:"KFEE,string"`
-	LINK float64 `json:"LINK,string"`
-	USDC float64 `json:"USDC,string"`
-	USDT float64 `json:"USDT,string"`
-	XDAO float64 `json:"XDAO,string"`
-	XETC float64 `json:"XETC,string"`
-	XETH float64 `json:"XETH,string"`
-	XICN float64 `json:"XICN,string"`
-	XLTC float64 `json:"XLTC,string"`
-	XMLN float64 `json:"XMLN,string"`
-	XNMC float64 `json:"XNMC,string"`
-	XREP float64 `json:"XREP,string"`
-	XXBT float64 `json:"XXBT,string"`
-	XXDG float64 `json:"XXDG,string"`
-	XXLM float64 `json:"XXLM,string"`
-	XXMR float64 `json:"XXMR,string"`
-	XXRP float64 `json:"XXRP,string"`
-	XTZ  float64 `json:"XTZ,string"`
-	XXVN float64 `json:"XXVN,string"`
-	XZEC float64 `json:"XZEC,string"`
-	ZCAD float64 `json:"ZCAD,string"`
-	ZEUR float64 `json:"ZEUR,string"`
-	ZGBP float64 `json:"ZGBP,string"`
-	ZJPY float64 `json:"ZJPY,string"`
-	ZKRW float64 `json:"ZKRW,string"`
-	ZUSD float64 `json:"ZUSD,string"`
-	TRX  float64 `json:"TRX,string"`
+	ADA  Decimal `json:"ADA"`
+	AAVE Decimal `json:"AAVE"`
+	BCH  Decimal `json:"BCH"`
+	DASH Decimal `json:"DASH"`
+	EOS  Decimal `json:"EOS"`
+	GNO  Decimal `json:"GNO"`
+	QTUM Decimal `json:"QTUM"`
+	KFEE Decimal `json:"KFEE"`
+	LINK Decimal `json:"LINK"`
+	USDC Decimal `json:"USDC"`
+	USDT Decimal `json:"USDT"`
+	XDAO Decimal `json:"XDAO"`
+	XETC Decimal `json:"XETC"`
+	XETH Decimal `json:"XETH"`
+	XICN Decimal `json:"XICN"`
+	XLTC Decimal `json:"XLTC"`
+	XMLN Decimal `json:"XMLN"`
+	XNMC Decimal `json:"XNMC"`
+	XREP Decimal `json:"XREP"`
+	XXBT Decimal `json:"XXBT"`
+	XXDG Decimal `json:"XXDG"`
+	XXLM Decimal `json:"XXLM"`
+	XXMR Decimal `json:"XXMR"`
+	XXRP Decimal `json:"XXRP"`
+	XTZ  Decimal `json:"XTZ"`
+	XXVN Decimal `json:"XXVN"`
+	XZEC Decimal `json:"XZEC"`
+	ZCAD Decimal `json:"ZCAD"`
+	ZEUR Decimal `json:"ZEUR"`
+	ZGBP Decimal `json:"ZGBP"`
+	ZJPY Decimal `json:"ZJPY"`
+	ZKRW Decimal `json:"ZKRW"`
+	ZUSD Decimal `json:"ZUSD"`
+	TRX  Decimal `json:"TRX"`
 }
 
 // TradeBalanceResponse struct used as the response for the TradeBalance method
 type TradeBalanceResponse struct {
-	EquivalentBalance         float64 `json:"eb,string"`
-	TradeBalance              float64 `json:"tb,string"`
-	MarginOP                  float64 `json:"m,string"`
-	UnrealizedNetProfitLossOP float64 `json:"n,string"`
-	CostBasisOP               float64 `json:"c,string"`
-	CurrentValuationOP        float64 `json:"v,string"`
-	Equity                    float64 `json:"e,string"`
-	FreeMargin                float64 `json:"mf,string"`
-	MarginLevel               float64 `json:"ml,string"`
+	EquivalentBalance         Decimal `json:"eb"`
+	TradeBalance              Decimal `json:"tb"`
+	MarginOP                  Decimal `json:"m"`
+	UnrealizedNetProfitLossOP Decimal `json:"n"`
+	CostBasisOP               Decimal `json:"c"`
+	CurrentValuationOP        Decimal `json:"v"`
+	Equity                    Decimal `json:"e"`
+	FreeMargin                Decimal `json:"mf"`
+	MarginLevel               Decimal `json:"ml"`
+}
+
+// FeeMap includes fees information, keyed by the pair names returned by Kraken
+type FeeMap map[string]FeeInfo
+
+// Legacy returns the fees as a Fees struct, dropping unknown pairs
+func (m FeeMap) Legacy() *Fees {
+	resp := &Fees{}
+	fillLegacyView(resp, m)
+	return resp
 }
 
 // Fees includes fees information for different currencies
+//
+// Deprecated: Fees only knows a fixed set of pairs, use FeeMap instead.
 type Fees struct {
 	ADACAD   FeeInfo
 	ADAETH   FeeInfo
@@ -409,23 +484,52 @@ type Fees struct {
 
 // FeeInfo represents a fee information
 type FeeInfo struct {
-	Fee        float64 `json:"fee,string"`
-	MinFee     float64 `json:"minfee,string"`
-	MaxFee     float64 `json:"maxfee,string"`
-	NextFee    float64 `json:"nextfee,string"`
-	NextVolume float64 `json:"nextvolume,string"`
-	TierVolume float64 `json:"tiervolume,string"`
+	Fee        Decimal `json:"fee"`
+	MinFee     Decimal `json:"minfee"`
+	MaxFee     Decimal `json:"maxfee"`
+	NextFee    Decimal `json:"nextfee"`
+	NextVolume Decimal `json:"nextvolume"`
+	TierVolume Decimal `json:"tiervolume"`
 }
 
 // TradeVolumeResponse represents the response for trade volume
 type TradeVolumeResponse struct {
-	Volume    float64 `json:"volume,string"`
-	Currency  string  `json:"currency"`
-	Fees      Fees    `json:"fees"`
-	FeesMaker Fees    `json:"fees_maker"`
+	Volume   Decimal `json:"volume"`
+	Currency string  `json:"currency"`
+	// Deprecated: Fees only knows a fixed set of pairs, use FeeMap instead.
+	Fees Fees `json:"-"`
+	// Deprecated: FeesMaker only knows a fixed set of pairs, use FeesMakerMap instead.
+	FeesMaker Fees `json:"-"`
+	// Taker fees keyed by the pair names returned by Kraken
+	FeeMap FeeMap `json:"fees"`
+	// Maker fees keyed by the pair names returned by Kraken
+	FeesMakerMap FeeMap `json:"fees_maker"`
+}
+
+// UnmarshalJSON decodes the fees into the maps and fills the deprecated Fees fields from them
+func (r *TradeVolumeResponse) UnmarshalJSON(data []byte) error {
+	type tradeVolume TradeVolumeResponse
+	if err := json.Unmarshal(data, (*tradeVolume)(r)); err != nil {
+		return err
+	}
+	r.Fees = *r.FeeMap.Legacy()
+	r.FeesMaker = *r.FeesMakerMap.Legacy()
+	return nil
+}
+
+// TickerMap includes the requested ticker pairs, keyed by the pair names returned by Kraken
+type TickerMap map[string]PairTickerInfo
+
+// Legacy returns the tickers as a TickerResponse, dropping unknown pairs
+func (m TickerMap) Legacy() *TickerResponse {
+	resp := &TickerResponse{}
+	fillLegacyView(resp, m)
+	return resp
 }
 
 // TickerResponse includes the requested ticker pairs
+//
+// Deprecated: TickerResponse only knows a fixed set of pairs, use TickerMap instead.
 type TickerResponse struct {
 	ADACAD   PairTickerInfo
 	ADAETH   PairTickerInfo
@@ -519,40 +623,68 @@ type WithdrawResponse struct {
 
 // WithdrawInfoResponse is the response type showing withdrawal information for a selected withdrawal method.
 type WithdrawInfoResponse struct {
-	Method string    `json:"method"`
-	Limit  big.Float `json:"limit"`
-	Amount big.Float `json:"amount"`
-	Fee    big.Float `json:"fee"`
+	Method string  `json:"method"`
+	Limit  Decimal `json:"limit"`
+	Amount Decimal `json:"amount"`
+	Fee    Decimal `json:"fee"`
 }
 
-// GetPairTickerInfo is a helper method that returns given `pair`'s `PairTickerInfo`
+// WebSocketsTokenResponse is the response type of a GetWebSocketsToken query to the Kraken API.
+// The token must be used within Expires seconds and stays valid while a connection uses it.
+type WebSocketsTokenResponse struct {
+	Token   string `json:"token"`
+	Expires int    `json:"expires"`
+}
+
+// AccountTransferResponse is the response type of an AccountTransfer query to the Kraken API.
+type AccountTransferResponse struct {
+	TransferID string         `json:"transfer_id"`
+	Status     TransferStatus `json:"status"`
+}
+
+// IsPending reports whether the transfer has not been settled yet
+func (r *AccountTransferResponse) IsPending() bool {
+	return r.Status == TransferStatusPending
+}
+
+// IsComplete reports whether the funds have arrived at the destination account
+func (r *AccountTransferResponse) IsComplete() bool {
+	return r.Status == TransferStatusComplete
+}
+
+// GetPairTickerInfo is a helper method that returns given `pair`'s `PairTickerInfo`.
+// It returns an empty PairTickerInfo for pairs unknown to TickerResponse.
 func (v *TickerResponse) GetPairTickerInfo(pair string) PairTickerInfo {
 	r := reflect.ValueOf(v)
 	f := reflect.Indirect(r).FieldByName(pair)
+	if !f.IsValid() {
+		return PairTickerInfo{}
+	}
 
-	return f.Interface().(PairTickerInfo)
+	info, _ := f.Interface().(PairTickerInfo)
+	return info
 }
 
 // PairTickerInfo represents ticker information for a pair
 type PairTickerInfo struct {
 	// Ask array(<price>, <whole lot volume>, <lot volume>)
-	Ask []string `json:"a"`
+	Ask []Decimal `json:"a"`
 	// Bid array(<price>, <whole lot volume>, <lot volume>)
-	Bid []string `json:"b"`
+	Bid []Decimal `json:"b"`
 	// Last trade closed array(<price>, <lot volume>)
-	Close []string `json:"c"`
+	Close []Decimal `json:"c"`
 	// Volume array(<today>, <last 24 hours>)
-	Volume []string `json:"v"`
+	Volume []Decimal `json:"v"`
 	// Volume weighted average price array(<today>, <last 24 hours>)
-	VolumeAveragePrice []string `json:"p"`
+	VolumeAveragePrice []Decimal `json:"p"`
 	// Number of trades array(<today>, <last 24 hours>)
 	Trades []int `json:"t"`
 	// Low array(<today>, <last 24 hours>)
-	Low []string `json:"l"`
+	Low []Decimal `json:"l"`
 	// High array(<today>, <last 24 hours>)
-	High []string `json:"h"`
+	High []Decimal `json:"h"`
 	// Today's opening price
-	OpeningPrice float64 `json:"o,string"`
+	OpeningPrice Decimal `json:"o"`
 }
 
 // TradesResponse represents a list of the last trades
@@ -569,27 +701,45 @@ type TradesHistoryResponse struct {
 
 // TradeHistoryInfo represents a transaction
 type TradeHistoryInfo struct {
-	TransactionID string  `json:"ordertxid"`
-	PostxID       string  `json:"postxid"`
-	AssetPair     string  `json:"pair"`
-	Time          float64 `json:"time"`
-	Type          string  `json:"type"`
-	OrderType     string  `json:"ordertype"`
-	Price         float64 `json:"price,string"`
-	Cost          float64 `json:"cost,string"`
-	Fee           float64 `json:"fee,string"`
-	Volume        float64 `json:"vol,string"`
-	Margin        float64 `json:"margin,string"`
-	Misc          string  `json:"misc"`
+	TradeID       string    `json:"-"`
+	TransactionID string    `json:"ordertxid"`
+	PostxID       string    `json:"postxid"`
+	AssetPair     string    `json:"pair"`
+	Time          float64   `json:"time"`
+	Type          Side      `json:"type"`
+	OrderType     OrderType `json:"ordertype"`
+	Price         Decimal   `json:"price"`
+	Cost          Decimal   `json:"cost"`
+	Fee           Decimal   `json:"fee"`
+	Volume        Decimal   `json:"vol"`
+	Margin        Decimal   `json:"margin"`
+	Misc          string    `json:"misc"`
+}
+
+// ExecutedAt returns the time of the trade
+func (t TradeHistoryInfo) ExecutedAt() time.Time {
+	return floatTime(t.Time)
+}
+
+// MiscFlags returns the trade's misc flags
+func (t TradeHistoryInfo) MiscFlags() []MiscFlag {
+	return ParseMiscFlags(t.Misc)
+}
+
+// IsClosing reports whether the trade closes all or part of a position
+func (t TradeHistoryInfo) IsClosing() bool {
+	return hasMiscFlag(t.Misc, MiscClosing)
 }
 
 // TradeInfo represents a trades information
 type TradeInfo struct {
-	Price         string
-	PriceFloat    float64
-	Volume        string
-	VolumeFloat   float64
-	Time          int64
+	Price  Decimal
+	Volume Decimal
+	Time   int64
+	// Execution time including fractional seconds
+	Timestamp time.Time
+	// Trade ID, 0 if Kraken did not send one
+	TradeID       int64
 	Buy           bool
 	Sell          bool
 	Market        bool
@@ -600,18 +750,25 @@ type TradeInfo struct {
 // LedgersResponse represents an associative array of ledgers infos
 type LedgersResponse struct {
 	Ledger map[string]LedgerInfo `json:"ledger"`
+	Count  int                   `json:"count"`
 }
 
 // LedgerInfo Represents the ledger informations
 type LedgerInfo struct {
-	RefID   string    `json:"refid"`
-	Time    float64   `json:"time"`
-	Type    string    `json:"type"`
-	Aclass  string    `json:"aclass"`
-	Asset   string    `json:"asset"`
-	Amount  big.Float `json:"amount"`
-	Fee     big.Float `json:"fee"`
-	Balance big.Float `json:"balance"`
+	LedgerID string     `json:"-"`
+	RefID    string     `json:"refid"`
+	Time     float64    `json:"time"`
+	Type     LedgerType `json:"type"`
+	Aclass   string     `json:"aclass"`
+	Asset    string     `json:"asset"`
+	Amount   Decimal    `json:"amount"`
+	Fee      Decimal    `json:"fee"`
+	Balance  Decimal    `json:"balance"`
+}
+
+// RecordedAt returns the time of the ledger entry
+func (l LedgerInfo) RecordedAt() time.Time {
+	return floatTime(l.Time)
 }
 
 // OrderTypes for AddOrder
@@ -632,14 +789,16 @@ const (
 
 // OrderDescription represents an orders description
 type OrderDescription struct {
-	AssetPair      string `json:"pair"`
-	Close          string `json:"close"`
-	Leverage       string `json:"leverage"`
-	Order          string `json:"order"`
-	OrderType      string `json:"ordertype"`
+	AssetPair string    `json:"pair"`
+	Close     string    `json:"close"`
+	Leverage  string    `json:"leverage"`
+	Order     string    `json:"order"`
+	OrderType OrderType `json:"ordertype"`
+	// Prices as text, relative like "+5.0%" for trailing stops, see Order for the
+	// exact prices
 	PrimaryPrice   string `json:"price"`
 	SecondaryPrice string `json:"price2"`
-	Type           string `json:"type"`
+	Type           Side   `json:"type"`
 }
 
 // Order represents a single order
@@ -647,24 +806,49 @@ type Order struct {
 	TransactionID  string           `json:"-"`
 	ReferenceID    string           `json:"refid"`
 	UserRef        int              `json:"userref"`
-	Status         string           `json:"status"`
+	Status         OrderStatus      `json:"status"`
 	OpenTime       float64          `json:"opentm"`
 	StartTime      float64          `json:"starttm"`
 	ExpireTime     float64          `json:"expiretm"`
 	Description    OrderDescription `json:"descr"`
-	Volume         string           `json:"vol"`
-	VolumeExecuted float64          `json:"vol_exec,string"`
-	Cost           float64          `json:"cost,string"`
-	Fee            float64          `json:"fee,string"`
-	Price          float64          `json:"price,string"`
-	StopPrice      float64          `json:"stopprice.string"`
-	LimitPrice     float64          `json:"limitprice,string"`
+	Volume         Decimal          `json:"vol"`
+	VolumeExecuted Decimal          `json:"vol_exec"`
+	Cost           Decimal          `json:"cost"`
+	Fee            Decimal          `json:"fee"`
+	Price          Decimal          `json:"price"`
+	StopPrice      Decimal          `json:"stopprice"`
+	LimitPrice     Decimal          `json:"limitprice"`
 	Misc           string           `json:"misc"`
 	OrderFlags     string           `json:"oflags"`
 	CloseTime      float64          `json:"closetm"`
 	Reason         string           `json:"reason"`
 }
 
+// OpenedAt returns the time the order was placed
+func (o Order) OpenedAt() time.Time {
+	return floatTime(o.OpenTime)
+}
+
+// StartsAt returns the scheduled start time, zero if the order starts immediately
+func (o Order) StartsAt() time.Time {
+	return floatTime(o.StartTime)
+}
+
+// ExpiresAt returns the expiration time, zero if the order does not expire
+func (o Order) ExpiresAt() time.Time {
+	return floatTime(o.ExpireTime)
+}
+
+// ClosedAt returns the time the order was closed, zero for open orders
+func (o Order) ClosedAt() time.Time {
+	return floatTime(o.CloseTime)
+}
+
+// MiscFlags returns the order's misc flags
+func (o Order) MiscFlags() []MiscFlag {
+	return ParseMiscFlags(o.Misc)
+}
+
 // ClosedOrdersResponse represents a list of closed orders, indexed by id
 type ClosedOrdersResponse struct {
 	Closed map[string]Order `json:"closed"`
@@ -673,16 +857,17 @@ type ClosedOrdersResponse struct {
 
 // OrderBookItem is a piece of information about an order.
 type OrderBookItem struct {
-	Price  float64
-	Amount float64
+	Price  Decimal
+	Amount Decimal
 	Ts     int64
 }
 
 // UnmarshalJSON takes a json array from kraken and converts it into an OrderBookItem.
+// Kraken Futures sends [price, amount] with plain numbers.
 func (o *OrderBookItem) UnmarshalJSON(data []byte) error {
 	tmpStruct := struct {
-		price  string
-		amount string
+		price  Decimal
+		amount Decimal
 		ts     int64
 	}{}
 	tmpArray := []interface{}{&tmpStruct.price, &tmpStruct.amount, &tmpStruct.ts}
@@ -691,14 +876,8 @@ func (o *OrderBookItem) UnmarshalJSON(data []byte) error {
 		return err
 	}
 
-	o.Price, err = strconv.ParseFloat(tmpStruct.price, 64)
-	if err != nil {
-		return err
-	}
-	o.Amount, err = strconv.ParseFloat(tmpStruct.amount, 64)
-	if err != nil {
-		return err
-	}
+	o.Price = tmpStruct.price
+	o.Amount = tmpStruct.amount
 	o.Ts = tmpStruct.ts
 	return nil
 }
@@ -733,34 +912,162 @@ type CancelOrderResponse struct {
 // QueryOrdersResponse response when checking all orders
 type QueryOrdersResponse map[string]Order
 
-// NewOHLC constructor for OHLC
+// NewOHLC constructor for OHLC from a decoded OHLC entry
 func NewOHLC(input []interface{}) (*OHLC, error) {
-	if len(input) != 8 {
-		return nil, fmt.Errorf("the length is not 8 but %d", len(input))
+	data, err := json.Marshal(input)
+	if err != nil {
+		return nil, err
+	}
+	ohlc := new(OHLC)
+	if err := json.Unmarshal(data, ohlc); err != nil {
+		return nil, err
+	}
+	return ohlc, nil
+}
+
+// UnmarshalJSON decodes an OHLC entry
+// array(<time>, <open>, <high>, <low>, <close>, <vwap>, <volume>, <count>).
+// An object as written by json.Marshal is decoded field by field.
+func (o *OHLC) UnmarshalJSON(data []byte) error {
+	if isJSONObject(data) {
+		type ohlc OHLC
+		return json.Unmarshal(data, (*ohlc)(o))
+	}
+
+	var fields []json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+	if len(fields) != 8 {
+		return fmt.Errorf("the length is not 8 but %d", len(fields))
+	}
+	var ts float64
+	if err := json.Unmarshal(fields[0], &ts); err != nil {
+		return fmt.Errorf("time should be a number, got %s", fields[0])
+	}
+	return o.decodeFields(time.Unix(int64(ts), 0), fields[1:])
+}
+
+// decodeFields decodes array(<open>, <high>, <low>, <close>, <vwap>, <volume>, <count>)
+func (o *OHLC) decodeFields(start time.Time, fields []json.RawMessage) error {
+	o.Time = start
+	targets := []*Decimal{&o.Open, &o.High, &o.Low, &o.Close, &o.Vwap, &o.Volume}
+	names := []string{"open", "high", "low", "close", "vwap", "volume"}
+	var err error
+	for i, target := range targets {
+		if *target, err = decimalString(fields[i], names[i]); err != nil {
+			return err
+		}
+	}
+	if err := json.Unmarshal(fields[6], &o.Count); err != nil {
+		return fmt.Errorf("count should be a number, got %s", fields[6])
+	}
+	return nil
+}
+
+// NewTradeInfo constructor for TradeInfo from a decoded Trades entry
+func NewTradeInfo(input []interface{}) (*TradeInfo, error) {
+	data, err := json.Marshal(input)
+	if err != nil {
+		return nil, err
+	}
+	trade := new(TradeInfo)
+	if err := json.Unmarshal(data, trade); err != nil {
+		return nil, err
+	}
+	return trade, nil
+}
+
+// UnmarshalJSON decodes a Trades entry
+// array(<price>, <volume>, <time>, <buy/sell>, <market/limit>, <miscellaneous>, <trade id>).
+// The trade ID is missing in older responses. An object as written by json.Marshal is
+// decoded field by field.
+func (t *TradeInfo) UnmarshalJSON(data []byte) error {
+	if isJSONObject(data) {
+		type tradeInfo TradeInfo
+		return json.Unmarshal(data, (*tradeInfo)(t))
 	}
 
-	tmp := new(OHLC)
-	tmp.Time = time.Unix(int64(input[0].(float64)), 0)
-	tmp.Open, _ = strconv.ParseFloat(input[1].(string), 64)
-	tmp.High, _ = strconv.ParseFloat(input[2].(string), 64)
-	tmp.Low, _ = strconv.ParseFloat(input[3].(string), 64)
-	tmp.Close, _ = strconv.ParseFloat(input[4].(string), 64)
-	tmp.Vwap, _ = strconv.ParseFloat(input[5].(string), 64)
-	tmp.Volume, _ = strconv.ParseFloat(input[6].(string), 64)
-	tmp.Count = int(input[7].(float64))
+	var fields []json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+	if len(fields) < 6 {
+		return fmt.Errorf("the length is not at least 6 but %d", len(fields))
+	}
+	var ts float64
+	if err := json.Unmarshal(fields[2], &ts); err != nil {
+		return fmt.Errorf("time should be a number, got %s", fields[2])
+	}
+	return t.decodeFields(ts, fields)
+}
 
-	return tmp, nil
+// decodeFields decodes the fields of a trade except its time, which is given as ts
+func (t *TradeInfo) decodeFields(ts float64, fields []json.RawMessage) error {
+	price, err := decimalString(fields[0], "price")
+	if err != nil {
+		return err
+	}
+	volume, err := decimalString(fields[1], "volume")
+	if err != nil {
+		return err
+	}
+	texts := make([]string, 3)
+	for i, name := range []string{"side", "order type", "miscellaneous"} {
+		if err := json.Unmarshal(fields[i+3], &texts[i]); err != nil {
+			return fmt.Errorf("%s should be a string, got %s", name, fields[i+3])
+		}
+	}
+	var tradeID int64
+	if len(fields) > 6 {
+		if err := json.Unmarshal(fields[6], &tradeID); err != nil {
+			return fmt.Errorf("trade id should be a number, got %s", fields[6])
+		}
+	}
+
+	*t = TradeInfo{
+		Price:         price,
+		Volume:        volume,
+		Time:          int64(ts),
+		Timestamp:     floatTime(ts),
+		TradeID:       tradeID,
+		Buy:           texts[0] == BUY,
+		Sell:          texts[0] == SELL,
+		Market:        texts[1] == MARKET,
+		Limit:         texts[1] == LIMIT,
+		Miscellaneous: texts[2],
+	}
+	return nil
+}
+
+// isJSONObject reports whether data holds a JSON object
+func isJSONObject(data []byte) bool {
+	data = bytes.TrimSpace(data)
+	return len(data) > 0 && data[0] == '{'
+}
+
+// decimalString decodes a string encoded decimal, plain numbers are rejected
+func decimalString(data json.RawMessage, name string) (Decimal, error) {
+	var value string
+	if err := json.Unmarshal(data, &value); err != nil {
+		return Decimal{}, fmt.Errorf("%s should be a string, got %s", name, data)
+	}
+	d, err := NewDecimalFromString(value)
+	if err != nil {
+		return Decimal{}, fmt.Errorf("%s: %s", name, err)
+	}
+	return d, nil
 }
 
 // OHLC represents the "Open-high-low-close chart"
 type OHLC struct {
 	Time   time.Time `json:"time"`
-	Open   float64   `json:"open"`
-	High   float64   `json:"high"`
-	Low    float64   `json:"low"`
-	Close  float64   `json:"close"`
-	Vwap   float64   `json:"vwap"`
-	Volume float64   `json:"volume"`
+	Open   Decimal   `json:"open"`
+	High   Decimal   `json:"high"`
+	Low    Decimal   `json:"low"`
+	Close  Decimal   `json:"close"`
+	Vwap   Decimal   `json:"vwap"`
+	Volume Decimal   `json:"volume"`
 	Count  int       `json:"count"`
 }
 
@@ -770,3 +1077,17 @@ type OHLCResponse struct {
 	OHLC []*OHLC `json:"OHLC"`
 	Last float64 `json:"last"`
 }
+
+// fillLegacyView copies every entry of the map src into the field of the struct
+// pointed to by dst that carries the same name. Entries without a field are dropped.
+func fillLegacyView(dst interface{}, src interface{}) {
+	view := reflect.ValueOf(dst).Elem()
+	entries := reflect.ValueOf(src)
+	for _, key := range entries.MapKeys() {
+		field := view.FieldByName(key.String())
+		if !field.IsValid() || !field.CanSet() {
+			continue
+		}
+		field.Set(entries.MapIndex(key))
+	}
+}
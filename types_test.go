@@ -0,0 +1,153 @@
+package krakenapi
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestBalanceMapUnmarshal(t *testing.T) {
+	balances := BalanceMap{}
+	err := json.Unmarshal([]byte(`{"XXBT":"1.5","NEWCOIN":"42.0000000000"}`), &balances)
+	if err != nil {
+		t.Fatalf("BalanceMap should unmarshal, got %s", err)
+	}
+
+	if !balances["NEWCOIN"].Equal(NewDecimalFromInt(42)) {
+		t.Errorf("BalanceMap should keep unknown assets, got %+v", balances)
+	}
+
+	legacy := balances.Legacy()
+	if legacy.XXBT.String() != "1.5" {
+		t.Errorf("Legacy() should fill known assets, got %+v", legacy.XXBT)
+	}
+
+	if err := json.Unmarshal([]byte(`{"XXBT":"abc"}`), &balances); err == nil {
+		t.Errorf("BalanceMap should reject invalid amounts")
+	}
+}
+
+func TestTickerMapLegacy(t *testing.T) {
+	tickers := TickerMap{
+		XXBTZEUR:  {OpeningPrice: NewDecimalFromInt(100)},
+		"NEWZEUR": {OpeningPrice: NewDecimalFromInt(1)},
+	}
+
+	legacy := tickers.Legacy()
+	if legacy.XXBTZEUR.OpeningPrice.String() != "100" {
+		t.Errorf("Legacy() should fill known pairs, got %+v", legacy.XXBTZEUR)
+	}
+
+	if info := legacy.GetPairTickerInfo("NEWZEUR"); !info.OpeningPrice.IsZero() {
+		t.Errorf("GetPairTickerInfo() should return an empty info for unknown pairs, got %+v", info)
+	}
+}
+
+func TestTradeVolumeResponseFees(t *testing.T) {
+	var resp TradeVolumeResponse
+	err := json.Unmarshal([]byte(`{"currency":"ZUSD","volume":"10.5","fees":{"XXBTZEUR":{"fee":"0.2600"},"NEWZEUR":{"fee":"0.4"}},"fees_maker":{"XXBTZEUR":{"fee":"0.1600"}}}`), &resp)
+	if err != nil {
+		t.Fatalf("TradeVolumeResponse should unmarshal, got %s", err)
+	}
+	if resp.FeeMap["NEWZEUR"].Fee.String() != "0.4" || resp.FeesMakerMap[XXBTZEUR].Fee.String() != "0.16" {
+		t.Errorf("FeeMap and FeesMakerMap should keep all pairs, got %+v %+v", resp.FeeMap, resp.FeesMakerMap)
+	}
+	if resp.Fees.XXBTZEUR.Fee.String() != "0.26" || resp.FeesMaker.XXBTZEUR.Fee.String() != "0.16" {
+		t.Errorf("Fees and FeesMaker should be filled from the maps, got %+v %+v", resp.Fees.XXBTZEUR, resp.FeesMaker.XXBTZEUR)
+	}
+}
+
+func TestPairTickerInfoDecimals(t *testing.T) {
+	var ticker PairTickerInfo
+	err := json.Unmarshal([]byte(`{"a":["30243.40000","1","1.000"],"b":["30243.30000","2","2.000"],"c":["30240.1","0.005"],"v":["105.3","1804.2"],"p":["30211.8","30150.2"],"t":[1592,21349],"l":["30100.0","29900.0"],"h":["30500.0","30600.0"],"o":"30180.0"}`), &ticker)
+	if err != nil {
+		t.Fatalf("PairTickerInfo should unmarshal, got %s", err)
+	}
+	if ticker.Ask[0].String() != "30243.4" || ticker.Close[1].String() != "0.005" || ticker.High[1].String() != "30600" || ticker.OpeningPrice.String() != "30180" {
+		t.Errorf("PairTickerInfo decoded wrong values, got %+v", ticker)
+	}
+
+	if err := json.Unmarshal([]byte(`{"a":["ask","1","1.000"]}`), &ticker); err == nil {
+		t.Error("PairTickerInfo should reject prices which are not numbers")
+	}
+}
+
+func TestTradeInfoUnmarshal(t *testing.T) {
+	var trades []TradeInfo
+	err := json.Unmarshal([]byte(`[["30243.40000","0.34507674",1688669597.8277369,"b","m","",61044952],["30243.3","0.001",1688669597.9,"s","l",""]]`), &trades)
+	if err != nil {
+		t.Fatalf("TradeInfo should unmarshal, got %s", err)
+	}
+	if len(trades) != 2 || trades[0].Price.String() != "30243.4" || trades[0].TradeID != 61044952 || !trades[0].Buy || !trades[1].Limit || trades[1].TradeID != 0 {
+		t.Errorf("TradeInfo decoded wrong values, got %+v", trades)
+	}
+
+	data, _ := json.Marshal(trades[0])
+	var decoded TradeInfo
+	if err := json.Unmarshal(data, &decoded); err != nil || !decoded.Price.Equal(trades[0].Price) || !decoded.Timestamp.Equal(trades[0].Timestamp) {
+		t.Errorf("TradeInfo should decode its own JSON, got %+v (%v)", decoded, err)
+	}
+
+	if err := json.Unmarshal([]byte(`["30243.4","0.001",1688669597.9,"s","l",7]`), &decoded); err == nil {
+		t.Error("TradeInfo should reject a miscellaneous field which is not a string")
+	}
+}
+
+func TestOHLCUnmarshal(t *testing.T) {
+	var ohlc OHLC
+	if err := json.Unmarshal([]byte(`[1688671200,"30306.1","30306.2","30305.7","30305.7","30306.1","3.39243896",23]`), &ohlc); err != nil {
+		t.Fatalf("OHLC should unmarshal, got %s", err)
+	}
+	if ohlc.Time.Unix() != 1688671200 || ohlc.High.String() != "30306.2" || ohlc.Count != 23 {
+		t.Errorf("OHLC decoded wrong values, got %+v", ohlc)
+	}
+
+	data, _ := json.Marshal(ohlc)
+	var decoded OHLC
+	if err := json.Unmarshal(data, &decoded); err != nil || !decoded.Vwap.Equal(ohlc.Vwap) || decoded.Count != 23 {
+		t.Errorf("OHLC should decode its own JSON, got %+v (%v)", decoded, err)
+	}
+
+	if err := json.Unmarshal([]byte(`[1688671200,"30306.1","30306.2","30305.7","30305.7","30306.1","3.39243896",23,1]`), &decoded); err == nil {
+		t.Error("OHLC should reject entries with more than 8 fields")
+	}
+}
+
+func TestNewOHLC(t *testing.T) {
+	ohlc, err := NewOHLC([]interface{}{1688671200.0, "30306.1", "30306.2", "30305.7", "30305.7", "30306.1", "3.39243896", 23.0})
+	if err != nil || ohlc.Time.Unix() != 1688671200 || ohlc.Close.String() != "30305.7" || ohlc.Count != 23 {
+		t.Fatalf("NewOHLC() decoded wrong values, got %+v (%v)", ohlc, err)
+	}
+
+	malformed := [][]interface{}{
+		{1688671200.0, "30306.1", "30306.2", "30305.7", "30305.7", "30306.1", "3.39243896"},
+		{"1688671200", "30306.1", "30306.2", "30305.7", "30305.7", "30306.1", "3.39243896", 23.0},
+		{1688671200.0, 30306.1, "30306.2", "30305.7", "30305.7", "30306.1", "3.39243896", 23.0},
+		{1688671200.0, "30306.1", "high", "30305.7", "30305.7", "30306.1", "3.39243896", 23.0},
+		{1688671200.0, "30306.1", "30306.2", "30305.7", "30305.7", "30306.1", "3.39243896", "23"},
+	}
+	for _, row := range malformed {
+		if _, err := NewOHLC(row); err == nil {
+			t.Errorf("NewOHLC(%v) should fail", row)
+		}
+	}
+}
+
+func TestNewTradeInfo(t *testing.T) {
+	trade, err := NewTradeInfo([]interface{}{"30243.40000", "0.34507674", 1688669597.8277369, "b", "m", "", 61044952.0})
+	if err != nil || trade.Price.String() != "30243.4" || !trade.Buy || !trade.Market || trade.TradeID != 61044952 {
+		t.Fatalf("NewTradeInfo() decoded wrong values, got %+v (%v)", trade, err)
+	}
+
+	malformed := [][]interface{}{
+		{"30243.4", "0.001", 1688669597.9, "s", "l"},
+		{30243.4, "0.001", 1688669597.9, "s", "l", ""},
+		{"30243.4", "volume", 1688669597.9, "s", "l", ""},
+		{"30243.4", "0.001", "1688669597.9", "s", "l", ""},
+		{"30243.4", "0.001", 1688669597.9, "s", "l", "", "61044952"},
+	}
+	for _, row := range malformed {
+		if _, err := NewTradeInfo(row); err == nil {
+			t.Errorf("NewTradeInfo(%v) should fail", row)
+		}
+	}
+}
@@ -0,0 +1,779 @@
+package krakenapi
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// WSPublicURL is the endpoint of Kraken's public WebSocket feeds
+	WSPublicURL = "wss://ws.kraken.com"
+	// WSPrivateURL is the endpoint of Kraken's authenticated WebSocket feeds
+	WSPrivateURL = "wss://ws-auth.kraken.com"
+)
+
+// WebSocket channel names
+const (
+	ChannelTicker = "ticker"
+	ChannelOHLC   = "ohlc"
+	ChannelTrade  = "trade"
+	ChannelSpread = "spread"
+	ChannelBook   = "book"
+)
+
+// Subscription status values
+const (
+	SubscriptionSubscribed   = "subscribed"
+	SubscriptionUnsubscribed = "unsubscribed"
+	SubscriptionError        = "error"
+)
+
+// privateChannels are the channels requiring a token
+var privateChannels = []string{ChannelOwnTrades, ChannelOpenOrders, ChannelExecutions, ChannelBalances, ChannelLevel3,
+	ChannelFills, ChannelOpenPositions, ChannelFuturesOpenOrders}
+
+// wsEventBuffer is the number of events a WebSocket buffers before it stops reading
+const wsEventBuffer = 256
+
+// ErrWebSocketClosed is returned by calls on a closed WebSocket
+var ErrWebSocketClosed = errors.New("websocket closed")
+
+// Subscription selects a WebSocket channel
+type Subscription struct {
+	// Channel name, e.g. ChannelBook
+	Name string `json:"name"`
+	// Candle interval in minutes of the ohlc channel
+	Interval int `json:"interval,omitempty"`
+	// Number of price levels of the book channel
+	Depth int `json:"depth,omitempty"`
+	// Authentication token of the private channels
+	Token string `json:"token,omitempty"`
+}
+
+// WSChannel identifies the feed a WebSocket event belongs to
+type WSChannel struct {
+	// Channel name as sent by Kraken, e.g. "book-10" or "ohlc-5"
+	Name string `json:"-"`
+	// Pair as sent by Kraken, e.g. "XBT/USD"
+	Pair string `json:"-"`
+}
+
+// Channel returns the feed of the event
+func (c WSChannel) Channel() WSChannel {
+	return c
+}
+
+// WSEvent is a message received on a WebSocket
+type WSEvent interface {
+	// Channel returns the feed of the event, empty for connection events
+	Channel() WSChannel
+}
+
+// SystemStatusEvent is sent on connect and when the exchange status changes
+type SystemStatusEvent struct {
+	WSChannel
+	ConnectionID uint64 `json:"connectionID"`
+	Status       string `json:"status"`
+	Version      string `json:"version"`
+}
+
+// HeartbeatEvent is sent when a connection has no other traffic for a second
+type HeartbeatEvent struct {
+	WSChannel
+}
+
+// PongEvent answers Ping
+type PongEvent struct {
+	WSChannel
+	ReqID int64 `json:"reqid"`
+}
+
+// SubscriptionStatusEvent reports the result of a subscribe or unsubscribe request
+type SubscriptionStatusEvent struct {
+	WSChannel
+	ChannelID    int64        `json:"channelID"`
+	Status       string       `json:"status"`
+	Subscription Subscription `json:"subscription"`
+	ErrorMessage string       `json:"errorMessage"`
+	ReqID        int64        `json:"reqid"`
+}
+
+// ErrorEvent reports a message Kraken rejected or one that could not be decoded
+type ErrorEvent struct {
+	WSChannel
+	Err error
+}
+
+// TickerEvent is an update of the ticker channel
+type TickerEvent struct {
+	WSChannel
+	Ticker PairTickerInfo
+}
+
+// OHLCEvent is an update of the candle currently open in the ohlc channel.
+// OHLC.Time is the start of the candle, like in the REST OHLC.
+type OHLCEvent struct {
+	WSChannel
+	// Candle interval in minutes
+	Interval int
+	// End of the candle
+	EndTime time.Time
+	// Time of the last trade
+	UpdateTime time.Time
+	OHLC       OHLC
+}
+
+// TradeEvent carries the trades of the trade channel
+type TradeEvent struct {
+	WSChannel
+	Trades []TradeInfo
+}
+
+// SpreadEvent is an update of the best bid and ask of the spread channel
+type SpreadEvent struct {
+	WSChannel
+	Bid       Decimal
+	Ask       Decimal
+	Time      time.Time
+	BidVolume Decimal
+	AskVolume Decimal
+}
+
+// BookEvent is a snapshot or an update of the book channel. Levels with a zero
+// Amount are removed from the book.
+type BookEvent struct {
+	WSChannel
+	// Number of price levels of the subscription
+	Depth    int
+	Snapshot bool
+	Asks     []OrderBookItem
+	Bids     []OrderBookItem
+	// CRC32 checksum of the top 10 levels after the update, sent with updates only
+	Checksum uint32
+}
+
+// WebSocket is a connection to Kraken's WebSocket API. Events are delivered in the
+// order they are received on the channel returned by Events, which is closed when
+// the connection ends. It is safe for concurrent use.
+type WebSocket struct {
+	url      string
+	version  WSVersion
+	dialer   *websocket.Dialer
+	registry *Registry
+
+	conn    *websocket.Conn
+	writeMu sync.Mutex
+	events  chan WSEvent
+	quit    chan struct{}
+	done    chan struct{}
+
+	mu        sync.Mutex
+	closed    bool
+	err       error
+	lastReqID int64
+	token     string
+	// answers awaited by order requests, keyed by reqid
+	pending map[int64]chan []byte
+	// Kraken Futures challenge awaited by AuthenticateFutures and its signature
+	challenge   chan []byte
+	futuresAuth *futuresChallenge
+
+	// state of the read loop: last sequence number per sequenced feed,
+	// v2 book depths and v2 pair precisions by symbol
+	sequences   map[WSChannel]int64
+	bookDepths  map[string]int
+	instruments map[string]InstrumentPair
+}
+
+// NewWebSocket creates a WebSocket for the given endpoint, e.g. WSPublicURL. Endpoints
+// ending in "/v2" use the WebSocket API v2, endpoints ending in "/ws/v1" Kraken Futures.
+func NewWebSocket(url string) *WebSocket {
+	version := WSVersion1
+	if strings.HasSuffix(url, "/v2") {
+		version = WSVersion2
+	} else if strings.HasSuffix(url, "/ws/v1") {
+		version = WSVersionFutures
+	}
+	return &WebSocket{
+		url:     url,
+		version: version,
+		dialer:  websocket.DefaultDialer,
+		events:  make(chan WSEvent, wsEventBuffer),
+		quit:    make(chan struct{}),
+		done:    make(chan struct{}),
+
+		pending:     map[int64]chan []byte{},
+		sequences:   map[WSChannel]int64{},
+		bookDepths:  map[string]int{},
+		instruments: map[string]InstrumentPair{},
+	}
+}
+
+// WithDialer sets the dialer used by Connect
+func (ws *WebSocket) WithDialer(dialer *websocket.Dialer) *WebSocket {
+	ws.dialer = dialer
+	return ws
+}
+
+// WithRegistry makes the WebSocket resolve pair aliases like "XXBTZUSD" to the
+// names used on WebSockets like "XBT/USD" or "BTC/USD" in v2
+func (ws *WebSocket) WithRegistry(registry *Registry) *WebSocket {
+	ws.registry = registry
+	return ws
+}
+
+// Connect opens the connection and starts delivering events. A WebSocket can only be connected once.
+func (ws *WebSocket) Connect(ctx context.Context) error {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	if ws.conn != nil || ws.closed {
+		return fmt.Errorf("websocket %s is already connected", ws.url)
+	}
+
+	conn, _, err := ws.dialer.DialContext(ctx, ws.url, nil)
+	if err != nil {
+		return fmt.Errorf("Could not connect to %s: %s", ws.url, err)
+	}
+	if ws.version == WSVersionFutures {
+		// Kraken Futures only sends heartbeats on request, they show that the connection is alive
+		ws.writeMu.Lock()
+		err = conn.WriteJSON(map[string]string{"event": "subscribe", "feed": "heartbeat"})
+		ws.writeMu.Unlock()
+		if err != nil {
+			conn.Close()
+			return fmt.Errorf("Could not connect to %s: %s", ws.url, err)
+		}
+	}
+	ws.conn = conn
+	go ws.read()
+	return nil
+}
+
+// Events returns the channel of received events
+func (ws *WebSocket) Events() <-chan WSEvent {
+	return ws.events
+}
+
+// Done is closed when the connection has ended
+func (ws *WebSocket) Done() <-chan struct{} {
+	return ws.done
+}
+
+// Err returns the reason the connection ended, nil while it is open or after Close
+func (ws *WebSocket) Err() error {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	return ws.err
+}
+
+// Close ends the connection
+func (ws *WebSocket) Close() error {
+	ws.mu.Lock()
+	if ws.closed {
+		ws.mu.Unlock()
+		return nil
+	}
+	ws.closed = true
+	conn := ws.conn
+	close(ws.quit)
+	ws.mu.Unlock()
+
+	if conn == nil {
+		close(ws.events)
+		close(ws.done)
+		return nil
+	}
+
+	ws.writeMu.Lock()
+	conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""), time.Now().Add(time.Second))
+	ws.writeMu.Unlock()
+	err := conn.Close()
+	<-ws.done
+	return err
+}
+
+// Subscribe subscribes to a channel for the given pairs. The result is reported by
+// a SubscriptionStatusEvent per pair.
+func (ws *WebSocket) Subscribe(ctx context.Context, sub Subscription, pairs ...string) error {
+	return ws.subscription(ctx, "subscribe", sub, pairs)
+}
+
+// Unsubscribe ends a subscription for the given pairs
+func (ws *WebSocket) Unsubscribe(ctx context.Context, sub Subscription, pairs ...string) error {
+	return ws.subscription(ctx, "unsubscribe", sub, pairs)
+}
+
+func (ws *WebSocket) subscription(ctx context.Context, event string, sub Subscription, pairs []string) error {
+	if ws.version == WSVersionFutures {
+		return ws.subscriptionFutures(ctx, event, sub, pairs)
+	}
+	if sub.Token == "" && isStringInSlice(sub.Name, privateChannels) {
+		token, err := ws.authToken()
+		if err != nil {
+			return err
+		}
+		sub.Token = token
+	}
+
+	names := make([]string, len(pairs))
+	for i, pair := range pairs {
+		names[i] = ws.pairName(pair)
+	}
+	if ws.version == WSVersion2 {
+		return ws.send(ctx, subscriptionV2(event, sub, names, ws.nextReqID()))
+	}
+
+	msg := map[string]interface{}{
+		"event":        event,
+		"subscription": sub,
+	}
+	if len(names) > 0 {
+		msg["pair"] = names
+	}
+	return ws.send(ctx, msg)
+}
+
+// Ping asks Kraken for a PongEvent
+func (ws *WebSocket) Ping(ctx context.Context) error {
+	if ws.version == WSVersion2 {
+		return ws.send(ctx, map[string]interface{}{"method": "ping", "req_id": ws.nextReqID()})
+	}
+	return ws.send(ctx, map[string]interface{}{"event": "ping", "reqid": ws.nextReqID()})
+}
+
+// pairName returns the WebSocket name of a pair alias
+func (ws *WebSocket) pairName(pair string) string {
+	if ws.registry == nil || ws.version == WSVersionFutures {
+		return pair
+	}
+	info, found := ws.registry.Pair(pair)
+	if !found || info.WSName == "" {
+		return pair
+	}
+	if ws.version == WSVersion2 {
+		return symbolV2(info.WSName)
+	}
+	return info.WSName
+}
+
+// channelKey returns the channel of the events of a subscription to pair
+func (ws *WebSocket) channelKey(sub Subscription, pair string) WSChannel {
+	if pair != "" {
+		pair = ws.pairName(pair)
+	}
+	if ws.version == WSVersionFutures {
+		return WSChannel{Name: sub.Name, Pair: pair}
+	}
+
+	param := 0
+	switch sub.Name {
+	case ChannelBook:
+		// Kraken's default depth and interval
+		param = 10
+		if sub.Depth > 0 {
+			param = sub.Depth
+		}
+	case ChannelOHLC:
+		param = 1
+		if sub.Interval > 0 {
+			param = sub.Interval
+		}
+	}
+	return WSChannel{Name: channelName(sub.Name, param), Pair: pair}
+}
+
+// nextReqID returns a new request ID
+func (ws *WebSocket) nextReqID() int64 {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	ws.lastReqID++
+	return ws.lastReqID
+}
+
+// send writes msg as JSON, giving up when ctx is done. An aborted write leaves the
+// connection unusable.
+func (ws *WebSocket) send(ctx context.Context, msg interface{}) error {
+	ws.mu.Lock()
+	conn, closed := ws.conn, ws.closed
+	ws.mu.Unlock()
+	if conn == nil {
+		return fmt.Errorf("websocket %s is not connected", ws.url)
+	}
+	if closed {
+		return ErrWebSocketClosed
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	ws.writeMu.Lock()
+	defer ws.writeMu.Unlock()
+	deadline, _ := ctx.Deadline()
+	conn.SetWriteDeadline(deadline)
+
+	// a write blocked on a peer that does not read is aborted through the deadline of the
+	// underlying connection, which is safe to set concurrently
+	written := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.UnderlyingConn().SetWriteDeadline(time.Now())
+		case <-written:
+		}
+	}()
+	err := conn.WriteJSON(msg)
+	close(written)
+	if err != nil && ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return err
+}
+
+// read receives messages until the connection ends
+func (ws *WebSocket) read() {
+	defer close(ws.done)
+	defer close(ws.events)
+
+	for {
+		_, data, err := ws.conn.ReadMessage()
+		if err != nil {
+			ws.mu.Lock()
+			if !ws.closed {
+				ws.err = err
+			}
+			ws.mu.Unlock()
+			return
+		}
+
+		if ws.answer(data) {
+			continue
+		}
+
+		var events []WSEvent
+		if ws.version == WSVersion2 {
+			events, err = ws.decodeV2(data)
+		} else if ws.version == WSVersionFutures {
+			if ws.answerChallenge(data) {
+				continue
+			}
+			events, err = ws.decodeFutures(data)
+		} else {
+			events, err = decodeWSMessage(data)
+		}
+		if err != nil {
+			events = []WSEvent{&ErrorEvent{Err: fmt.Errorf("Could not decode websocket message %s: %s", data, err)}}
+		}
+		for _, event := range events {
+			if private, ok := event.(sequenced); ok {
+				if gap := ws.checkSequence(private); gap != nil && !ws.deliver(gap) {
+					return
+				}
+			}
+			if !ws.deliver(event) {
+				return
+			}
+		}
+	}
+}
+
+// deliver passes event to the consumer, it returns false when the WebSocket was closed
+func (ws *WebSocket) deliver(event WSEvent) bool {
+	select {
+	case ws.events <- event:
+		return true
+	case <-ws.quit:
+		return false
+	}
+}
+
+// decodeWSMessage decodes a message of the WebSocket API v1
+func decodeWSMessage(data []byte) ([]WSEvent, error) {
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 {
+		return nil, errors.New("empty message")
+	}
+	if data[0] == '{' {
+		event, err := decodeWSObject(data)
+		if err != nil {
+			return nil, err
+		}
+		return []WSEvent{event}, nil
+	}
+
+	var fields []json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	if len(fields) == 3 && bytes.HasPrefix(fields[0], []byte("[")) {
+		return decodeWSPrivate(fields)
+	}
+	if len(fields) < 4 {
+		return nil, fmt.Errorf("the length is not at least 4 but %d", len(fields))
+	}
+
+	var channel WSChannel
+	if err := json.Unmarshal(fields[len(fields)-2], &channel.Name); err != nil {
+		return nil, fmt.Errorf("channel name: %s", err)
+	}
+	if err := json.Unmarshal(fields[len(fields)-1], &channel.Pair); err != nil {
+		return nil, fmt.Errorf("pair: %s", err)
+	}
+	payloads := fields[1 : len(fields)-2]
+
+	name, param := channel.Name, 0
+	if i := strings.IndexByte(name, '-'); i >= 0 {
+		p, err := strconv.Atoi(name[i+1:])
+		if err != nil {
+			return nil, fmt.Errorf("invalid channel name %s", channel.Name)
+		}
+		name, param = name[:i], p
+	}
+
+	switch name {
+	case ChannelTicker:
+		return decodeWSTicker(channel, payloads[0])
+	case ChannelOHLC:
+		return decodeWSOHLC(channel, param, payloads[0])
+	case ChannelTrade:
+		return decodeWSTrades(channel, payloads[0])
+	case ChannelSpread:
+		return decodeWSSpread(channel, payloads[0])
+	case ChannelBook:
+		return decodeWSBook(channel, param, payloads)
+	}
+	return nil, fmt.Errorf("unknown channel %s", channel.Name)
+}
+
+// decodeWSObject decodes a message with an event field
+func decodeWSObject(data []byte) (WSEvent, error) {
+	var head struct {
+		Event        string `json:"event"`
+		ErrorMessage string `json:"errorMessage"`
+	}
+	if err := json.Unmarshal(data, &head); err != nil {
+		return nil, err
+	}
+
+	switch head.Event {
+	case "heartbeat":
+		return &HeartbeatEvent{}, nil
+	case "pong":
+		event := &PongEvent{}
+		return event, json.Unmarshal(data, event)
+	case "systemStatus":
+		event := &SystemStatusEvent{}
+		return event, json.Unmarshal(data, event)
+	case "subscriptionStatus":
+		event := &SubscriptionStatusEvent{}
+		if err := json.Unmarshal(data, event); err != nil {
+			return nil, err
+		}
+		var names struct {
+			ChannelName string `json:"channelName"`
+			Pair        string `json:"pair"`
+		}
+		json.Unmarshal(data, &names)
+		event.WSChannel = WSChannel{Name: names.ChannelName, Pair: names.Pair}
+		return event, nil
+	case "error":
+		return &ErrorEvent{Err: fmt.Errorf("Kraken websocket error: %s", head.ErrorMessage)}, nil
+	}
+	return nil, fmt.Errorf("unknown event %q", head.Event)
+}
+
+func decodeWSTicker(channel WSChannel, payload json.RawMessage) ([]WSEvent, error) {
+	var ticker struct {
+		PairTickerInfo
+		// The WebSocket sends the opening price of today and of the last 24 hours
+		Open []Decimal `json:"o"`
+	}
+	if err := json.Unmarshal(payload, &ticker); err != nil {
+		return nil, err
+	}
+
+	event := &TickerEvent{WSChannel: channel, Ticker: ticker.PairTickerInfo}
+	if len(ticker.Open) > 0 {
+		event.Ticker.OpeningPrice = ticker.Open[0]
+	}
+	return []WSEvent{event}, nil
+}
+
+// decodeWSOHLC decodes array(<time>, <etime>, <open>, <high>, <low>, <close>, <vwap>, <volume>, <count>)
+func decodeWSOHLC(channel WSChannel, interval int, payload json.RawMessage) ([]WSEvent, error) {
+	var fields []json.RawMessage
+	if err := json.Unmarshal(payload, &fields); err != nil {
+		return nil, err
+	}
+	if len(fields) != 9 {
+		return nil, fmt.Errorf("the length is not 9 but %d", len(fields))
+	}
+
+	updated, err := floatString(fields[0], "time")
+	if err != nil {
+		return nil, err
+	}
+	end, err := floatString(fields[1], "etime")
+	if err != nil {
+		return nil, err
+	}
+	// The REST layout starts with the candle's start time instead
+	start := end - float64(interval*60)
+	var ohlc OHLC
+	if err := ohlc.decodeFields(time.Unix(int64(start), 0), fields[2:]); err != nil {
+		return nil, err
+	}
+
+	return []WSEvent{&OHLCEvent{
+		WSChannel:  channel,
+		Interval:   interval,
+		EndTime:    floatTime(end),
+		UpdateTime: floatTime(updated),
+		OHLC:       ohlc,
+	}}, nil
+}
+
+// decodeWSTrades decodes array(array(<price>, <volume>, <time>, <side>, <orderType>, <misc>), ...)
+func decodeWSTrades(channel WSChannel, payload json.RawMessage) ([]WSEvent, error) {
+	var rows [][]json.RawMessage
+	if err := json.Unmarshal(payload, &rows); err != nil {
+		return nil, err
+	}
+
+	event := &TradeEvent{WSChannel: channel, Trades: make([]TradeInfo, len(rows))}
+	for i, fields := range rows {
+		if len(fields) < 6 {
+			return nil, fmt.Errorf("trade %d: the length is not at least 6 but %d", i, len(fields))
+		}
+		// The REST layout sends the time as number
+		ts, err := floatString(fields[2], "time")
+		if err != nil {
+			return nil, fmt.Errorf("trade %d: %s", i, err)
+		}
+		if err := event.Trades[i].decodeFields(ts, fields[:6]); err != nil {
+			return nil, fmt.Errorf("trade %d: %s", i, err)
+		}
+	}
+	return []WSEvent{event}, nil
+}
+
+// floatString decodes a string encoded number
+func floatString(data json.RawMessage, name string) (float64, error) {
+	var value string
+	if err := json.Unmarshal(data, &value); err != nil {
+		return 0, fmt.Errorf("%s should be a string, got %s", name, data)
+	}
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %s", name, err)
+	}
+	return f, nil
+}
+
+// decodeWSSpread decodes array(<bid>, <ask>, <timestamp>, <bidVolume>, <askVolume>)
+func decodeWSSpread(channel WSChannel, payload json.RawMessage) ([]WSEvent, error) {
+	var fields []json.RawMessage
+	if err := json.Unmarshal(payload, &fields); err != nil {
+		return nil, err
+	}
+	if len(fields) < 5 {
+		return nil, fmt.Errorf("the length is not at least 5 but %d", len(fields))
+	}
+
+	event := &SpreadEvent{WSChannel: channel}
+	ts, err := floatString(fields[2], "timestamp")
+	if err != nil {
+		return nil, err
+	}
+	event.Time = floatTime(ts)
+
+	targets := map[int]*Decimal{0: &event.Bid, 1: &event.Ask, 3: &event.BidVolume, 4: &event.AskVolume}
+	names := map[int]string{0: "bid", 1: "ask", 3: "bid volume", 4: "ask volume"}
+	for i, target := range targets {
+		if *target, err = decimalString(fields[i], names[i]); err != nil {
+			return nil, err
+		}
+	}
+	return []WSEvent{event}, nil
+}
+
+// decodeWSBook decodes a book snapshot with "as" and "bs" or one or two update objects
+// with "a" and "b", the last of which carries the checksum "c"
+func decodeWSBook(channel WSChannel, depth int, payloads []json.RawMessage) ([]WSEvent, error) {
+	event := &BookEvent{WSChannel: channel, Depth: depth}
+	for _, payload := range payloads {
+		var book struct {
+			AskSnapshot []json.RawMessage `json:"as"`
+			BidSnapshot []json.RawMessage `json:"bs"`
+			Asks        []json.RawMessage `json:"a"`
+			Bids        []json.RawMessage `json:"b"`
+			Checksum    string            `json:"c"`
+		}
+		if err := json.Unmarshal(payload, &book); err != nil {
+			return nil, err
+		}
+
+		if book.AskSnapshot != nil || book.BidSnapshot != nil {
+			event.Snapshot = true
+		}
+		for _, side := range []struct {
+			rows   []json.RawMessage
+			levels *[]OrderBookItem
+		}{
+			{book.AskSnapshot, &event.Asks},
+			{book.BidSnapshot, &event.Bids},
+			{book.Asks, &event.Asks},
+			{book.Bids, &event.Bids},
+		} {
+			for _, row := range side.rows {
+				level, err := newWSBookLevel(row)
+				if err != nil {
+					return nil, err
+				}
+				*side.levels = append(*side.levels, level)
+			}
+		}
+
+		if book.Checksum != "" {
+			checksum, err := strconv.ParseUint(book.Checksum, 10, 32)
+			if err != nil {
+				return nil, fmt.Errorf("checksum: %s", err)
+			}
+			event.Checksum = uint32(checksum)
+		}
+	}
+	return []WSEvent{event}, nil
+}
+
+// newWSBookLevel decodes array(<price>, <volume>, <timestamp>, ["r"])
+func newWSBookLevel(row json.RawMessage) (OrderBookItem, error) {
+	var fields []json.RawMessage
+	if err := json.Unmarshal(row, &fields); err != nil {
+		return OrderBookItem{}, err
+	}
+	if len(fields) < 3 {
+		return OrderBookItem{}, fmt.Errorf("the length of a price level is not at least 3 but %d", len(fields))
+	}
+
+	price, err := decimalString(fields[0], "price")
+	if err != nil {
+		return OrderBookItem{}, err
+	}
+	amount, err := decimalString(fields[1], "volume")
+	if err != nil {
+		return OrderBookItem{}, err
+	}
+	ts, err := floatString(fields[2], "timestamp")
+	if err != nil {
+		return OrderBookItem{}, err
+	}
+	return OrderBookItem{Price: price, Amount: amount, Ts: int64(ts)}, nil
+}
@@ -0,0 +1,616 @@
+package krakenapi
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// WSVersionFutures is the protocol of the Kraken Futures WebSocket API
+const WSVersionFutures WSVersion = 3
+
+const (
+	// FuturesWSURL is the endpoint of the Kraken Futures WebSocket API
+	FuturesWSURL = "wss://futures.kraken.com/ws/v1"
+	// FuturesDemoWSURL is the WebSocket endpoint of the Kraken Futures demo environment
+	FuturesDemoWSURL = "wss://demo-futures.kraken.com/ws/v1"
+)
+
+// Kraken Futures feed names, the public ticker, book and trade feeds use ChannelTicker,
+// ChannelBook and ChannelTrade and the balances feed ChannelBalances
+const (
+	ChannelHeartbeat         = "heartbeat"
+	ChannelFills             = "fills"
+	ChannelOpenPositions     = "open_positions"
+	ChannelFuturesOpenOrders = "open_orders"
+)
+
+// FuturesTickerEvent is an update of the futures ticker feed
+type FuturesTickerEvent struct {
+	WSChannel
+	Time   time.Time
+	Ticker FuturesTicker
+}
+
+// FuturesBookEvent is a snapshot or an update of the futures book feed, which carries the
+// full book. Apply the embedded BookEvent to a LiveOrderBook of depth 0 to maintain it.
+type FuturesBookEvent struct {
+	BookEvent
+	Sequence int64
+	Time     time.Time
+}
+
+func (e *FuturesBookEvent) sequence() int64 {
+	return e.Sequence
+}
+
+func (e *FuturesBookEvent) isSnapshot() bool {
+	return e.Snapshot
+}
+
+// FuturesTrade is a public trade of a futures contract
+type FuturesTrade struct {
+	ID   string
+	Side Side
+	// Type like "fill", "liquidation" or "termination"
+	Type     string
+	Sequence int64
+	Time     time.Time
+	Size     Decimal
+	Price    Decimal
+}
+
+// FuturesTradeEvent carries the recent trades after subscribing and then every new trade
+type FuturesTradeEvent struct {
+	WSChannel
+	Snapshot bool
+	Trades   []FuturesTrade
+}
+
+// FuturesFillsEvent carries the recent fills of the account after subscribing and then new fills
+type FuturesFillsEvent struct {
+	WSChannel
+	Snapshot bool
+	Fills    []FuturesFill
+}
+
+// FuturesPositionUpdate is an open position in the open_positions feed
+type FuturesPositionUpdate struct {
+	Symbol string `json:"instrument"`
+	// Position size, negative for short positions
+	Balance              Decimal `json:"balance"`
+	EntryPrice           Decimal `json:"entry_price"`
+	MarkPrice            Decimal `json:"mark_price"`
+	IndexPrice           Decimal `json:"index_price"`
+	PnL                  Decimal `json:"pnl"`
+	PnLCurrency          string  `json:"pnl_currency"`
+	LiquidationThreshold Decimal `json:"liquidation_threshold"`
+	EffectiveLeverage    Decimal `json:"effective_leverage"`
+	ReturnOnEquity       Decimal `json:"return_on_equity"`
+	InitialMargin        Decimal `json:"initial_margin"`
+	MaintenanceMargin    Decimal `json:"maintenance_margin"`
+}
+
+// FuturesOpenPositionsEvent carries all open positions of the account
+type FuturesOpenPositionsEvent struct {
+	WSChannel
+	Time      time.Time
+	Positions []FuturesPositionUpdate
+}
+
+// FuturesOpenOrdersEvent carries the open orders after subscribing and then every change.
+// When Canceled is set the order left the open orders, e.g. because it was filled.
+type FuturesOpenOrdersEvent struct {
+	WSChannel
+	Snapshot bool
+	Orders   []FuturesOrder
+	Canceled bool
+	// Reason of the change, e.g. "new_placed_order_by_user" or "cancelled_by_user"
+	Reason string
+}
+
+// FuturesMarginBalance is the balance of a single-collateral margin account
+type FuturesMarginBalance struct {
+	Name              string  `json:"name"`
+	Pair              string  `json:"pair"`
+	Unit              string  `json:"unit"`
+	Balance           Decimal `json:"balance"`
+	PortfolioValue    Decimal `json:"portfolio_value"`
+	Available         Decimal `json:"available"`
+	InitialMargin     Decimal `json:"initial_margin"`
+	MaintenanceMargin Decimal `json:"maintenance_margin"`
+	UnrealizedFunding Decimal `json:"unrealized_funding"`
+	PnL               Decimal `json:"pnl"`
+}
+
+// FuturesFlexCurrency is a currency held in the multi-collateral account
+type FuturesFlexCurrency struct {
+	Quantity         Decimal `json:"quantity"`
+	Value            Decimal `json:"value"`
+	CollateralValue  Decimal `json:"collateral_value"`
+	Available        Decimal `json:"available"`
+	Haircut          Decimal `json:"haircut"`
+	ConversionSpread Decimal `json:"conversion_spread"`
+}
+
+// FuturesFlexBalance is the balance of the multi-collateral account
+type FuturesFlexBalance struct {
+	Currencies        map[string]FuturesFlexCurrency `json:"currencies"`
+	BalanceValue      Decimal                        `json:"balance_value"`
+	PortfolioValue    Decimal                        `json:"portfolio_value"`
+	CollateralValue   Decimal                        `json:"collateral_value"`
+	InitialMargin     Decimal                        `json:"initial_margin"`
+	MaintenanceMargin Decimal                        `json:"maintenance_margin"`
+	AvailableMargin   Decimal                        `json:"available_margin"`
+	MarginEquity      Decimal                        `json:"margin_equity"`
+	PnL               Decimal                        `json:"pnl"`
+	UnrealizedFunding Decimal                        `json:"unrealized_funding"`
+	TotalUnrealized   Decimal                        `json:"total_unrealized"`
+}
+
+// FuturesBalancesEvent carries the balances of all futures accounts
+type FuturesBalancesEvent struct {
+	WSChannel
+	Snapshot bool
+	Sequence int64
+	Time     time.Time
+	// Spot holdings by currency
+	Holding map[string]Decimal
+	// Single-collateral margin accounts by name
+	Futures map[string]FuturesMarginBalance
+	Flex    FuturesFlexBalance
+}
+
+// futuresChallenge authenticates the private feeds of a Kraken Futures connection
+type futuresChallenge struct {
+	key      string
+	original string
+	signed   string
+}
+
+// signChallenge signs a Kraken Futures WebSocket challenge
+func signChallenge(challenge string, secret []byte) string {
+	return base64.StdEncoding.EncodeToString(getHMacSha512(getSha256([]byte(challenge)), secret))
+}
+
+// AuthenticateFutures requests and signs a challenge with the keys of api, which is then
+// used for the subscriptions to private feeds. Use it with FuturesWSURL.
+func (ws *WebSocket) AuthenticateFutures(ctx context.Context, api *FuturesAPI) error {
+	if ws.version != WSVersionFutures {
+		return fmt.Errorf("AuthenticateFutures is only supported on Kraken Futures websockets")
+	}
+	secret, err := base64.StdEncoding.DecodeString(api.secret)
+	if err != nil {
+		return fmt.Errorf("Could not decode the futures API secret: %s", err)
+	}
+
+	reply := make(chan []byte, 1)
+	ws.mu.Lock()
+	ws.challenge = reply
+	ws.mu.Unlock()
+	defer func() {
+		ws.mu.Lock()
+		ws.challenge = nil
+		ws.mu.Unlock()
+	}()
+
+	if err := ws.send(ctx, map[string]string{"event": "challenge", "api_key": api.key}); err != nil {
+		return err
+	}
+
+	var data []byte
+	select {
+	case data = <-reply:
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-ws.done:
+		if err := ws.Err(); err != nil {
+			return err
+		}
+		return ErrWebSocketClosed
+	}
+
+	var answer wsMessageFutures
+	if err := json.Unmarshal(data, &answer); err != nil {
+		return fmt.Errorf("Could not decode challenge: %s", err)
+	}
+	if answer.Event != "challenge" {
+		return fmt.Errorf("Kraken Futures refused the challenge: %s", answer.Message)
+	}
+
+	ws.mu.Lock()
+	ws.futuresAuth = &futuresChallenge{key: api.key, original: answer.Message, signed: signChallenge(answer.Message, secret)}
+	ws.mu.Unlock()
+	return nil
+}
+
+// answerChallenge passes the answer to a challenge request to AuthenticateFutures, it
+// returns false for all other messages
+func (ws *WebSocket) answerChallenge(data []byte) bool {
+	ws.mu.Lock()
+	reply := ws.challenge
+	ws.mu.Unlock()
+	if reply == nil {
+		return false
+	}
+
+	var head wsMessageFutures
+	if json.Unmarshal(data, &head) != nil || (head.Event != "challenge" && head.Event != "error") {
+		return false
+	}
+	select {
+	case reply <- data:
+	default:
+	}
+	return true
+}
+
+// subscriptionFutures sends a subscribe or unsubscribe request of the futures protocol
+func (ws *WebSocket) subscriptionFutures(ctx context.Context, event string, sub Subscription, products []string) error {
+	msg := map[string]interface{}{"event": event, "feed": sub.Name}
+	if len(products) > 0 {
+		msg["product_ids"] = products
+	}
+	if isStringInSlice(sub.Name, privateChannels) {
+		ws.mu.Lock()
+		auth := ws.futuresAuth
+		ws.mu.Unlock()
+		if auth == nil {
+			return fmt.Errorf("websocket is not authenticated, call AuthenticateFutures first")
+		}
+		msg["api_key"] = auth.key
+		msg["original_challenge"] = auth.original
+		msg["signed_challenge"] = auth.signed
+	}
+	return ws.send(ctx, msg)
+}
+
+// wsMessageFutures is the common part of the futures messages
+type wsMessageFutures struct {
+	Event      string      `json:"event"`
+	Feed       string      `json:"feed"`
+	ProductID  string      `json:"product_id"`
+	ProductIDs []string    `json:"product_ids"`
+	Message    string      `json:"message"`
+	Version    json.Number `json:"version"`
+}
+
+// futuresTime converts the milliseconds Kraken Futures sends to a time
+func futuresTime(ms int64) time.Time {
+	if ms == 0 {
+		return time.Time{}
+	}
+	return time.Unix(ms/1000, ms%1000*int64(time.Millisecond))
+}
+
+// decodeFutures decodes a message of the Kraken Futures protocol
+func (ws *WebSocket) decodeFutures(data []byte) ([]WSEvent, error) {
+	var head wsMessageFutures
+	if err := json.Unmarshal(data, &head); err != nil {
+		return nil, err
+	}
+
+	switch head.Event {
+	case "":
+	case "info":
+		return []WSEvent{&SystemStatusEvent{Status: "online", Version: head.Version.String()}}, nil
+	case "subscribed", "unsubscribed", "subscribed_failed", "unsubscribed_failed":
+		status := head.Event
+		if strings.HasSuffix(status, "_failed") {
+			status = SubscriptionError
+		}
+		products := head.ProductIDs
+		if len(products) == 0 {
+			products = []string{""}
+		}
+		events := make([]WSEvent, len(products))
+		for i, product := range products {
+			events[i] = &SubscriptionStatusEvent{
+				WSChannel:    WSChannel{Name: head.Feed, Pair: product},
+				Status:       status,
+				Subscription: Subscription{Name: head.Feed},
+				ErrorMessage: head.Message,
+			}
+		}
+		return events, nil
+	case "error", "alert":
+		return []WSEvent{&ErrorEvent{Err: fmt.Errorf("Kraken Futures %s: %s", head.Event, head.Message)}}, nil
+	default:
+		return nil, fmt.Errorf("unknown event %s", head.Event)
+	}
+
+	channel := WSChannel{Name: strings.TrimSuffix(head.Feed, "_snapshot"), Pair: head.ProductID}
+	snapshot := strings.HasSuffix(head.Feed, "_snapshot")
+	var event WSEvent
+	var err error
+	switch channel.Name {
+	case ChannelHeartbeat:
+		event = &HeartbeatEvent{}
+	case ChannelTicker:
+		event, err = decodeFuturesTicker(channel, data)
+	case ChannelBook:
+		event, err = decodeFuturesBook(channel, snapshot, data)
+	case ChannelTrade:
+		event, err = decodeFuturesTrades(channel, snapshot, data)
+	case ChannelFills:
+		event, err = decodeFuturesFills(channel, snapshot, data)
+	case ChannelOpenPositions:
+		var raw struct {
+			Positions []FuturesPositionUpdate `json:"positions"`
+			Timestamp int64                   `json:"timestamp"`
+		}
+		err = json.Unmarshal(data, &raw)
+		event = &FuturesOpenPositionsEvent{WSChannel: channel, Time: futuresTime(raw.Timestamp), Positions: raw.Positions}
+	case ChannelFuturesOpenOrders:
+		event, err = decodeFuturesOpenOrders(channel, snapshot, data)
+	case ChannelBalances:
+		var raw struct {
+			Holding   map[string]Decimal              `json:"holding"`
+			Futures   map[string]FuturesMarginBalance `json:"futures"`
+			Flex      FuturesFlexBalance              `json:"flex_futures"`
+			Timestamp int64                           `json:"timestamp"`
+			Sequence  int64                           `json:"seq"`
+		}
+		err = json.Unmarshal(data, &raw)
+		event = &FuturesBalancesEvent{WSChannel: channel, Snapshot: snapshot, Sequence: raw.Sequence, Time: futuresTime(raw.Timestamp),
+			Holding: raw.Holding, Futures: raw.Futures, Flex: raw.Flex}
+	default:
+		return nil, fmt.Errorf("unknown feed %s", head.Feed)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s message: %s", head.Feed, err)
+	}
+	return []WSEvent{event}, nil
+}
+
+func decodeFuturesTicker(channel WSChannel, data []byte) (WSEvent, error) {
+	var raw struct {
+		Time                  int64   `json:"time"`
+		Tag                   string  `json:"tag"`
+		Pair                  string  `json:"pair"`
+		Bid                   Decimal `json:"bid"`
+		BidSize               Decimal `json:"bid_size"`
+		Ask                   Decimal `json:"ask"`
+		AskSize               Decimal `json:"ask_size"`
+		Last                  Decimal `json:"last"`
+		Volume                Decimal `json:"volume"`
+		VolumeQuote           Decimal `json:"volumeQuote"`
+		Open                  Decimal `json:"open"`
+		High                  Decimal `json:"high"`
+		Low                   Decimal `json:"low"`
+		Change                Decimal `json:"change"`
+		Index                 Decimal `json:"index"`
+		MarkPrice             Decimal `json:"markPrice"`
+		OpenInterest          Decimal `json:"openInterest"`
+		FundingRate           Decimal `json:"funding_rate"`
+		FundingRatePrediction Decimal `json:"funding_rate_prediction"`
+		Suspended             bool    `json:"suspended"`
+		PostOnly              bool    `json:"post_only"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+
+	return &FuturesTickerEvent{WSChannel: channel, Time: futuresTime(raw.Time), Ticker: FuturesTicker{
+		Symbol:                channel.Pair,
+		Pair:                  raw.Pair,
+		Tag:                   raw.Tag,
+		Last:                  raw.Last,
+		MarkPrice:             raw.MarkPrice,
+		IndexPrice:            raw.Index,
+		Bid:                   raw.Bid,
+		BidSize:               raw.BidSize,
+		Ask:                   raw.Ask,
+		AskSize:               raw.AskSize,
+		Volume24h:             raw.Volume,
+		VolumeQuote:           raw.VolumeQuote,
+		OpenInterest:          raw.OpenInterest,
+		Open24h:               raw.Open,
+		High24h:               raw.High,
+		Low24h:                raw.Low,
+		Change24h:             raw.Change,
+		FundingRate:           raw.FundingRate,
+		FundingRatePrediction: raw.FundingRatePrediction,
+		Suspended:             raw.Suspended,
+		PostOnly:              raw.PostOnly,
+	}}, nil
+}
+
+// futuresLevel is a price level of the futures book feed
+type futuresLevel struct {
+	Price Decimal `json:"price"`
+	Qty   Decimal `json:"qty"`
+}
+
+func decodeFuturesBook(channel WSChannel, snapshot bool, data []byte) (WSEvent, error) {
+	var raw struct {
+		Sequence  int64          `json:"seq"`
+		Timestamp int64          `json:"timestamp"`
+		Bids      []futuresLevel `json:"bids"`
+		Asks      []futuresLevel `json:"asks"`
+		// Single level of an update
+		Side  Side    `json:"side"`
+		Price Decimal `json:"price"`
+		Qty   Decimal `json:"qty"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+
+	event := &FuturesBookEvent{BookEvent: BookEvent{WSChannel: channel, Snapshot: snapshot}, Sequence: raw.Sequence, Time: futuresTime(raw.Timestamp)}
+	if !snapshot {
+		level := OrderBookItem{Price: raw.Price, Amount: raw.Qty}
+		switch raw.Side {
+		case SideBuy:
+			event.Bids = []OrderBookItem{level}
+		case SideSell:
+			event.Asks = []OrderBookItem{level}
+		default:
+			return nil, fmt.Errorf("unknown side %s", raw.Side)
+		}
+		return event, nil
+	}
+
+	for _, level := range raw.Bids {
+		event.Bids = append(event.Bids, OrderBookItem{Price: level.Price, Amount: level.Qty})
+	}
+	for _, level := range raw.Asks {
+		event.Asks = append(event.Asks, OrderBookItem{Price: level.Price, Amount: level.Qty})
+	}
+	return event, nil
+}
+
+// futuresTrade is a trade of the futures trade feed
+type futuresTrade struct {
+	UID      string  `json:"uid"`
+	Side     Side    `json:"side"`
+	Type     string  `json:"type"`
+	Sequence int64   `json:"seq"`
+	Time     int64   `json:"time"`
+	Qty      Decimal `json:"qty"`
+	Price    Decimal `json:"price"`
+}
+
+func decodeFuturesTrades(channel WSChannel, snapshot bool, data []byte) (WSEvent, error) {
+	var trades []futuresTrade
+	if snapshot {
+		var raw struct {
+			Trades []futuresTrade `json:"trades"`
+		}
+		if err := json.Unmarshal(data, &raw); err != nil {
+			return nil, err
+		}
+		trades = raw.Trades
+	} else {
+		var trade futuresTrade
+		if err := json.Unmarshal(data, &trade); err != nil {
+			return nil, err
+		}
+		trades = []futuresTrade{trade}
+	}
+
+	event := &FuturesTradeEvent{WSChannel: channel, Snapshot: snapshot}
+	for _, trade := range trades {
+		event.Trades = append(event.Trades, FuturesTrade{
+			ID:       trade.UID,
+			Side:     trade.Side,
+			Type:     trade.Type,
+			Sequence: trade.Sequence,
+			Time:     futuresTime(trade.Time),
+			Size:     trade.Qty,
+			Price:    trade.Price,
+		})
+	}
+	return event, nil
+}
+
+func decodeFuturesFills(channel WSChannel, snapshot bool, data []byte) (WSEvent, error) {
+	var raw struct {
+		Fills []struct {
+			Instrument    string  `json:"instrument"`
+			Time          int64   `json:"time"`
+			Price         Decimal `json:"price"`
+			Qty           Decimal `json:"qty"`
+			Buy           bool    `json:"buy"`
+			OrderID       string  `json:"order_id"`
+			ClientOrderID string  `json:"cli_ord_id"`
+			FillID        string  `json:"fill_id"`
+			FillType      string  `json:"fill_type"`
+			FeePaid       Decimal `json:"fee_paid"`
+			FeeCurrency   string  `json:"fee_currency"`
+		} `json:"fills"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+
+	event := &FuturesFillsEvent{WSChannel: channel, Snapshot: snapshot}
+	for _, fill := range raw.Fills {
+		side := SideSell
+		if fill.Buy {
+			side = SideBuy
+		}
+		event.Fills = append(event.Fills, FuturesFill{
+			FillID:        fill.FillID,
+			OrderID:       fill.OrderID,
+			ClientOrderID: fill.ClientOrderID,
+			Symbol:        fill.Instrument,
+			Side:          side,
+			Size:          fill.Qty,
+			Price:         fill.Price,
+			FillTime:      futuresTime(fill.Time),
+			FillType:      fill.FillType,
+			Fee:           fill.FeePaid,
+			FeeCurrency:   fill.FeeCurrency,
+		})
+	}
+	return event, nil
+}
+
+// futuresWSOrder is an order of the futures open_orders feed
+type futuresWSOrder struct {
+	Instrument     string  `json:"instrument"`
+	Time           int64   `json:"time"`
+	LastUpdateTime int64   `json:"last_update_time"`
+	Qty            Decimal `json:"qty"`
+	Filled         Decimal `json:"filled"`
+	LimitPrice     Decimal `json:"limit_price"`
+	StopPrice      Decimal `json:"stop_price"`
+	Type           string  `json:"type"`
+	OrderID        string  `json:"order_id"`
+	ClientOrderID  string  `json:"cli_ord_id"`
+	// 0 for buy and 1 for sell orders
+	Direction     int    `json:"direction"`
+	ReduceOnly    bool   `json:"reduce_only"`
+	TriggerSignal string `json:"triggerSignal"`
+}
+
+// order converts o to the type of the REST open orders
+func (o futuresWSOrder) order() FuturesOrder {
+	side := SideBuy
+	if o.Direction == 1 {
+		side = SideSell
+	}
+	return FuturesOrder{
+		OrderID:        o.OrderID,
+		ClientOrderID:  o.ClientOrderID,
+		Symbol:         o.Instrument,
+		Side:           side,
+		OrderType:      o.Type,
+		LimitPrice:     o.LimitPrice,
+		StopPrice:      o.StopPrice,
+		FilledSize:     o.Filled,
+		UnfilledSize:   o.Qty,
+		ReduceOnly:     o.ReduceOnly,
+		TriggerSignal:  o.TriggerSignal,
+		ReceivedTime:   futuresTime(o.Time),
+		LastUpdateTime: futuresTime(o.LastUpdateTime),
+	}
+}
+
+func decodeFuturesOpenOrders(channel WSChannel, snapshot bool, data []byte) (WSEvent, error) {
+	var raw struct {
+		Orders   []futuresWSOrder `json:"orders"`
+		Order    *futuresWSOrder  `json:"order"`
+		OrderID  string           `json:"order_id"`
+		IsCancel bool             `json:"is_cancel"`
+		Reason   string           `json:"reason"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+
+	event := &FuturesOpenOrdersEvent{WSChannel: channel, Snapshot: snapshot, Canceled: raw.IsCancel, Reason: raw.Reason}
+	for _, order := range raw.Orders {
+		event.Orders = append(event.Orders, order.order())
+	}
+	if raw.Order != nil {
+		event.Orders = append(event.Orders, raw.Order.order())
+	} else if raw.OrderID != "" {
+		event.Orders = append(event.Orders, FuturesOrder{OrderID: raw.OrderID})
+	}
+	return event, nil
+}
@@ -0,0 +1,152 @@
+package krakenapi
+
+import (
+	"context"
+	"encoding/base64"
+	"testing"
+
+	"github.com/gorilla/websocket"
+)
+
+// readFuturesRequest reads the next request sent to conn
+func readFuturesRequest(t *testing.T, conn *websocket.Conn) map[string]interface{} {
+	t.Helper()
+	var req map[string]interface{}
+	if err := conn.ReadJSON(&req); err != nil {
+		t.Fatal(err)
+	}
+	return req
+}
+
+func TestWebSocketFutures(t *testing.T) {
+	server := newWSTestServer(t)
+	ws := NewWebSocket(server.url() + "/ws/v1")
+	if err := ws.Connect(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer ws.Close()
+	conn := server.accept(t)
+	ctx := context.Background()
+
+	req := readFuturesRequest(t, conn)
+	if req["event"] != "subscribe" || req["feed"] != "heartbeat" {
+		t.Errorf("Connect() should subscribe to heartbeats, got %v", req)
+	}
+
+	api := NewFutures("futures-key", base64.StdEncoding.EncodeToString([]byte("futures-secret")))
+	if err := ws.Subscribe(ctx, Subscription{Name: ChannelFills}); err == nil {
+		t.Error("Subscribe() should require a challenge for private feeds")
+	}
+	done := make(chan error)
+	go func() { done <- ws.AuthenticateFutures(ctx, api) }()
+	req = readFuturesRequest(t, conn)
+	if req["event"] != "challenge" || req["api_key"] != "futures-key" {
+		t.Errorf("AuthenticateFutures() sent a wrong request, got %v", req)
+	}
+	conn.WriteMessage(websocket.TextMessage, []byte(`{"event":"challenge","message":"226aee50-88fc-4618-a42a-34f7709570b2"}`))
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ws.Subscribe(ctx, Subscription{Name: ChannelFills}); err != nil {
+		t.Fatal(err)
+	}
+	req = readFuturesRequest(t, conn)
+	if req["feed"] != "fills" || req["original_challenge"] != "226aee50-88fc-4618-a42a-34f7709570b2" ||
+		req["signed_challenge"] != signChallenge("226aee50-88fc-4618-a42a-34f7709570b2", []byte("futures-secret")) {
+		t.Errorf("Subscribe() should sign private feeds, got %v", req)
+	}
+	if err := ws.Subscribe(ctx, Subscription{Name: ChannelBook}, "PF_XBTUSD"); err != nil {
+		t.Fatal(err)
+	}
+	req = readFuturesRequest(t, conn)
+	if req["feed"] != "book" || req["product_ids"].([]interface{})[0] != "PF_XBTUSD" || req["signed_challenge"] != nil {
+		t.Errorf("Subscribe() sent a wrong public request, got %v", req)
+	}
+
+	messages := []string{
+		`{"event":"info","version":1}`,
+		`{"event":"subscribed","feed":"book","product_ids":["PF_XBTUSD"]}`,
+		`{"feed":"heartbeat","time":1534262350627}`,
+		`{"time":1612270825253,"feed":"ticker","product_id":"PF_XBTUSD","bid":34832.5,"ask":34847.5,"bid_size":42864,"ask_size":2300,"volume":262306237,"index":34803.45,"last":34852,"change":2.99,"funding_rate":3.891007752e-9,"funding_rate_prediction":4.2233756e-9,"suspended":false,"tag":"perpetual","pair":"XBT:USD","openInterest":28,"markPrice":34844.25,"post_only":false}`,
+		`{"feed":"book_snapshot","product_id":"PF_XBTUSD","timestamp":1612269825817,"seq":100,"tickSize":null,"bids":[{"price":34892.5,"qty":6385},{"price":34892,"qty":10924}],"asks":[{"price":34911.5,"qty":20598}]}`,
+		`{"feed":"book","product_id":"PF_XBTUSD","side":"buy","seq":101,"price":34892.5,"qty":0,"timestamp":1612269953629}`,
+		`{"feed":"book","product_id":"PF_XBTUSD","side":"sell","seq":103,"price":34900,"qty":5,"timestamp":1612269953629}`,
+		`{"feed":"trade_snapshot","product_id":"PF_XBTUSD","trades":[{"feed":"trade","product_id":"PF_XBTUSD","uid":"caa9c653-420b-4c24-a9f1-462a054d86f1","side":"sell","type":"fill","seq":655508,"time":1612266317519,"qty":15000,"price":34969.5}]}`,
+		`{"feed":"trade","product_id":"PF_XBTUSD","uid":"05af78ac-a774-478c-a50c-8b9c234e071e","side":"buy","type":"liquidation","seq":655509,"time":1612266317520,"qty":0.5,"price":34970}`,
+		`{"feed":"fills_snapshot","account":"DemoUser","fills":[{"instrument":"PF_XBTUSD","time":1600256910739,"price":10937.5,"seq":36,"buy":true,"qty":0.5,"remaining_order_qty":0,"order_id":"9e30258b-5a98-4002-968a-5b0e149bcfbf","cli_ord_id":"8b58d9da-fcaf-4f60-91bc-9973a3eba48d","fill_id":"cad76f07-814e-4dc6-8478-7867407b6bff","fill_type":"maker","fee_paid":-0.00009142857,"fee_currency":"BTC"}]}`,
+		`{"feed":"open_positions","account":"DemoUser","positions":[{"instrument":"PF_XBTUSD","balance":-0.1,"pnl":1.5,"entry_price":26000,"mark_price":26015,"index_price":26010,"liquidation_threshold":0,"effective_leverage":0.5,"return_on_equity":0.01,"initial_margin":130,"maintenance_margin":65,"pnl_currency":"USD"}],"seq":4,"timestamp":1687383625330}`,
+		`{"feed":"open_orders","order":{"instrument":"PF_XBTUSD","time":1567702877410,"last_update_time":1567702877410,"qty":304,"filled":0,"limit_price":10640,"stop_price":0,"type":"limit","order_id":"59302619-41d2-4f0b-941f-7e7914760ad3","direction":1,"reduce_only":true},"is_cancel":false,"reason":"new_placed_order_by_user"}`,
+		`{"feed":"open_orders","order_id":"59302619-41d2-4f0b-941f-7e7914760ad3","is_cancel":true,"reason":"cancelled_by_user"}`,
+		`{"feed":"balances_snapshot","account":"DemoUser","holding":{"USDT":1000.5},"futures":{"F-XBT:USD":{"name":"F-XBT:USD","pair":"XBT/USD","unit":"XBT","portfolio_value":0.1,"balance":0.1,"maintenance_margin":0,"initial_margin":0,"available":0.1,"unrealized_funding":0,"pnl":0}},"flex_futures":{"currencies":{"USDT":{"quantity":1000.5,"value":1000,"collateral_value":990,"available":1000.5,"haircut":0.01,"conversion_spread":0}},"portfolio_value":1000,"available_margin":990},"timestamp":1640995200000,"seq":0}`,
+		`{"event":"alert","message":"Failed to subscribe to authenticated feed"}`,
+	}
+	for _, msg := range messages {
+		conn.WriteMessage(websocket.TextMessage, []byte(msg))
+	}
+
+	if status, ok := nextEvent(t, ws).(*SystemStatusEvent); !ok || status.Version != "1" {
+		t.Errorf("expected info, got %+v", status)
+	}
+	sub, ok := nextEvent(t, ws).(*SubscriptionStatusEvent)
+	if !ok || sub.Status != SubscriptionSubscribed || sub.Channel() != ws.channelKey(Subscription{Name: ChannelBook}, "PF_XBTUSD") {
+		t.Errorf("expected subscription status, got %+v", sub)
+	}
+	if _, ok := nextEvent(t, ws).(*HeartbeatEvent); !ok {
+		t.Error("expected heartbeat")
+	}
+	ticker, ok := nextEvent(t, ws).(*FuturesTickerEvent)
+	if !ok || ticker.Pair != "PF_XBTUSD" || ticker.Ticker.MarkPrice.String() != "34844.25" || ticker.Ticker.FundingRate.String() != "0.000000003891007752" || ticker.Time.UnixNano() != 1612270825253000000 {
+		t.Errorf("expected ticker, got %+v", ticker)
+	}
+
+	book := NewLiveOrderBook("PF_XBTUSD", 0)
+	for i := 0; i < 2; i++ {
+		event, ok := nextEvent(t, ws).(*FuturesBookEvent)
+		if !ok {
+			t.Fatalf("expected book, got %+v", event)
+		}
+		if err := book.Apply(&event.BookEvent); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if bid, _ := book.BestBid(); bid.Price.String() != "34892" {
+		t.Errorf("the book update should remove the best bid, got %+v", bid)
+	}
+	if gap, ok := nextEvent(t, ws).(*SequenceGapEvent); !ok || gap.Expected != 102 || gap.Pair != "PF_XBTUSD" {
+		t.Errorf("expected a sequence gap, got %+v", gap)
+	}
+	if update, ok := nextEvent(t, ws).(*FuturesBookEvent); !ok || update.Asks[0].Amount.String() != "5" {
+		t.Errorf("expected book update, got %+v", update)
+	}
+
+	trades, ok := nextEvent(t, ws).(*FuturesTradeEvent)
+	if !ok || !trades.Snapshot || trades.Trades[0].Side != SideSell || trades.Trades[0].Size.String() != "15000" {
+		t.Errorf("expected trade snapshot, got %+v", trades)
+	}
+	if trades, ok := nextEvent(t, ws).(*FuturesTradeEvent); !ok || trades.Snapshot || trades.Trades[0].Type != "liquidation" || trades.Trades[0].Sequence != 655509 {
+		t.Errorf("expected trade, got %+v", trades)
+	}
+	fills, ok := nextEvent(t, ws).(*FuturesFillsEvent)
+	if !ok || fills.Name != ChannelFills || fills.Fills[0].Side != SideBuy || fills.Fills[0].Fee.String() != "-0.00009142857" || fills.Fills[0].FillTime.Unix() != 1600256910 {
+		t.Errorf("expected fills, got %+v", fills)
+	}
+	positions, ok := nextEvent(t, ws).(*FuturesOpenPositionsEvent)
+	if !ok || positions.Positions[0].Balance.String() != "-0.1" || positions.Positions[0].EntryPrice.String() != "26000" {
+		t.Errorf("expected positions, got %+v", positions)
+	}
+	orders, ok := nextEvent(t, ws).(*FuturesOpenOrdersEvent)
+	if !ok || orders.Canceled || orders.Orders[0].Side != SideSell || orders.Orders[0].UnfilledSize.String() != "304" || !orders.Orders[0].ReduceOnly {
+		t.Errorf("expected new order, got %+v", orders)
+	}
+	if orders, ok := nextEvent(t, ws).(*FuturesOpenOrdersEvent); !ok || !orders.Canceled || orders.Orders[0].OrderID != "59302619-41d2-4f0b-941f-7e7914760ad3" {
+		t.Errorf("expected canceled order, got %+v", orders)
+	}
+	balances, ok := nextEvent(t, ws).(*FuturesBalancesEvent)
+	if !ok || !balances.Snapshot || balances.Holding["USDT"].String() != "1000.5" || balances.Futures["F-XBT:USD"].Available.String() != "0.1" || balances.Flex.Currencies["USDT"].CollateralValue.String() != "990" {
+		t.Errorf("expected balances, got %+v", balances)
+	}
+	if _, ok := nextEvent(t, ws).(*ErrorEvent); !ok {
+		t.Error("expected alert")
+	}
+}
@@ -0,0 +1,269 @@
+package krakenapi
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// BackpressurePolicy decides what a WSHub does when a subscriber does not keep up
+type BackpressurePolicy int
+
+const (
+	// BackpressureBlock waits until the subscriber has room, which delays all other subscribers
+	BackpressureBlock BackpressurePolicy = iota
+	// BackpressureDropOldest discards the oldest buffered event to make room
+	BackpressureDropOldest
+	// BackpressureCoalesce keeps only the latest event, e.g. for tickers
+	BackpressureCoalesce
+)
+
+// WSSource is a stream of WebSocket events with subscriptions, e.g. a WebSocket or a WSSupervisor
+type WSSource interface {
+	Events() <-chan WSEvent
+	Subscribe(ctx context.Context, sub Subscription, pairs ...string) error
+	Unsubscribe(ctx context.Context, sub Subscription, pairs ...string) error
+	channelKey(sub Subscription, pair string) WSChannel
+}
+
+// WSHub shares one upstream subscription per channel and pair between many subscribers.
+// The upstream subscription is made with the first subscriber and ended with the last one.
+// A subscriber joining a book channel later starts with a snapshot: the hub replays its
+// copy of a ChannelBook book, and resubscribes other book feeds like ChannelLevel3.
+type WSHub struct {
+	source WSSource
+
+	// subscribeMu orders the upstream subscriptions
+	subscribeMu sync.Mutex
+	mu          sync.RWMutex
+	subscribers map[WSChannel][]*HubSubscriber
+	// books of the BookEvent channels, replayed to late subscribers
+	books map[WSChannel]*LiveOrderBook
+}
+
+// HubSubscriber receives the events of one channel and pair from a WSHub
+type HubSubscriber struct {
+	hub     *WSHub
+	key     WSChannel
+	sub     Subscription
+	pair    string
+	policy  BackpressurePolicy
+	events  chan WSEvent
+	done    chan struct{}
+	once    sync.Once
+	mu      sync.Mutex
+	closed  bool
+	dropped int
+}
+
+// NewWSHub creates a WSHub on source, Run must be called to distribute the events
+func NewWSHub(source WSSource) *WSHub {
+	return &WSHub{
+		source:      source,
+		subscribers: map[WSChannel][]*HubSubscriber{},
+		books:       map[WSChannel]*LiveOrderBook{},
+	}
+}
+
+// isBookChannel reports whether the events of sub are deltas which need every event since a snapshot
+func isBookChannel(sub Subscription) bool {
+	return sub.Name == ChannelBook || sub.Name == ChannelLevel3
+}
+
+// Subscribe returns a subscriber to the events of sub for pair, which is empty for
+// private channels. buffer is the number of events buffered for the subscriber, it is
+// always 1 with BackpressureCoalesce. Book channels only support BackpressureBlock,
+// a dropped update corrupts the book.
+func (h *WSHub) Subscribe(ctx context.Context, sub Subscription, pair string, policy BackpressurePolicy, buffer int) (*HubSubscriber, error) {
+	if isBookChannel(sub) && policy != BackpressureBlock {
+		return nil, fmt.Errorf("%s channels need BackpressureBlock", sub.Name)
+	}
+	if policy == BackpressureCoalesce || buffer < 1 {
+		buffer = 1
+	}
+	s := &HubSubscriber{
+		hub:    h,
+		key:    h.source.channelKey(sub, pair),
+		sub:    sub,
+		pair:   pair,
+		policy: policy,
+		events: make(chan WSEvent, buffer),
+		done:   make(chan struct{}),
+	}
+
+	h.subscribeMu.Lock()
+	defer h.subscribeMu.Unlock()
+
+	// Register before subscribing, so the subscriber gets the first events
+	h.mu.Lock()
+	first := len(h.subscribers[s.key]) == 0
+	book := h.books[s.key]
+	if book != nil && book.Synced() {
+		// Run applies and dispatches under the lock, so the snapshot is followed by every later update
+		asks, bids := book.Top(-1)
+		s.events <- &BookEvent{WSChannel: s.key, Depth: book.depth, Snapshot: true, Asks: asks, Bids: bids}
+	}
+	h.subscribers[s.key] = append(h.subscribers[s.key], s)
+	h.mu.Unlock()
+
+	var err error
+	switch {
+	case first:
+		err = h.source.Subscribe(ctx, sub, s.pairs()...)
+	case isBookChannel(sub) && book == nil:
+		// No copy of the book, a new subscription sends every subscriber a snapshot
+		if err = h.source.Unsubscribe(ctx, sub, s.pairs()...); err == nil {
+			err = h.source.Subscribe(ctx, sub, s.pairs()...)
+		}
+	}
+	if err != nil {
+		h.mu.Lock()
+		h.subscribers[s.key] = removeSubscriber(h.subscribers[s.key], s)
+		if len(h.subscribers[s.key]) == 0 {
+			delete(h.subscribers, s.key)
+			delete(h.books, s.key)
+		}
+		h.mu.Unlock()
+		return nil, err
+	}
+	return s, nil
+}
+
+// removeSubscriber returns subscribers without s
+func removeSubscriber(subscribers []*HubSubscriber, s *HubSubscriber) []*HubSubscriber {
+	for i, subscriber := range subscribers {
+		if subscriber == s {
+			return append(subscribers[:i:i], subscribers[i+1:]...)
+		}
+	}
+	return subscribers
+}
+
+// Run distributes the events of the source until it is closed or ctx is done
+func (h *WSHub) Run(ctx context.Context) error {
+	for {
+		select {
+		case event, ok := <-h.source.Events():
+			if !ok {
+				return ErrWebSocketClosed
+			}
+			h.mu.Lock()
+			subscribers := h.subscribers[event.Channel()]
+			if book, ok := event.(*BookEvent); ok && len(subscribers) > 0 {
+				h.track(book)
+			}
+			h.mu.Unlock()
+			for _, s := range subscribers {
+				if !s.deliver(ctx, event) {
+					return ctx.Err()
+				}
+			}
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// track applies a book event to the hub's copy of the book, the caller holds mu
+func (h *WSHub) track(event *BookEvent) {
+	book := h.books[event.WSChannel]
+	if book == nil {
+		book = NewLiveOrderBook(event.Pair, event.Depth)
+		h.books[event.WSChannel] = book
+	}
+	// A book failing the checksum waits for the next snapshot, which the subscribers request
+	book.Apply(event)
+}
+
+// remove drops s and ends the upstream subscription when it was the last subscriber
+func (h *WSHub) remove(ctx context.Context, s *HubSubscriber) error {
+	h.subscribeMu.Lock()
+	defer h.subscribeMu.Unlock()
+
+	h.mu.Lock()
+	subscribers := removeSubscriber(h.subscribers[s.key], s)
+	found := len(subscribers) < len(h.subscribers[s.key])
+	if len(subscribers) == 0 {
+		delete(h.subscribers, s.key)
+		delete(h.books, s.key)
+	} else {
+		h.subscribers[s.key] = subscribers
+	}
+	h.mu.Unlock()
+
+	if !found {
+		return fmt.Errorf("subscriber of %s %s is not subscribed", s.key.Name, s.key.Pair)
+	}
+	if len(subscribers) == 0 {
+		return h.source.Unsubscribe(ctx, s.sub, s.pairs()...)
+	}
+	return nil
+}
+
+// Events returns the events of the subscribed channel, it is closed by Unsubscribe
+func (s *HubSubscriber) Events() <-chan WSEvent {
+	return s.events
+}
+
+// Dropped returns the number of events discarded because the subscriber did not keep up
+func (s *HubSubscriber) Dropped() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.dropped
+}
+
+// Unsubscribe stops the delivery and closes Events
+func (s *HubSubscriber) Unsubscribe(ctx context.Context) error {
+	var err error
+	s.once.Do(func() {
+		// Release a blocked delivery before taking the lock
+		close(s.done)
+		s.mu.Lock()
+		s.closed = true
+		close(s.events)
+		s.mu.Unlock()
+		err = s.hub.remove(ctx, s)
+	})
+	return err
+}
+
+// pairs returns the pairs of the upstream subscription
+func (s *HubSubscriber) pairs() []string {
+	if s.pair == "" {
+		return nil
+	}
+	return []string{s.pair}
+}
+
+// deliver passes event to the subscriber following its policy, it returns false when ctx is done
+func (s *HubSubscriber) deliver(ctx context.Context, event WSEvent) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return true
+	}
+
+	if s.policy == BackpressureBlock {
+		select {
+		case s.events <- event:
+		case <-s.done:
+		case <-ctx.Done():
+			return false
+		}
+		return true
+	}
+
+	for {
+		select {
+		case s.events <- event:
+			return true
+		default:
+		}
+		// Make room, the subscriber may have taken the event meanwhile
+		select {
+		case <-s.events:
+			s.dropped++
+		default:
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package krakenapi
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestWSHub(t *testing.T) {
+	server := newWSTestServer(t)
+	ws, conn := server.connect(t)
+	registry := NewRegistry(AssetPairMap{
+		XXBTZUSD: {Altname: "XBTUSD", WSName: "XBT/USD", Base: "XXBT", Quote: "ZUSD"},
+	}, AssetMap{"XXBT": {Altname: "XBT"}, "ZUSD": {Altname: "USD"}})
+	ws.WithRegistry(registry)
+	hub := NewWSHub(ws)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go hub.Run(ctx)
+
+	ticker := Subscription{Name: ChannelTicker}
+	latest, err := hub.Subscribe(ctx, ticker, XXBTZUSD, BackpressureCoalesce, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	recent, _ := hub.Subscribe(ctx, ticker, "XBTUSD", BackpressureDropOldest, 2)
+	all, _ := hub.Subscribe(ctx, ticker, "XBT/USD", BackpressureBlock, 1)
+
+	var req map[string]interface{}
+	if err := conn.ReadJSON(&req); err != nil {
+		t.Fatal(err)
+	}
+	if req["event"] != "subscribe" || req["pair"].([]interface{})[0] != "XBT/USD" {
+		t.Errorf("expected one upstream subscription, got %v", req)
+	}
+
+	for i := 1; i <= 3; i++ {
+		conn.WriteMessage(websocket.TextMessage, []byte(fmt.Sprintf(`[42,{"a":["552%d.40000",1,"1.000"],"b":["5525.10000",1,"1.000"],"c":["5525.10000","0.00398963"],"v":["2634.1","3591.1"],"p":["5631.4","5653.7"],"t":[11493,16267],"l":["5505.0","5505.0"],"h":["5783.0","5783.0"],"o":["5760.7","5763.4"]},"ticker","XBT/USD"]`, i)))
+	}
+	// Unrelated channels are not delivered
+	conn.WriteMessage(websocket.TextMessage, []byte(`{"event":"heartbeat"}`))
+
+	ask := func(s *HubSubscriber) string {
+		t.Helper()
+		select {
+		case event := <-s.Events():
+			return event.(*TickerEvent).Ticker.Ask[0].String()
+		case <-time.After(5 * time.Second):
+			t.Fatal("no hub event")
+			return ""
+		}
+	}
+	for i := 1; i <= 3; i++ {
+		if price := ask(all); price != fmt.Sprintf("552%d.4", i) {
+			t.Errorf("a blocking subscriber should get every event, got %s", price)
+		}
+	}
+	if price := ask(latest); price != "5523.4" || latest.Dropped() != 2 {
+		t.Errorf("a coalescing subscriber should get the latest event, got %s dropped %d", price, latest.Dropped())
+	}
+	if first, second := ask(recent), ask(recent); first != "5522.4" || second != "5523.4" || recent.Dropped() != 1 {
+		t.Errorf("a dropping subscriber should get the newest events, got %s %s", first, second)
+	}
+
+	for _, s := range []*HubSubscriber{latest, recent} {
+		if err := s.Unsubscribe(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, ok := <-latest.Events(); ok {
+		t.Error("Unsubscribe() should close the events")
+	}
+	if err := all.Unsubscribe(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := conn.ReadJSON(&req); err != nil {
+		t.Fatal(err)
+	}
+	if req["event"] != "unsubscribe" {
+		t.Errorf("the last subscriber should end the upstream subscription, got %v", req)
+	}
+	if err := all.Unsubscribe(ctx); err != nil {
+		t.Errorf("a second Unsubscribe() should do nothing, got %s", err)
+	}
+}
+
+func TestWSHubBookSnapshot(t *testing.T) {
+	server := newWSTestServer(t)
+	ws, conn := server.connect(t)
+	hub := NewWSHub(ws)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go hub.Run(ctx)
+
+	book := Subscription{Name: ChannelBook, Depth: 10}
+	if _, err := hub.Subscribe(ctx, book, "XBT/USD", BackpressureDropOldest, 10); err == nil {
+		t.Error("Subscribe() should reject dropping updates of a book")
+	}
+	first, err := hub.Subscribe(ctx, book, "XBT/USD", BackpressureBlock, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var req map[string]interface{}
+	if err := conn.ReadJSON(&req); err != nil {
+		t.Fatal(err)
+	}
+
+	next := func(s *HubSubscriber) *BookEvent {
+		t.Helper()
+		select {
+		case event := <-s.Events():
+			return event.(*BookEvent)
+		case <-time.After(5 * time.Second):
+			t.Fatal("no hub event")
+			return nil
+		}
+	}
+	conn.WriteMessage(websocket.TextMessage, []byte(`[0,{"as":[["5541.30000","2.50700000","1534614248.123678"]],"bs":[["5541.20000","1.52900000","1534614248.765567"]]},"book-10","XBT/USD"]`))
+	conn.WriteMessage(websocket.TextMessage, []byte(`[0,{"a":[["5541.40000","1.00000000","1534614248.456738"]]},"book-10","XBT/USD"]`))
+	if event := next(first); !event.Snapshot {
+		t.Fatalf("the first subscriber should get the snapshot, got %+v", event)
+	}
+	next(first)
+
+	late, err := hub.Subscribe(ctx, book, "XBT/USD", BackpressureBlock, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if event := next(late); !event.Snapshot || len(event.Asks) != 2 || len(event.Bids) != 1 || event.Asks[1].Price.String() != "5541.4" {
+		t.Errorf("a late subscriber should start with the current book, got %+v", event)
+	}
+	conn.WriteMessage(websocket.TextMessage, []byte(`[0,{"b":[["5541.10000","1.00000000","1534614248.456738"]]},"book-10","XBT/USD"]`))
+	for _, s := range []*HubSubscriber{first, late} {
+		if event := next(s); event.Snapshot || len(event.Bids) != 1 {
+			t.Errorf("every subscriber should get the following update, got %+v", event)
+		}
+	}
+}
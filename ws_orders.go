@@ -0,0 +1,233 @@
+package krakenapi
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// WSOrderError is an order request Kraken rejected on the WebSocket
+type WSOrderError struct {
+	// Request event, e.g. "addOrder"
+	Event string
+	// Kraken error, e.g. "EOrder:Insufficient funds"
+	Message string
+	ReqID   int64
+}
+
+func (e *WSOrderError) Error() string {
+	return fmt.Sprintf("websocket %s request %d failed: %s", e.Event, e.ReqID, e.Message)
+}
+
+// WSAddOrderResult is the answer to an accepted AddOrder
+type WSAddOrderResult struct {
+	TransactionID string `json:"txid"`
+	// Order description, e.g. "buy 0.01770000 XBTUSD @ limit 4000"
+	Description string `json:"descr"`
+}
+
+// WSEditOrderResult is the answer to an accepted EditOrder
+type WSEditOrderResult struct {
+	// ID of the order replacing the edited one
+	TransactionID         string `json:"txid"`
+	OriginalTransactionID string `json:"originaltxid"`
+	Description           string `json:"descr"`
+}
+
+// CancelAllOrdersAfterResult is the answer to CancelAllOrdersAfter
+type CancelAllOrdersAfterResult struct {
+	CurrentTime time.Time `json:"currentTime"`
+	// Time all orders are canceled unless the timer is extended, zero if it was disabled
+	TriggerTime time.Time `json:"triggerTime"`
+}
+
+// orderReply is the answer to an order request
+type orderReply struct {
+	Status       string `json:"status"`
+	ErrorMessage string `json:"errorMessage"`
+}
+
+// AddOrder places an order and waits for Kraken's answer, see KrakenAPI.PlaceOrder.
+// The WebSocket must be authenticated and use the API v1.
+func (ws *WebSocket) AddOrder(ctx context.Context, req *OrderRequest) (*WSAddOrderResult, error) {
+	params, err := req.params()
+	if err != nil {
+		return nil, err
+	}
+
+	msg := map[string]interface{}{}
+	for key, value := range params {
+		msg[key] = value
+	}
+	msg["pair"] = ws.pairName(req.Pair)
+	if req.ReduceOnly {
+		msg["reduce_only"] = true
+	}
+	// Accepting the trading agreement is only supported on REST
+	delete(msg, "trading_agreement")
+
+	result := &WSAddOrderResult{}
+	if err := ws.orderRequest(ctx, "addOrder", msg, result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// EditOrder replaces the open order txid with the price, volume, flags and user reference of req
+func (ws *WebSocket) EditOrder(ctx context.Context, txid string, req *OrderRequest) (*WSEditOrderResult, error) {
+	params, err := req.params()
+	if err != nil {
+		return nil, err
+	}
+
+	msg := map[string]interface{}{
+		"orderid": txid,
+		"pair":    ws.pairName(req.Pair),
+		"volume":  params["volume"],
+	}
+	for _, key := range []string{"price", "price2", "oflags", "validate"} {
+		if value, ok := params[key]; ok {
+			msg[key] = value
+		}
+	}
+	if value, ok := params["userref"]; ok {
+		msg["newuserref"] = value
+	}
+
+	result := &WSEditOrderResult{}
+	if err := ws.orderRequest(ctx, "editOrder", msg, result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// CancelOrder cancels the orders with the given transaction IDs or user references
+func (ws *WebSocket) CancelOrder(ctx context.Context, txids ...string) error {
+	return ws.orderRequest(ctx, "cancelOrder", map[string]interface{}{"txid": txids}, nil)
+}
+
+// CancelAll cancels all open orders and returns their number
+func (ws *WebSocket) CancelAll(ctx context.Context) (int, error) {
+	var result struct {
+		Count int `json:"count"`
+	}
+	if err := ws.orderRequest(ctx, "cancelAll", map[string]interface{}{}, &result); err != nil {
+		return 0, err
+	}
+	return result.Count, nil
+}
+
+// CancelAllOrdersAfter cancels all open orders after timeout unless it is called again
+// before, a timeout of 0 disables the timer
+func (ws *WebSocket) CancelAllOrdersAfter(ctx context.Context, timeout time.Duration) (*CancelAllOrdersAfterResult, error) {
+	msg := map[string]interface{}{"timeout": int(timeout / time.Second)}
+	var reply struct {
+		CurrentTime string `json:"currentTime"`
+		TriggerTime string `json:"triggerTime"`
+	}
+	if err := ws.orderRequest(ctx, "cancelAllOrdersAfter", msg, &reply); err != nil {
+		return nil, err
+	}
+
+	result := &CancelAllOrdersAfterResult{}
+	for _, field := range []struct {
+		text  string
+		value *time.Time
+	}{{reply.CurrentTime, &result.CurrentTime}, {reply.TriggerTime, &result.TriggerTime}} {
+		if field.text == "" || field.text == "0" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, field.text)
+		if err != nil {
+			return nil, fmt.Errorf("Could not decode cancelAllOrdersAfter time %s: %s", field.text, err)
+		}
+		*field.value = t
+	}
+	return result, nil
+}
+
+// orderRequest sends an order request and decodes the answer with the same reqid into result
+func (ws *WebSocket) orderRequest(ctx context.Context, event string, msg map[string]interface{}, result interface{}) error {
+	if ws.version != WSVersion1 {
+		return fmt.Errorf("%s is only supported on the websocket API v1", event)
+	}
+	token, err := ws.authToken()
+	if err != nil {
+		return err
+	}
+
+	reqID := ws.nextReqID()
+	msg["event"] = event
+	msg["token"] = token
+	msg["reqid"] = reqID
+
+	reply := make(chan []byte, 1)
+	ws.mu.Lock()
+	ws.pending[reqID] = reply
+	ws.mu.Unlock()
+	defer func() {
+		ws.mu.Lock()
+		delete(ws.pending, reqID)
+		ws.mu.Unlock()
+	}()
+
+	if err := ws.send(ctx, msg); err != nil {
+		return err
+	}
+
+	var data []byte
+	select {
+	case data = <-reply:
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-ws.done:
+		if err := ws.Err(); err != nil {
+			return err
+		}
+		return ErrWebSocketClosed
+	}
+
+	var answer orderReply
+	if err := json.Unmarshal(data, &answer); err != nil {
+		return fmt.Errorf("Could not decode %s answer: %s", event, err)
+	}
+	if answer.Status != "ok" {
+		return &WSOrderError{Event: event, Message: answer.ErrorMessage, ReqID: reqID}
+	}
+	if result == nil {
+		return nil
+	}
+	if err := json.Unmarshal(data, result); err != nil {
+		return fmt.Errorf("Could not decode %s answer: %s", event, err)
+	}
+	return nil
+}
+
+// answer passes the answer to an order request to the waiting call, it returns false
+// for all other messages
+func (ws *WebSocket) answer(data []byte) bool {
+	if len(data) == 0 || data[0] != '{' {
+		return false
+	}
+	var head struct {
+		ReqID json.Number `json:"reqid"`
+	}
+	if json.Unmarshal(data, &head) != nil || head.ReqID == "" {
+		return false
+	}
+	reqID, err := strconv.ParseInt(head.ReqID.String(), 10, 64)
+	if err != nil {
+		return false
+	}
+
+	ws.mu.Lock()
+	reply, ok := ws.pending[reqID]
+	delete(ws.pending, reqID)
+	ws.mu.Unlock()
+	if ok {
+		reply <- data
+	}
+	return ok
+}
@@ -0,0 +1,113 @@
+package krakenapi
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestWebSocketOrders(t *testing.T) {
+	server := newWSTestServer(t)
+	ws, conn := server.connect(t)
+	ctx := context.Background()
+
+	req := NewLimitOrder("XBT/USD", SideBuy, MustDecimal("0.0177"), MustDecimal("4000"))
+	if _, err := ws.AddOrder(ctx, req); err == nil {
+		t.Error("AddOrder() should require a token")
+	}
+	api := newTestAPI(`{"error":[],"result":{"token":"secret-token","expires":900}}`)
+	if err := ws.Authenticate(ctx, api); err != nil {
+		t.Fatal(err)
+	}
+
+	requests := make(chan map[string]interface{}, 10)
+	go func() {
+		for {
+			var msg map[string]interface{}
+			if err := conn.ReadJSON(&msg); err != nil {
+				return
+			}
+			requests <- msg
+			reqID := int64(msg["reqid"].(float64))
+			var answer string
+			switch msg["event"] {
+			case "addOrder":
+				if msg["price"] == "1" {
+					answer = `{"errorMessage":"EOrder:Insufficient funds","event":"addOrderStatus","status":"error","reqid":%d}`
+				} else {
+					answer = `{"descr":"buy 0.01770000 XBTUSD @ limit 4000","event":"addOrderStatus","status":"ok","txid":"ONPNXH-KMKMU-F4MR5V","reqid":%d}`
+				}
+			case "editOrder":
+				answer = `{"descr":"order edited price = 9000.00000000","event":"editOrderStatus","originaltxid":"O65KZW-J4AW3-VFS74A","reqid":%d,"status":"ok","txid":"OTI672-HJFAO-XOIPPK"}`
+			case "cancelAll":
+				answer = `{"count":2,"event":"cancelAllStatus","status":"ok","reqid":%d}`
+			case "cancelAllOrdersAfter":
+				answer = `{"currentTime":"2020-12-21T09:37:09Z","event":"cancelAllOrdersAfterStatus","reqid":%d,"status":"ok","triggerTime":"2020-12-21T09:38:09Z"}`
+			default:
+				// Leave cancelOrder unanswered
+				continue
+			}
+			// Unrelated traffic between request and answer
+			conn.WriteMessage(websocket.TextMessage, []byte(`{"event":"heartbeat"}`))
+			conn.WriteMessage(websocket.TextMessage, []byte(fmt.Sprintf(answer, reqID)))
+		}
+	}()
+
+	added, err := ws.AddOrder(ctx, req)
+	if err != nil {
+		t.Fatalf("AddOrder() should not return an error, got %s", err)
+	}
+	if added.TransactionID != "ONPNXH-KMKMU-F4MR5V" {
+		t.Errorf("AddOrder() returned wrong result, got %+v", added)
+	}
+	sent := <-requests
+	if sent["token"] != "secret-token" || sent["pair"] != "XBT/USD" || sent["ordertype"] != "limit" || sent["volume"] != "0.0177" || sent["price"] != "4000" {
+		t.Errorf("AddOrder() sent wrong request, got %v", sent)
+	}
+
+	req.Price = MustDecimal("1")
+	_, err = ws.AddOrder(ctx, req)
+	if orderErr, ok := err.(*WSOrderError); !ok || orderErr.Message != "EOrder:Insufficient funds" || orderErr.Event != "addOrder" {
+		t.Errorf("AddOrder() should return a WSOrderError, got %v", err)
+	}
+	<-requests
+
+	req.Price = MustDecimal("9000")
+	req.UserRef = 7
+	edited, err := ws.EditOrder(ctx, "O65KZW-J4AW3-VFS74A", req)
+	if err != nil || edited.TransactionID != "OTI672-HJFAO-XOIPPK" || edited.OriginalTransactionID != "O65KZW-J4AW3-VFS74A" {
+		t.Errorf("EditOrder() returned wrong result, got %+v %v", edited, err)
+	}
+	if sent := <-requests; sent["orderid"] != "O65KZW-J4AW3-VFS74A" || sent["newuserref"] != "7" || sent["ordertype"] != nil {
+		t.Errorf("EditOrder() sent wrong request, got %v", sent)
+	}
+
+	if count, err := ws.CancelAll(ctx); err != nil || count != 2 {
+		t.Errorf("CancelAll() should return the count, got %d %v", count, err)
+	}
+	<-requests
+
+	after, err := ws.CancelAllOrdersAfter(ctx, time.Minute)
+	if err != nil || after.TriggerTime.Sub(after.CurrentTime) != time.Minute {
+		t.Errorf("CancelAllOrdersAfter() returned wrong times, got %+v %v", after, err)
+	}
+	if sent := <-requests; sent["timeout"] != 60.0 {
+		t.Errorf("CancelAllOrdersAfter() sent wrong timeout, got %v", sent)
+	}
+
+	timeout, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	if err := ws.CancelOrder(timeout, "OGTT3Y-C6I3P-XRI6HX"); err != context.DeadlineExceeded {
+		t.Errorf("CancelOrder() should respect the context, got %v", err)
+	}
+
+	// Answers are not delivered as events
+	for i := 0; i < 5; i++ {
+		if _, ok := nextEvent(t, ws).(*HeartbeatEvent); !ok {
+			t.Error("only heartbeats should be delivered as events")
+		}
+	}
+}
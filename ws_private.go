@@ -0,0 +1,183 @@
+package krakenapi
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+)
+
+// Private WebSocket channel names
+const (
+	ChannelOwnTrades  = "ownTrades"
+	ChannelOpenOrders = "openOrders"
+)
+
+// OwnTradesEvent carries trades of the account, the first event after subscribing
+// holds the most recent trades
+type OwnTradesEvent struct {
+	WSChannel
+	Sequence int64
+	Trades   []TradeHistoryInfo
+}
+
+// OpenOrdersEvent carries the open orders of the account after subscribing and then
+// their changes. A change only sets the fields Kraken sent, e.g. Status on a status
+// change or VolumeExecuted, Cost, Fee and Price, the average price, on a fill.
+type OpenOrdersEvent struct {
+	WSChannel
+	Sequence int64
+	Orders   []Order
+}
+
+// SequenceGapEvent reports private channel messages that were not received
+type SequenceGapEvent struct {
+	WSChannel
+	// Sequence number of the first missing message
+	Expected int64
+	// Sequence number of the received message
+	Received int64
+}
+
+func (e *OwnTradesEvent) sequence() int64 {
+	return e.Sequence
+}
+
+func (e *OpenOrdersEvent) sequence() int64 {
+	return e.Sequence
+}
+
+// sequenced is an event of a private channel
+type sequenced interface {
+	WSEvent
+	sequence() int64
+}
+
+// Authenticate fetches a WebSocket token with api, which is then used for the
+// subscriptions to private channels and for orders. Use it with WSPrivateURL.
+func (ws *WebSocket) Authenticate(ctx context.Context, api *KrakenAPI) error {
+	resp, err := api.GetWebSocketsTokenContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	ws.token = resp.Token
+	return nil
+}
+
+// authToken returns the token fetched by Authenticate
+func (ws *WebSocket) authToken() (string, error) {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	if ws.token == "" {
+		return "", fmt.Errorf("websocket %s is not authenticated", ws.url)
+	}
+	return ws.token, nil
+}
+
+// checkSequence returns a SequenceGapEvent when messages of the event's channel were skipped.
+// Kraken numbers the messages of every subscription from 1.
+func (ws *WebSocket) checkSequence(event sequenced) WSEvent {
+	channel := event.Channel()
+	last, seen := ws.sequences[channel]
+	ws.sequences[channel] = event.sequence()
+	if snapshot, ok := event.(interface{ isSnapshot() bool }); ok && snapshot.isSnapshot() {
+		// A snapshot starts the sequence again
+		return nil
+	}
+	if !seen || event.sequence() == 1 || event.sequence() <= last+1 {
+		return nil
+	}
+	return &SequenceGapEvent{WSChannel: event.Channel(), Expected: last + 1, Received: event.sequence()}
+}
+
+// wsTimeFields are the fields the private channels send as strings while the REST
+// API sends them as numbers
+var wsTimeFields = []string{"time", "opentm", "starttm", "expiretm", "closetm", "lastupdated"}
+
+// wsFieldNames maps private channel fields to the REST fields of the same value
+var wsFieldNames = map[string]string{"avg_price": "price"}
+
+// decodeWSPrivate decodes array(array(object(<id>: object)), <channelName>, object(sequence))
+func decodeWSPrivate(fields []json.RawMessage) ([]WSEvent, error) {
+	var name string
+	if err := json.Unmarshal(fields[1], &name); err != nil {
+		return nil, fmt.Errorf("channel name: %s", err)
+	}
+	var seq struct {
+		Sequence int64 `json:"sequence"`
+	}
+	if err := json.Unmarshal(fields[2], &seq); err != nil {
+		return nil, fmt.Errorf("sequence: %s", err)
+	}
+
+	var entries []map[string]map[string]interface{}
+	if err := json.Unmarshal(fields[0], &entries); err != nil {
+		return nil, err
+	}
+
+	channel := WSChannel{Name: name}
+	switch name {
+	case ChannelOwnTrades:
+		event := &OwnTradesEvent{WSChannel: channel, Sequence: seq.Sequence}
+		err := eachWSRecord(entries, func(id string, data []byte) error {
+			var trade TradeHistoryInfo
+			if err := json.Unmarshal(data, &trade); err != nil {
+				return err
+			}
+			trade.TradeID = id
+			event.Trades = append(event.Trades, trade)
+			return nil
+		})
+		return []WSEvent{event}, err
+	case ChannelOpenOrders:
+		event := &OpenOrdersEvent{WSChannel: channel, Sequence: seq.Sequence}
+		err := eachWSRecord(entries, func(id string, data []byte) error {
+			var order Order
+			if err := json.Unmarshal(data, &order); err != nil {
+				return err
+			}
+			order.TransactionID = id
+			event.Orders = append(event.Orders, order)
+			return nil
+		})
+		return []WSEvent{event}, err
+	}
+	return nil, fmt.Errorf("unknown channel %s", name)
+}
+
+// eachWSRecord converts the records of a private channel to the REST format and passes them to fn
+func eachWSRecord(entries []map[string]map[string]interface{}, fn func(id string, data []byte) error) error {
+	for _, entry := range entries {
+		for id, record := range entry {
+			for _, field := range wsTimeFields {
+				text, ok := record[field].(string)
+				if !ok {
+					continue
+				}
+				value, err := strconv.ParseFloat(text, 64)
+				if err != nil {
+					return fmt.Errorf("%s %s: %s", id, field, err)
+				}
+				record[field] = value
+			}
+			for from, to := range wsFieldNames {
+				if value, ok := record[from]; ok {
+					record[to] = value
+					delete(record, from)
+				}
+			}
+
+			data, err := json.Marshal(record)
+			if err != nil {
+				return err
+			}
+			if err := fn(id, data); err != nil {
+				return fmt.Errorf("%s: %s", id, err)
+			}
+		}
+	}
+	return nil
+}
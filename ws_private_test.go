@@ -0,0 +1,68 @@
+package krakenapi
+
+import (
+	"context"
+	"testing"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestWebSocketPrivateFeeds(t *testing.T) {
+	server := newWSTestServer(t)
+	ws, conn := server.connect(t)
+	ctx := context.Background()
+
+	if err := ws.Subscribe(ctx, Subscription{Name: ChannelOwnTrades}); err == nil {
+		t.Error("Subscribe() should require a token for private channels")
+	}
+	api := newTestAPI(`{"error":[],"result":{"token":"1Dwc4lzSwNWOAwkMdqhssNNFhs1ed606d1WcF3XfEMw","expires":900}}`)
+	if err := ws.Authenticate(ctx, api); err != nil {
+		t.Fatal(err)
+	}
+	if err := ws.Subscribe(ctx, Subscription{Name: ChannelOwnTrades}); err != nil {
+		t.Fatal(err)
+	}
+	var req map[string]interface{}
+	if err := conn.ReadJSON(&req); err != nil {
+		t.Fatal(err)
+	}
+	if req["subscription"].(map[string]interface{})["token"] != "1Dwc4lzSwNWOAwkMdqhssNNFhs1ed606d1WcF3XfEMw" {
+		t.Errorf("Subscribe() should send the token, got %v", req)
+	}
+
+	messages := []string{
+		`[[{"TDLH43-DVQXD-2KHVYY":{"cost":"1000000.00000","fee":"1600.00000","margin":"0.00000","ordertxid":"TDLH43-DVQXD-2KHVYY","ordertype":"limit","pair":"XBT/EUR","postxid":"OGTT3Y-C6I3P-XRI6HX","price":"100000.00000","time":"1560516023.070651","type":"sell","vol":"1000000000.00000000"}}],"ownTrades",{"sequence":1}]`,
+		`[[{"OGTT3Y-C6I3P-XRI6HX":{"avg_price":"34.50000","cost":"0.00000","descr":{"close":"","leverage":"0:1","order":"sell 10.00345345 XBT/EUR @ limit 34.50000","ordertype":"limit","pair":"XBT/EUR","price":"34.50000","price2":"0.00000","type":"sell"},"expiretm":"0.000000","fee":"0.00000","limitprice":"34.50000","misc":"","oflags":"fcib","opentm":"0.000000","refid":"OKIVMP-5GVZN-Z2D2UA","starttm":"0.000000","status":"open","stopprice":"0.000000","userref":0,"vol":"10.00345345","vol_exec":"0.00000000"}}],"openOrders",{"sequence":1}]`,
+		`[[{"OGTT3Y-C6I3P-XRI6HX":{"status":"open","vol_exec":"2.00000000","cost":"69.00000","fee":"0.11040","avg_price":"34.50000"}}],"openOrders",{"sequence":2}]`,
+		`[[{"OGTT3Y-C6I3P-XRI6HX":{"status":"closed","lastupdated":"1560516030.123456"}}],"openOrders",{"sequence":5}]`,
+	}
+	for _, msg := range messages {
+		conn.WriteMessage(websocket.TextMessage, []byte(msg))
+	}
+
+	trades, ok := nextEvent(t, ws).(*OwnTradesEvent)
+	if !ok || trades.Sequence != 1 || len(trades.Trades) != 1 {
+		t.Fatalf("expected own trades, got %+v", trades)
+	}
+	if trade := trades.Trades[0]; trade.TradeID != "TDLH43-DVQXD-2KHVYY" || trade.Time != 1560516023.070651 || trade.Type != SideSell || trade.Fee.String() != "1600" {
+		t.Errorf("own trade decoded wrong values, got %+v", trade)
+	}
+
+	snapshot, ok := nextEvent(t, ws).(*OpenOrdersEvent)
+	if !ok || len(snapshot.Orders) != 1 || snapshot.Orders[0].Description.OrderType != OTLimit || snapshot.Orders[0].Volume.String() != "10.00345345" {
+		t.Errorf("expected open orders, got %+v", snapshot)
+	}
+	fill, ok := nextEvent(t, ws).(*OpenOrdersEvent)
+	if !ok || fill.Orders[0].TransactionID != "OGTT3Y-C6I3P-XRI6HX" || fill.Orders[0].VolumeExecuted.String() != "2" || fill.Orders[0].Price.String() != "34.5" {
+		t.Errorf("expected partial fill, got %+v", fill)
+	}
+
+	gap, ok := nextEvent(t, ws).(*SequenceGapEvent)
+	if !ok || gap.Name != ChannelOpenOrders || gap.Expected != 3 || gap.Received != 5 {
+		t.Errorf("expected sequence gap, got %+v", gap)
+	}
+	closed, ok := nextEvent(t, ws).(*OpenOrdersEvent)
+	if !ok || closed.Orders[0].Status != OrderStatusClosed {
+		t.Errorf("expected status change, got %+v", closed)
+	}
+}
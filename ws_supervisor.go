@@ -0,0 +1,514 @@
+package krakenapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Default timings of a WSSupervisor
+const (
+	DefaultStaleTimeout = 10 * time.Second
+	// Kraken Futures sends fewer heartbeats than the spot API
+	DefaultFuturesStaleTimeout = 90 * time.Second
+	DefaultMinBackoff          = time.Second
+	DefaultMaxBackoff          = time.Minute
+)
+
+// errStale is the reason a connection without traffic is dropped
+var errStale = errors.New("websocket connection is stale")
+
+// ConnectionEvent reports a WSSupervisor connecting or losing its connection
+type ConnectionEvent struct {
+	WSChannel
+	Connected bool
+	// Reason the connection was lost or could not be established
+	Err error
+}
+
+// GapEvent marks a part of a feed that was missed while disconnected and could not be
+// recovered through the REST API
+type GapEvent struct {
+	WSChannel
+	From time.Time
+	To   time.Time
+	Err  error
+}
+
+// wsSubscription is a subscription restored on every connection
+type wsSubscription struct {
+	sub   Subscription
+	pairs []string
+}
+
+// tradeCursor is the last trade delivered for a pair
+type tradeCursor struct {
+	time time.Time
+	id   int64
+	// Number of trades delivered at time, keyed by tradeKey
+	seen map[string]int
+}
+
+// candleCursor is the last candle delivered for a pair and interval
+type candleCursor struct {
+	channel  WSChannel
+	interval int
+	start    time.Time
+}
+
+// WSSupervisor keeps a WebSocket connected. It reconnects with exponential backoff
+// when the connection is lost or no message, including heartbeats, arrived for the
+// stale timeout, restores all subscriptions and fetches the trades and candles missed
+// in between from the REST API. Trades already delivered are not repeated. What cannot
+// be recovered is reported by a GapEvent. Kraken Futures connections are only reconnected
+// and resubscribed, their feeds start again with a snapshot.
+type WSSupervisor struct {
+	connect    func() *WebSocket
+	names      *WebSocket
+	api        *KrakenAPI
+	futures    *FuturesAPI
+	stale      time.Duration
+	minBackoff time.Duration
+	maxBackoff time.Duration
+	events     chan WSEvent
+
+	mu   sync.Mutex
+	ws   *WebSocket
+	subs []wsSubscription
+
+	// state of Run
+	trades map[string]*tradeCursor
+	// trades delivered by the gap filling which the live feed may repeat
+	overlaps map[string]*tradeCursor
+	candles  map[string]candleCursor
+}
+
+// NewWSSupervisor creates a WSSupervisor which opens its connections with connect, e.g.
+// func() *WebSocket { return NewWebSocket(WSPublicURL) }. api is used to fill gaps and
+// to authenticate private subscriptions, it can be nil for public feeds without gap filling.
+func NewWSSupervisor(connect func() *WebSocket, api *KrakenAPI) *WSSupervisor {
+	names := connect()
+	stale := DefaultStaleTimeout
+	if names.version == WSVersionFutures {
+		stale = DefaultFuturesStaleTimeout
+	}
+	return &WSSupervisor{
+		connect:    connect,
+		names:      names,
+		api:        api,
+		stale:      stale,
+		minBackoff: DefaultMinBackoff,
+		maxBackoff: DefaultMaxBackoff,
+		events:     make(chan WSEvent, wsEventBuffer),
+		trades:     map[string]*tradeCursor{},
+		overlaps:   map[string]*tradeCursor{},
+		candles:    map[string]candleCursor{},
+	}
+}
+
+// WithStaleTimeout sets how long a connection may stay silent before it is replaced
+func (s *WSSupervisor) WithStaleTimeout(timeout time.Duration) *WSSupervisor {
+	s.stale = timeout
+	return s
+}
+
+// WithFuturesAPI sets the client used to authenticate the private feeds of Kraken Futures connections
+func (s *WSSupervisor) WithFuturesAPI(api *FuturesAPI) *WSSupervisor {
+	s.futures = api
+	return s
+}
+
+// WithBackoff sets the first and the longest pause between reconnects
+func (s *WSSupervisor) WithBackoff(min time.Duration, max time.Duration) *WSSupervisor {
+	s.minBackoff, s.maxBackoff = min, max
+	return s
+}
+
+// Events returns the channel of events of all connections, it is closed when Run returns
+func (s *WSSupervisor) Events() <-chan WSEvent {
+	return s.events
+}
+
+// Subscribe subscribes to a channel now, if connected, and on every reconnect.
+// Leave the token of private channels empty, a new one is fetched for every connection.
+func (s *WSSupervisor) Subscribe(ctx context.Context, sub Subscription, pairs ...string) error {
+	s.mu.Lock()
+	s.subs = append(s.subs, wsSubscription{sub: sub, pairs: pairs})
+	ws := s.ws
+	s.mu.Unlock()
+
+	if ws == nil {
+		return nil
+	}
+	return ws.Subscribe(ctx, sub, pairs...)
+}
+
+// Unsubscribe ends a subscription made with Subscribe
+func (s *WSSupervisor) Unsubscribe(ctx context.Context, sub Subscription, pairs ...string) error {
+	s.mu.Lock()
+	for i := 0; i < len(s.subs); i++ {
+		if s.subs[i].sub == sub && strings.Join(s.subs[i].pairs, ",") == strings.Join(pairs, ",") {
+			s.subs = append(s.subs[:i], s.subs[i+1:]...)
+			i--
+		}
+	}
+	ws := s.ws
+	s.mu.Unlock()
+
+	if ws == nil {
+		return nil
+	}
+	return ws.Unsubscribe(ctx, sub, pairs...)
+}
+
+// channelKey returns the channel of the events of a subscription to pair
+func (s *WSSupervisor) channelKey(sub Subscription, pair string) WSChannel {
+	return s.names.channelKey(sub, pair)
+}
+
+// Run keeps the connection up until ctx is done
+func (s *WSSupervisor) Run(ctx context.Context) error {
+	defer close(s.events)
+
+	backoff := s.minBackoff
+	for reconnect := false; ; reconnect = true {
+		connected, err := s.session(ctx, reconnect)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if !s.emit(ctx, &ConnectionEvent{Err: err}) {
+			return ctx.Err()
+		}
+
+		if connected {
+			backoff = s.minBackoff
+		}
+		if err := sleepContext(ctx, backoff); err != nil {
+			return err
+		}
+		if backoff *= 2; backoff > s.maxBackoff {
+			backoff = s.maxBackoff
+		}
+	}
+}
+
+// session runs one connection, it reports whether the connection was established
+func (s *WSSupervisor) session(ctx context.Context, reconnect bool) (bool, error) {
+	ws := s.connect()
+	if err := ws.Connect(ctx); err != nil {
+		return false, err
+	}
+	defer ws.Close()
+
+	s.mu.Lock()
+	subs := append([]wsSubscription(nil), s.subs...)
+	s.mu.Unlock()
+
+	for _, entry := range subs {
+		if entry.sub.Token == "" && isStringInSlice(entry.sub.Name, privateChannels) {
+			if err := s.authenticate(ctx, ws, entry.sub.Name); err != nil {
+				return false, err
+			}
+			break
+		}
+	}
+
+	s.mu.Lock()
+	s.ws = ws
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		s.ws = nil
+		s.mu.Unlock()
+	}()
+
+	// Subscribe before filling gaps, so the live feed starts before the REST data ends
+	for _, entry := range subs {
+		if err := ws.Subscribe(ctx, entry.sub, entry.pairs...); err != nil {
+			return false, err
+		}
+	}
+	if !s.emit(ctx, &ConnectionEvent{Connected: true}) {
+		return true, ctx.Err()
+	}
+
+	// Fill the gaps while the live events are queued, they are delivered after the REST data
+	var queue []WSEvent
+	var filled chan bool
+	if reconnect {
+		filled = make(chan bool, 1)
+		fillCtx, cancel := context.WithCancel(ctx)
+		go func() { filled <- s.fillGaps(fillCtx) }()
+		defer func() {
+			cancel()
+			if filled != nil {
+				<-filled
+			}
+		}()
+	}
+
+	timer := time.NewTimer(s.stale)
+	defer timer.Stop()
+	for {
+		select {
+		case event, ok := <-ws.Events():
+			if !ok {
+				if err := ws.Err(); err != nil {
+					return true, err
+				}
+				return true, ErrWebSocketClosed
+			}
+			if !timer.Stop() {
+				<-timer.C
+			}
+			timer.Reset(s.stale)
+
+			if filled != nil {
+				queue = append(queue, event)
+				continue
+			}
+			if event = s.track(event); event != nil && !s.emit(ctx, event) {
+				return true, ctx.Err()
+			}
+		case ok := <-filled:
+			filled = nil
+			if !ok {
+				return true, ctx.Err()
+			}
+			for _, event := range queue {
+				if event = s.track(event); event != nil && !s.emit(ctx, event) {
+					return true, ctx.Err()
+				}
+			}
+			queue = nil
+		case <-timer.C:
+			return true, errStale
+		case <-ctx.Done():
+			return true, ctx.Err()
+		}
+	}
+}
+
+// authenticate prepares ws for the private channel name
+func (s *WSSupervisor) authenticate(ctx context.Context, ws *WebSocket, name string) error {
+	if ws.version == WSVersionFutures {
+		if s.futures == nil {
+			return fmt.Errorf("private feed %s needs a FuturesAPI to authenticate", name)
+		}
+		return ws.AuthenticateFutures(ctx, s.futures)
+	}
+	if s.api == nil {
+		return fmt.Errorf("private channel %s needs a KrakenAPI to authenticate", name)
+	}
+	return ws.Authenticate(ctx, s.api)
+}
+
+// emit passes event to the consumer, it returns false when ctx is done
+func (s *WSSupervisor) emit(ctx context.Context, event WSEvent) bool {
+	select {
+	case s.events <- event:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// track records the last trade and candle of every feed and drops the live trades
+// which were already delivered by the gap filling
+func (s *WSSupervisor) track(event WSEvent) WSEvent {
+	switch e := event.(type) {
+	case *TradeEvent:
+		trades := e.Trades
+		if overlap := s.overlaps[e.Pair]; overlap != nil {
+			var passed bool
+			trades, passed = overlap.filter(trades)
+			if passed {
+				delete(s.overlaps, e.Pair)
+			}
+		}
+		s.tradeCursor(e.Pair).advance(trades)
+		if len(trades) == 0 {
+			return nil
+		}
+		if len(trades) < len(e.Trades) {
+			filtered := *e
+			filtered.Trades = trades
+			return &filtered
+		}
+	case *OHLCEvent:
+		key := e.Name + "|" + e.Pair
+		if last, found := s.candles[key]; !found || !e.OHLC.Time.Before(last.start) {
+			s.candles[key] = candleCursor{channel: e.WSChannel, interval: e.Interval, start: e.OHLC.Time}
+		}
+	}
+	return event
+}
+
+// tradeCursor returns the cursor of pair
+func (s *WSSupervisor) tradeCursor(pair string) *tradeCursor {
+	cursor, found := s.trades[pair]
+	if !found {
+		cursor = &tradeCursor{seen: map[string]int{}}
+		s.trades[pair] = cursor
+	}
+	return cursor
+}
+
+// tradeKey identifies a trade among the trades of one timestamp when Kraken sent no trade ID
+func tradeKey(trade TradeInfo) string {
+	return fmt.Sprintf("%s|%s|%t|%t", trade.Price, trade.Volume, trade.Buy, trade.Market)
+}
+
+// advance moves the cursor over trades, which must be newer than the cursor
+func (c *tradeCursor) advance(trades []TradeInfo) {
+	for _, trade := range trades {
+		if trade.Timestamp.After(c.time) {
+			c.time = trade.Timestamp
+			c.seen = map[string]int{}
+		}
+		if trade.TradeID > 0 {
+			c.id = trade.TradeID
+		}
+		c.seen[tradeKey(trade)]++
+	}
+}
+
+// clone returns a copy of the cursor
+func (c *tradeCursor) clone() *tradeCursor {
+	clone := &tradeCursor{time: c.time, id: c.id, seen: map[string]int{}}
+	for key, count := range c.seen {
+		clone.seen[key] = count
+	}
+	return clone
+}
+
+// filter drops the trades the cursor was advanced over. Trades are compared by ID when
+// both have one, and otherwise by time and, at the cursor's time, by tradeKey. Every
+// dropped trade at the cursor's time is taken off seen. passed reports whether a trade
+// after the cursor was found, all following trades are new then.
+func (c *tradeCursor) filter(trades []TradeInfo) (fresh []TradeInfo, passed bool) {
+	for _, trade := range trades {
+		switch {
+		case passed:
+		case trade.TradeID > 0 && c.id > 0:
+			if trade.TradeID <= c.id {
+				continue
+			}
+			passed = true
+		case trade.Timestamp.Before(c.time):
+			continue
+		case trade.Timestamp.After(c.time):
+			passed = true
+		default:
+			if key := tradeKey(trade); c.seen[key] > 0 {
+				c.seen[key]--
+				continue
+			}
+		}
+		fresh = append(fresh, trade)
+	}
+	return fresh, passed
+}
+
+// restPairName returns the REST name of a WebSocket pair, e.g. "XBTUSD" for "BTC/USD"
+func restPairName(pair string) string {
+	parts := strings.Split(pair, "/")
+	for i, part := range parts {
+		if altname, found := displayNames[part]; found {
+			parts[i] = altname
+		}
+	}
+	return strings.Join(parts, "")
+}
+
+// fillGaps delivers the trades and candles missed while disconnected, it returns false when ctx is done
+func (s *WSSupervisor) fillGaps(ctx context.Context) bool {
+	now := time.Now()
+	for pair, last := range s.trades {
+		channel := WSChannel{Name: ChannelTrade, Pair: pair}
+		if s.api == nil {
+			if !s.emit(ctx, &GapEvent{WSChannel: channel, From: last.time, To: now, Err: errors.New("no KrakenAPI to recover trades")}) {
+				return false
+			}
+			continue
+		}
+
+		// Start before the last trade, the trades sharing its time are filtered by key
+		delivered := last.clone()
+		backfill := s.api.BackfillTrades(ctx, restPairName(pair), last.time.Add(-time.Nanosecond), time.Time{})
+		var trades []TradeInfo
+		flush := func() bool {
+			fresh, _ := delivered.filter(trades)
+			trades = nil
+			last.advance(fresh)
+			return len(fresh) == 0 || s.emit(ctx, &TradeEvent{WSChannel: channel, Trades: fresh})
+		}
+		for backfill.Next() {
+			trades = append(trades, backfill.Trade())
+			if len(trades) == tradesPageSize && !flush() {
+				return false
+			}
+		}
+		if !flush() {
+			return false
+		}
+		s.overlaps[pair] = last.clone()
+		if err := backfill.Err(); err != nil {
+			if !s.emit(ctx, &GapEvent{WSChannel: channel, From: last.time, To: now, Err: err}) {
+				return false
+			}
+		}
+	}
+
+	for _, last := range s.candles {
+		if !s.fillCandles(ctx, last, now) {
+			return false
+		}
+	}
+	return true
+}
+
+// fillCandles delivers the candles from the last delivered one on, it returns false when ctx is done
+func (s *WSSupervisor) fillCandles(ctx context.Context, last candleCursor, now time.Time) bool {
+	length := time.Duration(last.interval) * time.Minute
+	if s.api == nil {
+		return s.emit(ctx, &GapEvent{WSChannel: last.channel, From: last.start, To: now, Err: errors.New("no KrakenAPI to recover candles")})
+	}
+
+	resp, err := s.api.OHLCSinceContext(ctx, restPairName(last.channel.Pair), strconv.Itoa(last.interval), last.start.Unix()-1)
+	if err != nil {
+		return s.emit(ctx, &GapEvent{WSChannel: last.channel, From: last.start, To: now, Err: err})
+	}
+	// missing reports the candles Kraken did not return
+	missing := func(from time.Time, to time.Time) *GapEvent {
+		err := fmt.Errorf("Kraken returned no candles between %s and %s", from.UTC(), to.UTC())
+		return &GapEvent{WSChannel: last.channel, From: from, To: to, Err: err}
+	}
+
+	next := last.start
+	for _, candle := range resp.OHLC {
+		if candle.Time.Before(last.start) {
+			continue
+		}
+		// Kraken only keeps the last 720 candles
+		if candle.Time.After(next) && !s.emit(ctx, missing(next, candle.Time)) {
+			return false
+		}
+
+		event := &OHLCEvent{WSChannel: last.channel, Interval: last.interval, EndTime: candle.Time.Add(length), OHLC: *candle}
+		if !s.emit(ctx, s.track(event)) {
+			return false
+		}
+		next = candle.Time.Add(length)
+	}
+
+	// The candles must reach the candle open at the reconnect
+	if !next.After(now) {
+		return s.emit(ctx, missing(next, now))
+	}
+	return true
+}
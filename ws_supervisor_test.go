@@ -0,0 +1,168 @@
+package krakenapi
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// nextSupervisorEvent returns the next event of s
+func nextSupervisorEvent(t *testing.T, s *WSSupervisor) WSEvent {
+	t.Helper()
+	select {
+	case event, ok := <-s.Events():
+		if !ok {
+			t.Fatal("supervisor events closed")
+		}
+		return event
+	case <-time.After(5 * time.Second):
+		t.Fatal("no supervisor event")
+		return nil
+	}
+}
+
+// expectSubscribe reads a subscribe request from conn
+func expectSubscribe(t *testing.T, conn *websocket.Conn, name string) {
+	t.Helper()
+	var req map[string]interface{}
+	if err := conn.ReadJSON(&req); err != nil {
+		t.Fatal(err)
+	}
+	if sub := req["subscription"].(map[string]interface{}); req["event"] != "subscribe" || sub["name"] != name {
+		t.Errorf("expected a %s subscription, got %v", name, req)
+	}
+}
+
+func TestWSSupervisorReconnects(t *testing.T) {
+	server := newWSTestServer(t)
+	supervisor := NewWSSupervisor(func() *WebSocket { return NewWebSocket(server.url()) }, newTestAPI(testTrades)).
+		WithStaleTimeout(200*time.Millisecond).
+		WithBackoff(10*time.Millisecond, 50*time.Millisecond)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := supervisor.Subscribe(ctx, Subscription{Name: ChannelTrade}, "XBT/USD"); err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan error)
+	go func() { done <- supervisor.Run(ctx) }()
+
+	conn := server.accept(t)
+	expectSubscribe(t, conn, ChannelTrade)
+	if event, ok := nextSupervisorEvent(t, supervisor).(*ConnectionEvent); !ok || !event.Connected {
+		t.Fatalf("expected connection, got %+v", event)
+	}
+	conn.WriteMessage(websocket.TextMessage, []byte(`[0,[["30200.00000","0.10000000","1688669597.5","b","l",""]],"trade","XBT/USD"]`))
+	if trades, ok := nextSupervisorEvent(t, supervisor).(*TradeEvent); !ok || len(trades.Trades) != 1 {
+		t.Fatalf("expected live trade, got %+v", trades)
+	}
+
+	// The connection drops, the missed trades come from REST
+	conn.Close()
+	if event, ok := nextSupervisorEvent(t, supervisor).(*ConnectionEvent); !ok || event.Connected || event.Err == nil {
+		t.Fatalf("expected disconnection, got %+v", event)
+	}
+	conn = server.accept(t)
+	expectSubscribe(t, conn, ChannelTrade)
+	if event, ok := nextSupervisorEvent(t, supervisor).(*ConnectionEvent); !ok || !event.Connected {
+		t.Fatalf("expected reconnection, got %+v", event)
+	}
+	trades, ok := nextSupervisorEvent(t, supervisor).(*TradeEvent)
+	if !ok || trades.Pair != "XBT/USD" || len(trades.Trades) != 3 || trades.Trades[2].TradeID != 61044954 {
+		t.Fatalf("expected recovered trades, got %+v", trades)
+	}
+
+	// Trades already recovered are not repeated
+	conn.WriteMessage(websocket.TextMessage, []byte(`[0,[["30240.00000","0.50000000","1688669700.25","s","l",""],["30250.00000","0.20000000","1688669701.5","b","l",""]],"trade","XBT/USD"]`))
+	if trades, ok := nextSupervisorEvent(t, supervisor).(*TradeEvent); !ok || len(trades.Trades) != 1 || trades.Trades[0].Price.String() != "30250" {
+		t.Fatalf("expected only the new trade, got %+v", trades)
+	}
+
+	// A silent connection is replaced
+	if event, ok := nextSupervisorEvent(t, supervisor).(*ConnectionEvent); !ok || event.Err != errStale {
+		t.Fatalf("expected stale connection, got %+v", event)
+	}
+	expectSubscribe(t, server.accept(t), ChannelTrade)
+
+	cancel()
+	if err := <-done; err != context.Canceled {
+		t.Errorf("Run() should return the context error, got %v", err)
+	}
+}
+
+func TestWSSupervisorCandleGap(t *testing.T) {
+	server := newWSTestServer(t)
+	api := newTestAPI(`{"error":[],"result":{"XXBTZUSD":[
+		[1542057060,"3586.7","3586.7","3586.6","3586.6","3586.68","0.03373",2],
+		[1542057660,"3590.1","3591.0","3590.1","3591.0","3590.5","0.5",4]
+	],"last":1542057600}}`)
+	supervisor := NewWSSupervisor(func() *WebSocket { return NewWebSocket(server.url()) }, api).
+		WithBackoff(10*time.Millisecond, 10*time.Millisecond)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	supervisor.Subscribe(ctx, Subscription{Name: ChannelOHLC, Interval: 5}, "XBT/USD")
+	go supervisor.Run(ctx)
+
+	conn := server.accept(t)
+	expectSubscribe(t, conn, ChannelOHLC)
+	nextSupervisorEvent(t, supervisor)
+	conn.WriteMessage(websocket.TextMessage, []byte(`[42,["1542057314.748456","1542057360.435743","3586.70000","3586.70000","3586.60000","3586.60000","3586.68894","0.03373000",2],"ohlc-5","XBT/USD"]`))
+	if _, ok := nextSupervisorEvent(t, supervisor).(*OHLCEvent); !ok {
+		t.Fatal("expected live candle")
+	}
+	conn.Close()
+	nextSupervisorEvent(t, supervisor)
+	expectSubscribe(t, server.accept(t), ChannelOHLC)
+	nextSupervisorEvent(t, supervisor)
+
+	if candle, ok := nextSupervisorEvent(t, supervisor).(*OHLCEvent); !ok || candle.Name != "ohlc-5" || candle.OHLC.Time.Unix() != 1542057060 {
+		t.Errorf("expected the last candle to be refreshed, got %+v", candle)
+	}
+	gap, ok := nextSupervisorEvent(t, supervisor).(*GapEvent)
+	if !ok || gap.From.Unix() != 1542057360 || gap.To.Unix() != 1542057660 || gap.Err == nil {
+		t.Errorf("expected a gap marker, got %+v", gap)
+	}
+	if candle, ok := nextSupervisorEvent(t, supervisor).(*OHLCEvent); !ok || candle.OHLC.Time.Unix() != 1542057660 || candle.EndTime.Unix() != 1542057960 {
+		t.Errorf("expected the recovered candle, got %+v", candle)
+	}
+	// The recovered candles end long before the reconnect
+	if gap, ok := nextSupervisorEvent(t, supervisor).(*GapEvent); !ok || gap.From.Unix() != 1542057960 || gap.To.Before(time.Now().Add(-time.Minute)) || gap.Err == nil {
+		t.Errorf("expected a gap marker up to the reconnect, got %+v", gap)
+	}
+}
+
+func TestWSSupervisorSameTimestampTrades(t *testing.T) {
+	supervisor := NewWSSupervisor(func() *WebSocket { return NewWebSocket(WSPublicURL) }, nil)
+	at := time.Unix(1688669700, 250000000)
+	channel := WSChannel{Name: ChannelTrade, Pair: "XBT/USD"}
+	live := &TradeEvent{WSChannel: channel, Trades: []TradeInfo{
+		{Price: MustDecimal("30240.00000"), Volume: MustDecimal("0.50000000"), Timestamp: at},
+		{Price: MustDecimal("30240.00000"), Volume: MustDecimal("0.50000000"), Timestamp: at},
+		{Price: MustDecimal("30241.00000"), Volume: MustDecimal("0.10000000"), Timestamp: at, Buy: true},
+	}}
+	if event, ok := supervisor.track(live).(*TradeEvent); !ok || len(event.Trades) != 3 {
+		t.Fatalf("track() should deliver all trades of one timestamp, got %+v", event)
+	}
+
+	// The gap filling delivered the first two trades at the boundary, the live feed repeats them
+	supervisor.overlaps["XBT/USD"] = &tradeCursor{time: at, seen: map[string]int{
+		tradeKey(live.Trades[0]): 1,
+		tradeKey(live.Trades[2]): 1,
+	}}
+	event, ok := supervisor.track(&TradeEvent{WSChannel: channel, Trades: []TradeInfo{
+		{Price: MustDecimal("30240.0"), Volume: MustDecimal("0.5"), Timestamp: at},
+		{Price: MustDecimal("30240.0"), Volume: MustDecimal("0.5"), Timestamp: at},
+		{Price: MustDecimal("30241.0"), Volume: MustDecimal("0.1"), Timestamp: at, Buy: true},
+		{Price: MustDecimal("30242.0"), Volume: MustDecimal("0.2"), Timestamp: at.Add(time.Second)},
+	}}).(*TradeEvent)
+	if !ok || len(event.Trades) != 2 || event.Trades[0].Price.String() != "30240" || event.Trades[1].Price.String() != "30242" {
+		t.Fatalf("track() should only drop the repeated trades, got %+v", event)
+	}
+	if _, found := supervisor.overlaps["XBT/USD"]; found {
+		t.Error("track() should end the overlap after a newer trade")
+	}
+	if event := supervisor.track(&TradeEvent{WSChannel: channel, Trades: []TradeInfo{{Price: MustDecimal("30240.0"), Volume: MustDecimal("0.5"), Timestamp: at.Add(time.Second)}}}); event == nil {
+		t.Error("track() should not filter live trades outside of an overlap")
+	}
+}
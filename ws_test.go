@@ -0,0 +1,223 @@
+package krakenapi
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// wsTestServer is a WebSocket endpoint scripted by a test
+type wsTestServer struct {
+	*httptest.Server
+	conns chan *websocket.Conn
+}
+
+func newWSTestServer(t *testing.T) *wsTestServer {
+	s := &wsTestServer{conns: make(chan *websocket.Conn, 10)}
+	upgrader := websocket.Upgrader{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			t.Errorf("upgrade failed: %s", err)
+			return
+		}
+		s.conns <- conn
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// url returns the ws:// address of the server
+func (s *wsTestServer) url() string {
+	return "ws" + strings.TrimPrefix(s.URL, "http")
+}
+
+// accept returns the server side of the next connection
+func (s *wsTestServer) accept(t *testing.T) *websocket.Conn {
+	select {
+	case conn := <-s.conns:
+		t.Cleanup(func() { conn.Close() })
+		return conn
+	case <-time.After(5 * time.Second):
+		t.Fatal("no websocket connection")
+		return nil
+	}
+}
+
+// connect returns a connected WebSocket and the server side of its connection
+func (s *wsTestServer) connect(t *testing.T) (*WebSocket, *websocket.Conn) {
+	ws := NewWebSocket(s.url())
+	if err := ws.Connect(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ws.Close() })
+	return ws, s.accept(t)
+}
+
+// nextEvent returns the next event of ws
+func nextEvent(t *testing.T, ws *WebSocket) WSEvent {
+	t.Helper()
+	select {
+	case event, ok := <-ws.Events():
+		if !ok {
+			t.Fatalf("events closed: %v", ws.Err())
+		}
+		return event
+	case <-time.After(5 * time.Second):
+		t.Fatal("no websocket event")
+		return nil
+	}
+}
+
+func TestWebSocketPublicFeeds(t *testing.T) {
+	server := newWSTestServer(t)
+	ws, conn := server.connect(t)
+
+	if err := ws.Subscribe(context.Background(), Subscription{Name: ChannelOHLC, Interval: 5}, "XBT/USD"); err != nil {
+		t.Fatal(err)
+	}
+	var req map[string]interface{}
+	if err := conn.ReadJSON(&req); err != nil {
+		t.Fatal(err)
+	}
+	if req["event"] != "subscribe" || req["subscription"].(map[string]interface{})["interval"] != 5.0 || req["pair"].([]interface{})[0] != "XBT/USD" {
+		t.Errorf("Subscribe() sent a wrong request, got %v", req)
+	}
+
+	messages := []string{
+		`{"connectionID":8628615390848610000,"event":"systemStatus","status":"online","version":"1.0.0"}`,
+		`{"channelID":42,"channelName":"ohlc-5","event":"subscriptionStatus","pair":"XBT/USD","status":"subscribed","subscription":{"interval":5,"name":"ohlc"}}`,
+		`{"event":"heartbeat"}`,
+		`[42,{"a":["5525.40000",1,"1.000"],"b":["5525.10000",1,"1.000"],"c":["5525.10000","0.00398963"],"v":["2634.11501494","3591.17907851"],"p":["5631.44067","5653.78939"],"t":[11493,16267],"l":["5505.00000","5505.00000"],"h":["5783.00000","5783.00000"],"o":["5760.70000","5763.40000"]},"ticker","XBT/USD"]`,
+		`[42,["1542057314.748456","1542057360.435743","3586.70000","3586.70000","3586.60000","3586.60000","3586.68894","0.03373000",2],"ohlc-5","XBT/USD"]`,
+		`[0,[["5541.20000","0.15850568","1534614057.321597","s","l",""],["6060.00000","0.02455000","1534614057.324998","b","l",""]],"trade","XBT/USD"]`,
+		`[0,["5698.40000","5700.00000","1542057299.545897","1.01234567","0.98765432"],"spread","XBT/USD"]`,
+		`[0,{"as":[["5541.30000","2.50700000","1534614248.123678"]],"bs":[["5541.20000","1.52900000","1534614248.765567"]]},"book-10","XBT/USD"]`,
+		`[1234,{"a":[["5541.30000","2.50700000","1534614248.456738"]]},{"b":[["5541.30000","0.00000000","1534614335.345903"]],"c":"974942666"},"book-10","XBT/USD"]`,
+		`{"errorMessage":"Subscription depth not supported","event":"error"}`,
+		`[0,"garbage","unknown","XBT/USD"]`,
+	}
+	for _, msg := range messages {
+		if err := conn.WriteMessage(websocket.TextMessage, []byte(msg)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if status, ok := nextEvent(t, ws).(*SystemStatusEvent); !ok || status.Status != "online" {
+		t.Errorf("expected system status, got %+v", status)
+	}
+	sub, ok := nextEvent(t, ws).(*SubscriptionStatusEvent)
+	if !ok || sub.Status != SubscriptionSubscribed || sub.Channel() != (WSChannel{Name: "ohlc-5", Pair: "XBT/USD"}) || sub.Subscription.Interval != 5 {
+		t.Errorf("expected subscription status, got %+v", sub)
+	}
+	if _, ok := nextEvent(t, ws).(*HeartbeatEvent); !ok {
+		t.Error("expected heartbeat")
+	}
+	ticker, ok := nextEvent(t, ws).(*TickerEvent)
+	if !ok || ticker.Ticker.Ask[0].String() != "5525.4" || ticker.Ticker.Ask[1].String() != "1" || ticker.Ticker.OpeningPrice.String() != "5760.7" || ticker.Ticker.Trades[1] != 16267 {
+		t.Errorf("expected ticker, got %+v", ticker)
+	}
+	ohlc, ok := nextEvent(t, ws).(*OHLCEvent)
+	if !ok || ohlc.Interval != 5 || !ohlc.OHLC.Time.Equal(time.Unix(1542057060, 0)) || ohlc.OHLC.Count != 2 || ohlc.OHLC.Vwap.String() != "3586.68894" {
+		t.Errorf("expected candle, got %+v", ohlc)
+	}
+	trades, ok := nextEvent(t, ws).(*TradeEvent)
+	if !ok || len(trades.Trades) != 2 || !trades.Trades[0].Sell || !trades.Trades[1].Buy || trades.Trades[0].Time != 1534614057 {
+		t.Errorf("expected trades, got %+v", trades)
+	}
+	spread, ok := nextEvent(t, ws).(*SpreadEvent)
+	if !ok || spread.Bid.String() != "5698.4" || spread.AskVolume.String() != "0.98765432" {
+		t.Errorf("expected spread, got %+v", spread)
+	}
+	snapshot, ok := nextEvent(t, ws).(*BookEvent)
+	if !ok || !snapshot.Snapshot || snapshot.Depth != 10 || len(snapshot.Asks) != 1 || len(snapshot.Bids) != 1 {
+		t.Errorf("expected book snapshot, got %+v", snapshot)
+	}
+	update, ok := nextEvent(t, ws).(*BookEvent)
+	if !ok || update.Snapshot || len(update.Asks) != 1 || len(update.Bids) != 1 || !update.Bids[0].Amount.IsZero() || update.Checksum != 974942666 {
+		t.Errorf("expected book update, got %+v", update)
+	}
+	for i := 0; i < 2; i++ {
+		if event, ok := nextEvent(t, ws).(*ErrorEvent); !ok || event.Err == nil {
+			t.Errorf("expected error event, got %+v", event)
+		}
+	}
+
+	conn.Close()
+	for range ws.Events() {
+	}
+	if ws.Err() == nil {
+		t.Error("Err() should report the lost connection")
+	}
+}
+
+func TestWebSocketClose(t *testing.T) {
+	server := newWSTestServer(t)
+	ws, _ := server.connect(t)
+
+	if err := ws.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := <-ws.Events(); ok {
+		t.Error("Events() should be closed after Close()")
+	}
+	if ws.Err() != nil {
+		t.Errorf("Err() should be nil after Close(), got %s", ws.Err())
+	}
+	if err := ws.Ping(context.Background()); err != ErrWebSocketClosed {
+		t.Errorf("Ping() should fail after Close(), got %v", err)
+	}
+}
+
+func TestWebSocketSendCancel(t *testing.T) {
+	server := newWSTestServer(t)
+	ws, _ := server.connect(t)
+
+	// the server never reads, so the write blocks once the socket buffers are full
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(100*time.Millisecond, cancel)
+	result := make(chan error, 1)
+	go func() {
+		result <- ws.send(ctx, map[string]string{"payload": strings.Repeat("x", 64<<20)})
+	}()
+	select {
+	case err := <-result:
+		if err != context.Canceled {
+			t.Errorf("send() should fail with %v, got %v", context.Canceled, err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("send() did not return after ctx was cancelled")
+	}
+}
+
+func TestWebSocketMalformedRows(t *testing.T) {
+	spreads := []string{
+		`["5698.40000","5700.00000","1542057299.545897","1.01234567"]`,
+		`[5698.4,"5700.00000","1542057299.545897","1.01234567","0.98765432"]`,
+		`["5698.40000","5700.00000",1542057299.545897,"1.01234567","0.98765432"]`,
+	}
+	for _, spread := range spreads {
+		if _, err := decodeWSSpread(WSChannel{}, json.RawMessage(spread)); err == nil {
+			t.Errorf("decodeWSSpread(%s) should fail", spread)
+		}
+	}
+
+	levels := []string{
+		`["5541.30000","2.50700000"]`,
+		`[5541.3,"2.50700000","1534614248.123678"]`,
+		`["5541.30000","volume","1534614248.123678"]`,
+		`["5541.30000","2.50700000",1534614248.123678]`,
+		`{"price":"5541.30000"}`,
+	}
+	for _, level := range levels {
+		if _, err := newWSBookLevel(json.RawMessage(level)); err == nil {
+			t.Errorf("newWSBookLevel(%s) should fail", level)
+		}
+	}
+}
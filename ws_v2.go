@@ -0,0 +1,586 @@
+package krakenapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// WSVersion selects the protocol of a WebSocket connection
+type WSVersion int
+
+// Supported WebSocket API versions
+const (
+	WSVersion1 WSVersion = 1
+	WSVersion2 WSVersion = 2
+)
+
+const (
+	// WSPublicURLv2 is the endpoint of Kraken's public WebSocket API v2
+	WSPublicURLv2 = "wss://ws.kraken.com/v2"
+	// WSPrivateURLv2 is the endpoint of Kraken's authenticated WebSocket API v2
+	WSPrivateURLv2 = "wss://ws-auth.kraken.com/v2"
+	// WSLevel3URLv2 is the endpoint of Kraken's authenticated WebSocket API v2 serving ChannelLevel3
+	WSLevel3URLv2 = "wss://ws-l3.kraken.com/v2"
+)
+
+// WebSocket API v2 channel names
+const (
+	// ChannelLevel3 is only served on WSLevel3URLv2 and needs a token
+	ChannelLevel3     = "level3"
+	ChannelInstrument = "instrument"
+	ChannelBalances   = "balances"
+	ChannelExecutions = "executions"
+)
+
+// Level3Order is an order of the level3 book
+type Level3Order struct {
+	// Change of an update, "add", "modify" or "delete", empty in snapshots
+	Event   string    `json:"event"`
+	OrderID string    `json:"order_id"`
+	Price   Decimal   `json:"limit_price"`
+	Volume  Decimal   `json:"order_qty"`
+	Time    time.Time `json:"timestamp"`
+}
+
+// Level3Event is a snapshot or an update of the level3 book, which lists single orders
+type Level3Event struct {
+	WSChannel
+	Snapshot bool
+	Bids     []Level3Order
+	Asks     []Level3Order
+	Checksum uint32
+}
+
+// InstrumentPair describes a pair in the instrument channel
+type InstrumentPair struct {
+	Symbol         string  `json:"symbol"`
+	Base           string  `json:"base"`
+	Quote          string  `json:"quote"`
+	Status         string  `json:"status"`
+	PricePrecision int32   `json:"price_precision"`
+	QtyPrecision   int32   `json:"qty_precision"`
+	CostPrecision  int32   `json:"cost_precision"`
+	TickSize       Decimal `json:"price_increment"`
+	QtyIncrement   Decimal `json:"qty_increment"`
+	QtyMin         Decimal `json:"qty_min"`
+	CostMin        Decimal `json:"cost_min"`
+	Marginable     bool    `json:"marginable"`
+}
+
+// InstrumentAsset describes an asset in the instrument channel
+type InstrumentAsset struct {
+	ID               string  `json:"id"`
+	Status           string  `json:"status"`
+	Precision        int32   `json:"precision"`
+	PrecisionDisplay int32   `json:"precision_display"`
+	Borrowable       bool    `json:"borrowable"`
+	CollateralValue  Decimal `json:"collateral_value"`
+	MarginRate       Decimal `json:"margin_rate"`
+}
+
+// InstrumentEvent carries the reference data of assets and pairs
+type InstrumentEvent struct {
+	WSChannel
+	Snapshot bool
+	Assets   []InstrumentAsset
+	Pairs    []InstrumentPair
+}
+
+// BalanceUpdate is the balance of an asset, with the ledger entry that changed it in updates
+type BalanceUpdate struct {
+	Asset      string     `json:"asset"`
+	AssetClass string     `json:"asset_class"`
+	Balance    Decimal    `json:"balance"`
+	Amount     Decimal    `json:"amount"`
+	Fee        Decimal    `json:"fee"`
+	LedgerID   string     `json:"ledger_id"`
+	RefID      string     `json:"ref_id"`
+	Type       LedgerType `json:"type"`
+	Time       time.Time  `json:"timestamp"`
+}
+
+// BalancesEvent carries the balances of the account after subscribing and then their changes
+type BalancesEvent struct {
+	WSChannel
+	Snapshot bool
+	Sequence int64
+	Balances []BalanceUpdate
+}
+
+func (e *BalancesEvent) sequence() int64 {
+	return e.Sequence
+}
+
+// WithVersion selects the protocol of the connection, which must match the endpoint.
+// By default endpoints ending in "/v2" use WSVersion2 and all others WSVersion1.
+func (ws *WebSocket) WithVersion(version WSVersion) *WebSocket {
+	ws.version = version
+	return ws
+}
+
+// symbolV2 returns the v2 symbol of a v1 pair name, e.g. "BTC/USD" for "XBT/USD"
+func symbolV2(name string) string {
+	parts := strings.Split(name, "/")
+	for i, part := range parts {
+		for display, altname := range displayNames {
+			if part == altname {
+				parts[i] = display
+			}
+		}
+	}
+	return strings.Join(parts, "/")
+}
+
+// subscriptionV2 builds a subscribe or unsubscribe request of the v2 protocol
+func subscriptionV2(method string, sub Subscription, symbols []string, reqID int64) map[string]interface{} {
+	params := map[string]interface{}{"channel": sub.Name}
+	if len(symbols) > 0 {
+		params["symbol"] = symbols
+	}
+	if sub.Interval > 0 {
+		params["interval"] = sub.Interval
+	}
+	if sub.Depth > 0 {
+		params["depth"] = sub.Depth
+	}
+	if sub.Token != "" {
+		params["token"] = sub.Token
+	}
+	return map[string]interface{}{"method": method, "params": params, "req_id": reqID}
+}
+
+// channelName returns the v1 channel name of a subscription, e.g. "book-10"
+func channelName(name string, param int) string {
+	if param > 0 && (name == ChannelBook || name == ChannelOHLC) {
+		return fmt.Sprintf("%s-%d", name, param)
+	}
+	return name
+}
+
+// wsMessageV2 is a message of the v2 protocol
+type wsMessageV2 struct {
+	Channel  string          `json:"channel"`
+	Type     string          `json:"type"`
+	Data     json.RawMessage `json:"data"`
+	Sequence int64           `json:"sequence"`
+	Method   string          `json:"method"`
+	Success  bool            `json:"success"`
+	Result   json.RawMessage `json:"result"`
+	Error    string          `json:"error"`
+	ReqID    int64           `json:"req_id"`
+}
+
+// decodeV2 decodes a message of the v2 protocol into the events of v1. It keeps the
+// book depths and instrument precisions and must only be called by the read loop.
+func (ws *WebSocket) decodeV2(data []byte) ([]WSEvent, error) {
+	var msg wsMessageV2
+	if err := json.Unmarshal(data, &msg); err != nil {
+		return nil, err
+	}
+
+	switch msg.Method {
+	case "":
+	case "pong":
+		return []WSEvent{&PongEvent{ReqID: msg.ReqID}}, nil
+	case "subscribe", "unsubscribe":
+		return ws.decodeSubscriptionV2(msg)
+	default:
+		if !msg.Success {
+			return []WSEvent{&ErrorEvent{Err: fmt.Errorf("Kraken websocket error: %s", msg.Error)}}, nil
+		}
+		return nil, nil
+	}
+
+	snapshot := msg.Type == "snapshot"
+	switch msg.Channel {
+	case "heartbeat":
+		return []WSEvent{&HeartbeatEvent{}}, nil
+	case "status":
+		var statuses []struct {
+			System       string `json:"system"`
+			Version      string `json:"version"`
+			ConnectionID uint64 `json:"connection_id"`
+		}
+		if err := json.Unmarshal(msg.Data, &statuses); err != nil {
+			return nil, err
+		}
+		events := make([]WSEvent, len(statuses))
+		for i, status := range statuses {
+			events[i] = &SystemStatusEvent{ConnectionID: status.ConnectionID, Status: status.System, Version: status.Version}
+		}
+		return events, nil
+	case ChannelTicker:
+		return decodeTickerV2(msg.Data)
+	case ChannelOHLC:
+		return decodeOHLCV2(msg.Data)
+	case ChannelTrade:
+		return decodeTradesV2(msg.Data)
+	case ChannelBook:
+		return ws.decodeBookV2(msg.Data, snapshot)
+	case ChannelLevel3:
+		return decodeLevel3V2(msg.Data, snapshot)
+	case ChannelInstrument:
+		return ws.decodeInstrumentV2(msg.Data, snapshot)
+	case ChannelBalances:
+		event := &BalancesEvent{WSChannel: WSChannel{Name: ChannelBalances}, Snapshot: snapshot, Sequence: msg.Sequence}
+		if err := json.Unmarshal(msg.Data, &event.Balances); err != nil {
+			return nil, err
+		}
+		return []WSEvent{event}, nil
+	case ChannelExecutions:
+		return decodeExecutionsV2(msg.Data, msg.Sequence)
+	}
+	return nil, fmt.Errorf("unknown channel %s", msg.Channel)
+}
+
+func (ws *WebSocket) decodeSubscriptionV2(msg wsMessageV2) ([]WSEvent, error) {
+	var result struct {
+		Channel  string `json:"channel"`
+		Symbol   string `json:"symbol"`
+		Depth    int    `json:"depth"`
+		Interval int    `json:"interval"`
+	}
+	if len(msg.Result) > 0 {
+		if err := json.Unmarshal(msg.Result, &result); err != nil {
+			return nil, err
+		}
+	}
+
+	event := &SubscriptionStatusEvent{
+		Subscription: Subscription{Name: result.Channel, Depth: result.Depth, Interval: result.Interval},
+		ErrorMessage: msg.Error,
+		ReqID:        msg.ReqID,
+	}
+	event.WSChannel = WSChannel{Name: channelName(result.Channel, result.Depth+result.Interval), Pair: result.Symbol}
+	switch {
+	case !msg.Success:
+		event.Status = SubscriptionError
+	case msg.Method == "subscribe":
+		event.Status = SubscriptionSubscribed
+		if result.Channel == ChannelBook {
+			ws.bookDepths[result.Symbol] = result.Depth
+		}
+	default:
+		event.Status = SubscriptionUnsubscribed
+	}
+	return []WSEvent{event}, nil
+}
+
+func decodeTickerV2(data json.RawMessage) ([]WSEvent, error) {
+	var tickers []struct {
+		Symbol string  `json:"symbol"`
+		Bid    Decimal `json:"bid"`
+		BidQty Decimal `json:"bid_qty"`
+		Ask    Decimal `json:"ask"`
+		AskQty Decimal `json:"ask_qty"`
+		Last   Decimal `json:"last"`
+		Volume Decimal `json:"volume"`
+		VWAP   Decimal `json:"vwap"`
+		Low    Decimal `json:"low"`
+		High   Decimal `json:"high"`
+		Change Decimal `json:"change"`
+	}
+	if err := json.Unmarshal(data, &tickers); err != nil {
+		return nil, err
+	}
+
+	events := make([]WSEvent, len(tickers))
+	for i, t := range tickers {
+		// v2 only reports the last 24 hours, which fill both the today and 24 hours
+		// entries, and no whole lot volumes and trade counts, which stay zero
+		events[i] = &TickerEvent{WSChannel: WSChannel{Name: ChannelTicker, Pair: t.Symbol}, Ticker: PairTickerInfo{
+			Ask:                []Decimal{t.Ask, {}, t.AskQty},
+			Bid:                []Decimal{t.Bid, {}, t.BidQty},
+			Close:              []Decimal{t.Last, {}},
+			Volume:             []Decimal{t.Volume, t.Volume},
+			VolumeAveragePrice: []Decimal{t.VWAP, t.VWAP},
+			Low:                []Decimal{t.Low, t.Low},
+			High:               []Decimal{t.High, t.High},
+			OpeningPrice:       t.Last.Sub(t.Change),
+		}}
+	}
+	return events, nil
+}
+
+func decodeOHLCV2(data json.RawMessage) ([]WSEvent, error) {
+	var candles []struct {
+		Symbol        string    `json:"symbol"`
+		Open          Decimal   `json:"open"`
+		High          Decimal   `json:"high"`
+		Low           Decimal   `json:"low"`
+		Close         Decimal   `json:"close"`
+		VWAP          Decimal   `json:"vwap"`
+		Volume        Decimal   `json:"volume"`
+		Trades        int       `json:"trades"`
+		IntervalBegin time.Time `json:"interval_begin"`
+		Interval      int       `json:"interval"`
+		Timestamp     time.Time `json:"timestamp"`
+	}
+	if err := json.Unmarshal(data, &candles); err != nil {
+		return nil, err
+	}
+
+	events := make([]WSEvent, len(candles))
+	for i, c := range candles {
+		events[i] = &OHLCEvent{
+			WSChannel:  WSChannel{Name: channelName(ChannelOHLC, c.Interval), Pair: c.Symbol},
+			Interval:   c.Interval,
+			EndTime:    c.IntervalBegin.Add(time.Duration(c.Interval) * time.Minute),
+			UpdateTime: c.Timestamp,
+			OHLC: OHLC{
+				Time:   c.IntervalBegin,
+				Open:   c.Open,
+				High:   c.High,
+				Low:    c.Low,
+				Close:  c.Close,
+				Vwap:   c.VWAP,
+				Volume: c.Volume,
+				Count:  c.Trades,
+			},
+		}
+	}
+	return events, nil
+}
+
+func decodeTradesV2(data json.RawMessage) ([]WSEvent, error) {
+	var trades []struct {
+		Symbol    string    `json:"symbol"`
+		Side      Side      `json:"side"`
+		Price     Decimal   `json:"price"`
+		Qty       Decimal   `json:"qty"`
+		OrdType   OrderType `json:"ord_type"`
+		TradeID   int64     `json:"trade_id"`
+		Timestamp time.Time `json:"timestamp"`
+	}
+	if err := json.Unmarshal(data, &trades); err != nil {
+		return nil, err
+	}
+
+	// One event per pair like in v1
+	var events []WSEvent
+	bySymbol := map[string]*TradeEvent{}
+	for _, t := range trades {
+		event := bySymbol[t.Symbol]
+		if event == nil {
+			event = &TradeEvent{WSChannel: WSChannel{Name: ChannelTrade, Pair: t.Symbol}}
+			bySymbol[t.Symbol] = event
+			events = append(events, event)
+		}
+		event.Trades = append(event.Trades, TradeInfo{
+			Price:     t.Price,
+			Volume:    t.Qty,
+			Time:      t.Timestamp.Unix(),
+			Timestamp: t.Timestamp,
+			TradeID:   t.TradeID,
+			Buy:       t.Side == SideBuy,
+			Sell:      t.Side == SideSell,
+			Market:    t.OrdType == OTMarket,
+			Limit:     t.OrdType == OTLimit,
+		})
+	}
+	return events, nil
+}
+
+// bookPrecision returns the decimals of prices and volumes of a symbol, which the
+// v2 checksum is computed with
+func (ws *WebSocket) bookPrecision(symbol string) (int32, int32, bool) {
+	if pair, found := ws.instruments[symbol]; found {
+		return pair.PricePrecision, pair.QtyPrecision, true
+	}
+	if ws.registry != nil {
+		if info, found := ws.registry.Pair(symbol); found {
+			return int32(info.PairDecimals), int32(info.LotDecimals), true
+		}
+	}
+	return 0, 0, false
+}
+
+// decodeBookV2 decodes book levels sent as numbers. The checksum is only kept if the
+// pair's precision is known from the instrument channel or the registry, as Kraken
+// computes it from the digits with trailing zeros.
+func (ws *WebSocket) decodeBookV2(data json.RawMessage, snapshot bool) ([]WSEvent, error) {
+	var books []struct {
+		Symbol string `json:"symbol"`
+		Bids   []struct {
+			Price Decimal `json:"price"`
+			Qty   Decimal `json:"qty"`
+		} `json:"bids"`
+		Asks []struct {
+			Price Decimal `json:"price"`
+			Qty   Decimal `json:"qty"`
+		} `json:"asks"`
+		Checksum  uint32    `json:"checksum"`
+		Timestamp time.Time `json:"timestamp"`
+	}
+	if err := json.Unmarshal(data, &books); err != nil {
+		return nil, err
+	}
+
+	events := make([]WSEvent, len(books))
+	for i, b := range books {
+		depth := ws.bookDepths[b.Symbol]
+		event := &BookEvent{WSChannel: WSChannel{Name: channelName(ChannelBook, depth), Pair: b.Symbol}, Depth: depth, Snapshot: snapshot}
+		pricePlaces, qtyPlaces, known := ws.bookPrecision(b.Symbol)
+		level := func(price Decimal, qty Decimal) OrderBookItem {
+			if known && price.scale <= pricePlaces && qty.scale <= qtyPlaces {
+				price, qty = price.rescale(pricePlaces), qty.rescale(qtyPlaces)
+			} else {
+				known = false
+			}
+			return OrderBookItem{Price: price, Amount: qty, Ts: b.Timestamp.Unix()}
+		}
+		for _, ask := range b.Asks {
+			event.Asks = append(event.Asks, level(ask.Price, ask.Qty))
+		}
+		for _, bid := range b.Bids {
+			event.Bids = append(event.Bids, level(bid.Price, bid.Qty))
+		}
+		if known {
+			event.Checksum = b.Checksum
+		}
+		events[i] = event
+	}
+	return events, nil
+}
+
+func decodeLevel3V2(data json.RawMessage, snapshot bool) ([]WSEvent, error) {
+	var books []struct {
+		Symbol   string        `json:"symbol"`
+		Bids     []Level3Order `json:"bids"`
+		Asks     []Level3Order `json:"asks"`
+		Checksum uint32        `json:"checksum"`
+	}
+	if err := json.Unmarshal(data, &books); err != nil {
+		return nil, err
+	}
+
+	events := make([]WSEvent, len(books))
+	for i, b := range books {
+		events[i] = &Level3Event{
+			WSChannel: WSChannel{Name: ChannelLevel3, Pair: b.Symbol},
+			Snapshot:  snapshot,
+			Bids:      b.Bids,
+			Asks:      b.Asks,
+			Checksum:  b.Checksum,
+		}
+	}
+	return events, nil
+}
+
+func (ws *WebSocket) decodeInstrumentV2(data json.RawMessage, snapshot bool) ([]WSEvent, error) {
+	event := &InstrumentEvent{WSChannel: WSChannel{Name: ChannelInstrument}, Snapshot: snapshot}
+	var instruments struct {
+		Assets []InstrumentAsset `json:"assets"`
+		Pairs  []InstrumentPair  `json:"pairs"`
+	}
+	if err := json.Unmarshal(data, &instruments); err != nil {
+		return nil, err
+	}
+	event.Assets, event.Pairs = instruments.Assets, instruments.Pairs
+
+	for _, pair := range event.Pairs {
+		ws.instruments[pair.Symbol] = pair
+	}
+	return []WSEvent{event}, nil
+}
+
+// orderStatusV2 maps the order status of the executions channel to the REST status
+var orderStatusV2 = map[string]OrderStatus{
+	"pending_new":      OrderStatusPending,
+	"new":              OrderStatusOpen,
+	"partially_filled": OrderStatusOpen,
+	"filled":           OrderStatusClosed,
+	"canceled":         OrderStatusCanceled,
+	"expired":          OrderStatusExpired,
+}
+
+// decodeExecutionsV2 decodes the executions channel into an OwnTradesEvent with the
+// fills and an OpenOrdersEvent with the order changes, like the v1 private channels
+func decodeExecutionsV2(data json.RawMessage, sequence int64) ([]WSEvent, error) {
+	var executions []struct {
+		OrderID      string    `json:"order_id"`
+		ExecID       string    `json:"exec_id"`
+		ExecType     string    `json:"exec_type"`
+		Symbol       string    `json:"symbol"`
+		Side         Side      `json:"side"`
+		OrderType    OrderType `json:"order_type"`
+		OrderStatus  string    `json:"order_status"`
+		OrderUserRef int       `json:"order_userref"`
+		OrderQty     Decimal   `json:"order_qty"`
+		LimitPrice   Decimal   `json:"limit_price"`
+		LastQty      Decimal   `json:"last_qty"`
+		LastPrice    Decimal   `json:"last_price"`
+		Cost         Decimal   `json:"cost"`
+		CumQty       Decimal   `json:"cum_qty"`
+		CumCost      Decimal   `json:"cum_cost"`
+		AvgPrice     Decimal   `json:"avg_price"`
+		Fees         []struct {
+			Qty Decimal `json:"qty"`
+		} `json:"fees"`
+		Reason    string    `json:"reason"`
+		Timestamp time.Time `json:"timestamp"`
+	}
+	if err := json.Unmarshal(data, &executions); err != nil {
+		return nil, err
+	}
+
+	channel := WSChannel{Name: ChannelExecutions}
+	trades := &OwnTradesEvent{WSChannel: channel, Sequence: sequence}
+	orders := &OpenOrdersEvent{WSChannel: channel, Sequence: sequence}
+	for _, e := range executions {
+		ts := float64(e.Timestamp.UnixNano()) / 1e9
+		status, known := orderStatusV2[e.OrderStatus]
+		if !known {
+			status = OrderStatus(e.OrderStatus)
+		}
+
+		order := Order{
+			TransactionID:  e.OrderID,
+			UserRef:        e.OrderUserRef,
+			Status:         status,
+			Description:    OrderDescription{AssetPair: e.Symbol, OrderType: e.OrderType, Type: e.Side},
+			Volume:         e.OrderQty,
+			VolumeExecuted: e.CumQty,
+			Cost:           e.CumCost,
+			Price:          e.AvgPrice,
+			LimitPrice:     e.LimitPrice,
+			Reason:         e.Reason,
+		}
+		if !e.LimitPrice.IsZero() {
+			order.Description.PrimaryPrice = e.LimitPrice.String()
+		}
+		if e.ExecType == "new" || e.ExecType == "pending_new" {
+			order.OpenTime = ts
+		}
+		if status.IsFinal() {
+			order.CloseTime = ts
+		}
+		orders.Orders = append(orders.Orders, order)
+
+		if e.ExecType == "trade" {
+			var fee Decimal
+			for _, f := range e.Fees {
+				fee = fee.Add(f.Qty)
+			}
+			trades.Trades = append(trades.Trades, TradeHistoryInfo{
+				TradeID:       e.ExecID,
+				TransactionID: e.OrderID,
+				AssetPair:     e.Symbol,
+				Time:          ts,
+				Type:          e.Side,
+				OrderType:     e.OrderType,
+				Price:         e.LastPrice,
+				Cost:          e.Cost,
+				Fee:           fee,
+				Volume:        e.LastQty,
+			})
+		}
+	}
+
+	if len(trades.Trades) > 0 {
+		return []WSEvent{trades, orders}, nil
+	}
+	return []WSEvent{orders}, nil
+}
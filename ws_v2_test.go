@@ -0,0 +1,127 @@
+package krakenapi
+
+import (
+	"context"
+	"fmt"
+	"hash/crc32"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestWebSocketV2(t *testing.T) {
+	server := newWSTestServer(t)
+	registry := NewRegistry(AssetPairMap{
+		XXBTZUSD: {Altname: "XBTUSD", WSName: "XBT/USD", Base: "XXBT", Quote: "ZUSD", PairDecimals: 1, LotDecimals: 8},
+	}, AssetMap{"XXBT": {Altname: "XBT"}, "ZUSD": {Altname: "USD"}})
+	ws := NewWebSocket(server.url() + "/v2").WithRegistry(registry)
+	if err := ws.Connect(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer ws.Close()
+	conn := server.accept(t)
+	ctx := context.Background()
+
+	if err := ws.Subscribe(ctx, Subscription{Name: ChannelBook, Depth: 10}, XXBTZUSD); err != nil {
+		t.Fatal(err)
+	}
+	var req map[string]interface{}
+	if err := conn.ReadJSON(&req); err != nil {
+		t.Fatal(err)
+	}
+	params := req["params"].(map[string]interface{})
+	if req["method"] != "subscribe" || params["channel"] != "book" || params["depth"] != 10.0 || params["symbol"].([]interface{})[0] != "BTC/USD" {
+		t.Errorf("Subscribe() sent a wrong v2 request, got %v", req)
+	}
+	if _, err := ws.AddOrder(ctx, NewMarketOrder("BTC/USD", SideBuy, MustDecimal("1"))); err == nil {
+		t.Error("AddOrder() should not be supported on v2")
+	}
+
+	checksum := crc32.ChecksumIEEE([]byte("452835" + "20000000" + "452834" + "120000000"))
+	messages := []string{
+		`{"channel":"status","data":[{"api_version":"v2","connection_id":12393906104898154338,"system":"online","version":"2.0.0"}],"type":"update"}`,
+		`{"method":"subscribe","result":{"channel":"book","depth":10,"snapshot":true,"symbol":"BTC/USD"},"success":true,"time_in":"2023-09-25T09:04:31.742599Z","time_out":"2023-09-25T09:04:31.742648Z"}`,
+		`{"channel":"heartbeat"}`,
+		`{"channel":"book","type":"snapshot","data":[{"symbol":"BTC/USD","bids":[{"price":45283.4,"qty":1.2}],"asks":[{"price":45283.5,"qty":0.1}],"checksum":1}]}`,
+		fmt.Sprintf(`{"channel":"book","type":"update","data":[{"symbol":"BTC/USD","bids":[],"asks":[{"price":45283.5,"qty":0.2}],"checksum":%d,"timestamp":"2023-10-06T17:35:55.440295Z"}]}`, checksum),
+		`{"channel":"ticker","type":"update","data":[{"symbol":"BTC/USD","bid":27240.8,"bid_qty":1.5,"ask":27240.9,"ask_qty":0.03,"last":27240.9,"volume":1834.2,"vwap":27154.5,"low":26967.2,"high":27467.2,"change":140.9,"change_pct":0.52}]}`,
+		`{"channel":"ohlc","type":"update","data":[{"symbol":"BTC/USD","open":27550.9,"high":27551.0,"low":27550.9,"close":27551.0,"trades":3,"volume":0.0279,"vwap":27550.95,"interval_begin":"2023-10-04T16:25:00.000000Z","interval":5,"timestamp":"2023-10-04T16:29:59.999999Z"}]}`,
+		`{"channel":"trade","type":"update","data":[{"symbol":"BTC/USD","side":"sell","price":26390.5,"qty":0.0002,"ord_type":"market","trade_id":4665906,"timestamp":"2023-09-25T07:49:37.708706Z"}]}`,
+		`{"channel":"executions","type":"update","sequence":1,"data":[{"order_id":"OK4GJX-KSTLS-7DZZO5","exec_id":"TIKLYY-4GGVP-GHUG3S","exec_type":"trade","trade_id":365573,"symbol":"BTC/USD","side":"buy","last_qty":0.5,"last_price":27000,"cost":13500,"order_userref":7,"order_status":"partially_filled","order_type":"limit","limit_price":27000,"order_qty":1,"cum_qty":0.5,"cum_cost":13500,"avg_price":27000,"fees":[{"asset":"USD","qty":35.1}],"timestamp":"2023-09-22T10:33:05.709950Z"}]}`,
+		`{"channel":"balances","type":"update","sequence":3,"data":[{"asset":"BTC","asset_class":"currency","amount":0.5,"balance":1.5,"fee":0,"ledger_id":"L4UESK-KG3EQ-UFO4T5","ref_id":"TIKLYY-4GGVP-GHUG3S","timestamp":"2023-09-22T10:33:05.709993Z","type":"trade"}]}`,
+		`{"method":"pong","req_id":9}`,
+	}
+	for _, msg := range messages {
+		conn.WriteMessage(websocket.TextMessage, []byte(msg))
+	}
+
+	if status, ok := nextEvent(t, ws).(*SystemStatusEvent); !ok || status.Status != "online" || status.Version != "2.0.0" {
+		t.Errorf("expected system status, got %+v", status)
+	}
+	sub, ok := nextEvent(t, ws).(*SubscriptionStatusEvent)
+	if !ok || sub.Status != SubscriptionSubscribed || sub.Name != "book-10" || sub.Pair != "BTC/USD" {
+		t.Errorf("expected subscription status, got %+v", sub)
+	}
+	if _, ok := nextEvent(t, ws).(*HeartbeatEvent); !ok {
+		t.Error("expected heartbeat")
+	}
+
+	maintainer := NewBookMaintainer(ws, 10)
+	if err := maintainer.Subscribe(ctx, XXBTZUSD); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		event := nextEvent(t, ws)
+		if book, ok := event.(*BookEvent); !ok || book.Depth != 10 {
+			t.Fatalf("expected book, got %+v", event)
+		}
+		if err := maintainer.Handle(ctx, event); err != nil {
+			t.Fatalf("the v2 book should pass the checksum, got %s", err)
+		}
+	}
+	if ask, _ := maintainer.Book(XXBTZUSD).BestAsk(); ask.Amount.String() != "0.2" {
+		t.Errorf("the v2 book update should be applied, got %v", ask)
+	}
+
+	ticker, ok := nextEvent(t, ws).(*TickerEvent)
+	if !ok || ticker.Ticker.Ask[0].String() != "27240.9" || ticker.Ticker.OpeningPrice.String() != "27100" {
+		t.Errorf("expected ticker, got %+v", ticker)
+	}
+	ohlc, ok := nextEvent(t, ws).(*OHLCEvent)
+	if !ok || ohlc.Name != "ohlc-5" || !ohlc.EndTime.Equal(time.Date(2023, 10, 4, 16, 30, 0, 0, time.UTC)) || ohlc.OHLC.Count != 3 {
+		t.Errorf("expected candle, got %+v", ohlc)
+	}
+	trades, ok := nextEvent(t, ws).(*TradeEvent)
+	if !ok || len(trades.Trades) != 1 || !trades.Trades[0].Sell || !trades.Trades[0].Market || trades.Trades[0].TradeID != 4665906 {
+		t.Errorf("expected trades, got %+v", trades)
+	}
+
+	ownTrades, ok := nextEvent(t, ws).(*OwnTradesEvent)
+	if !ok || len(ownTrades.Trades) != 1 || ownTrades.Trades[0].Volume.String() != "0.5" || ownTrades.Trades[0].Fee.String() != "35.1" {
+		t.Errorf("expected own trades, got %+v", ownTrades)
+	}
+	orders, ok := nextEvent(t, ws).(*OpenOrdersEvent)
+	if !ok || orders.Orders[0].Status != OrderStatusOpen || orders.Orders[0].VolumeExecuted.String() != "0.5" || orders.Orders[0].UserRef != 7 {
+		t.Errorf("expected order update, got %+v", orders)
+	}
+
+	balances, ok := nextEvent(t, ws).(*BalancesEvent)
+	if !ok || balances.Sequence != 3 || balances.Balances[0].Balance.String() != "1.5" || balances.Balances[0].Type != LedgerTypeTrade {
+		t.Errorf("expected balances, got %+v", balances)
+	}
+	if pong, ok := nextEvent(t, ws).(*PongEvent); !ok || pong.ReqID != 9 {
+		t.Errorf("expected pong, got %+v", pong)
+	}
+}
+
+func TestWebSocketV2BookWithoutPrecision(t *testing.T) {
+	ws := NewWebSocket(WSPublicURLv2)
+	events, err := ws.decodeV2([]byte(`{"channel":"book","type":"update","data":[{"symbol":"ETH/USD","bids":[{"price":1500.1,"qty":2}],"asks":[],"checksum":42}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if book := events[0].(*BookEvent); book.Checksum != 0 || book.Bids[0].Price.String() != "1500.1" {
+		t.Errorf("the checksum should be dropped without the pair's precision, got %+v", book)
+	}
+}